@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/repository"
+)
+
+// backupUsage справка по подкоманде backup.
+const backupUsage = `использование: backup <команда>
+  create           снять копию БД в директорию снимков, работает и при запущенном сервере
+  list             список снимков, последние первыми
+  check <снимок>   проверить снимок
+  restore <снимок> проверить снимок и подменить им файл БД, сервер должен быть остановлен
+снимок — имя файла в директории снимков или путь к файлу`
+
+// runBackup подкоманда backup: снимки БД SQLite и восстановление из них.
+func runBackup(cfg config.Config, app *slogavp.Application, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("не указана команда\n%s", backupUsage)
+	}
+	if !repository.IsSQLite(cfg.DBDriver) {
+		return repository.ErrBackupUnsupported
+	}
+	dir, name := cfg.BackupDirectory(), repository.BackupName(cfg.DBFile)
+
+	// Снимок по имени ищется в директории снимков
+	snapshot := func() (string, error) {
+		if len(args) < 2 {
+			return "", fmt.Errorf("не указан снимок\n%s", backupUsage)
+		}
+		path := args[1]
+		if filepath.Base(path) == path {
+			if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+				path = filepath.Join(dir, path)
+			}
+		}
+		return path, nil
+	}
+
+	switch args[0] {
+	case "create":
+		db, err := repository.NewOpenDB(cfg)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+		file, err := createBackup(repository.NewRepository(db, app), cfg, app)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Println(file.Path)
+		return nil
+
+	case "list":
+		files, err := repository.ListBackups(dir, name)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "СНИМОК\tРАЗМЕР\tСОЗДАН")
+		for _, f := range files {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", f.Name, f.Size, f.CreatedAt)
+		}
+		return w.Flush()
+
+	case "check":
+		path, err := snapshot()
+		if err != nil {
+			return err
+		}
+		if err = repository.CheckBackup(path); err != nil {
+			return err
+		}
+		_, _ = fmt.Printf("снимок %s в порядке\n", path)
+		return nil
+
+	case "restore":
+		path, err := snapshot()
+		if err != nil {
+			return err
+		}
+		previous, err := repository.RestoreBackup(path, cfg.DBFile, time.Now())
+		if err != nil {
+			return err
+		}
+		app.Log.Infof("БД %s восстановлена из снимка %s", cfg.DBFile, path)
+		_, _ = fmt.Printf("БД %s восстановлена из снимка %s\n", cfg.DBFile, path)
+		if previous != "" {
+			_, _ = fmt.Printf("прежний файл БД сохранён в %s\n", previous)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("неизвестная команда %q\n%s", args[0], backupUsage)
+	}
+}
+
+// createBackup снимок БД в директорию снимков и удаление снимков сверх TODO_BACKUP_KEEP.
+func createBackup(repo *repository.Repository, cfg config.Config, app *slogavp.Application) (repository.BackupFile, error) {
+	dir, name := cfg.BackupDirectory(), repository.BackupName(cfg.DBFile)
+
+	file, err := repo.Backup(dir, name, time.Now())
+	if err != nil {
+		return file, err
+	}
+	app.Log.Infof("Снимок БД сохранён: %s", file.Path)
+
+	removed, err := repository.PruneBackups(dir, name, cfg.BackupKeep)
+	for _, old := range removed {
+		app.Log.Infof("Удалён старый снимок БД: %s", old.Name)
+	}
+	return file, err
+}
+
+// scheduleBackups раз в TODO_BACKUP_INTERVAL снимает копию БД, пока работает сервер.
+// Нулевой интервал отключает снимки по расписанию.
+func scheduleBackups(repo *repository.Repository, cfg config.Config, app *slogavp.Application) {
+	if cfg.BackupInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.BackupInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := createBackup(repo, cfg, app); err != nil {
+			app.Log.Errorf("Не удалось снять копию БД: %v", err)
+		}
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"go_final_project_avp/internal/config"
 	"go_final_project_avp/internal/handler"
 	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/server"
+	"go_final_project_avp/internal/users"
 
-	"net/http"
+	"fmt"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,46 +21,76 @@ func main() {
 
 	cfg := config.LoadConfig(app)
 
+	// Подкоманда hash-password не работает с БД
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := runHashPassword(cfg, os.Stdin, os.Stdout); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Ошибка хэширования пароля: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	hasher := users.Hasher{Algorithm: cfg.PasswordAlgo, BcryptCost: cfg.BcryptCost}
+	if err := hasher.Validate(); err != nil {
+		app.Log.Errorf("Неверные настройки хэширования паролей: %v", err)
+		_, _ = fmt.Fprintf(os.Stderr, "Неверные настройки хэширования паролей: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Password != "" && !users.IsHash(cfg.Password) {
+		app.Log.Warnf("TODO_PASSWORD задан открытым текстом, запишите в него хэш: go run ./cmd hash-password")
+	}
+
+	// Подкоманда backup сама открывает БД: restore подменяет её файл
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		if err := runBackup(cfg, app, os.Args[2:]); err != nil {
+			app.Log.Errorf("Ошибка резервного копирования: %v", err)
+			_, _ = fmt.Fprintf(os.Stderr, "Ошибка резервного копирования: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	db, err := repository.NewOpenDB(cfg)
 	if err != nil {
-		app.Log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+		// Логгер не пишет в консоль и не завершает процесс, поэтому выходим сами
+		app.Log.Errorf("Не удалось подключиться к базе данных: %v", err)
+		_, _ = fmt.Fprintf(os.Stderr, "Не удалось подключиться к базе данных: %v\n", err)
+		os.Exit(1)
 	}
 
 	repo := repository.NewRepository(db, app)
 
+	// Подкоманда migrate управляет схемой БД без запуска сервера
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = runMigrate(repo, os.Args[2:]); err != nil {
+			app.Log.Errorf("Ошибка миграции: %v", err)
+			_, _ = fmt.Fprintf(os.Stderr, "Ошибка миграции: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err = repo.RunMigrations(cfg); err != nil {
-		app.Log.Fatalf("Не удалось выполнить миграцию: %v", err)
+		app.Log.Errorf("Не удалось выполнить миграцию: %v", err)
+		_, _ = fmt.Fprintf(os.Stderr, "Не удалось выполнить миграцию: %v\n", err)
+		os.Exit(1)
 	}
 
+	// Корзина очищается в фоне, пока работает сервер
+	go purgeTrash(repo, cfg.TrashRetention, app)
+	// Истёкшие сессии входа
+	go purgeSessions(repo, app)
+	// Снимки БД по расписанию
+	go scheduleBackups(repo, cfg, app)
+	// Напоминания о задачах по настроенным каналам
+	go runReminders(repo, cfg, app)
+	// Доставка событий задач подпискам веб-хуков
+	go dispatchWebhooks(repo, cfg, app)
+
 	newHandler := handler.NewHandler(cfg, repo, app)
 
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
-	r.Static("/css", "internal/web/css")
-	r.Static("/js", "internal/web/js")
-	r.StaticFile("/favicon.ico", "internal/web/favicon.ico")
-	r.LoadHTMLGlob("internal/web/*.html")
-	// Маршрут для логина страницы
-	r.GET("/login.html", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "login.html", nil)
-	})
-	// Маршрут для аутентификации
-	r.POST("/api/signin", newHandler.SignIn)
-
-	r.GET("/", handler.Index)
-	r.GET("/index.html", handler.Index)
-	r.GET("api/nextdate", newHandler.GetNextDate)
-	// Применяем middleware для защищённых маршрутов
-	authRoutes := r.Group("/api")
-	authRoutes.Use(newHandler.AuthMiddleware())
-	{
-		authRoutes.GET("/tasks", newHandler.GetTasks)
-		authRoutes.GET("/task", newHandler.GetTasksId)
-		authRoutes.PUT("/task", newHandler.UpdateTask)
-		authRoutes.POST("/task", newHandler.CreateTask)
-		authRoutes.DELETE("/task", newHandler.DeleteTask)
-		authRoutes.POST("/task/done", newHandler.DoneTask)
-	}
+	r := server.NewRouter(newHandler, server.WebDir)
 
 	if err = r.Run(":" + cfg.Port); err != nil {
 		app.Log.Fatalf("Не удалось запустить сервер: %v", err)
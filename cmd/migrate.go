@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"go_final_project_avp/internal/repository"
+)
+
+// migrateUsage справка по подкоманде migrate.
+const migrateUsage = `использование: migrate <команда>
+  status             список миграций и их состояние
+  up [версия]        применить миграции до версии, по умолчанию до последней
+  down [количество]  откатить последние миграции, по умолчанию одну`
+
+// runMigrate подкоманда migrate: управление версией схемы БД без запуска сервера.
+func runMigrate(repo *repository.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("не указана команда\n%s", migrateUsage)
+	}
+
+	// Необязательный числовой аргумент команды
+	number := func(def int) (int, error) {
+		if len(args) < 2 {
+			return def, nil
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("ожидается неотрицательное число, получено %q", args[1])
+		}
+		return n, nil
+	}
+
+	switch args[0] {
+	case "status":
+		states, err := repo.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ВЕРСИЯ\tИМЯ\tПРИМЕНЕНА")
+		for _, s := range states {
+			applied := "нет"
+			if s.Applied {
+				applied = s.AppliedAt
+			}
+			_, _ = fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+
+	case "up":
+		target, err := number(0)
+		if err != nil {
+			return err
+		}
+		return repo.MigrateUp(target)
+
+	case "down":
+		steps, err := number(1)
+		if err != nil {
+			return err
+		}
+		return repo.MigrateDown(steps)
+
+	default:
+		return fmt.Errorf("неизвестная команда %q\n%s", args[0], migrateUsage)
+	}
+}
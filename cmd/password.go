@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/users"
+)
+
+// runHashPassword подкоманда hash-password: читает пароль из первой строки стандартного ввода
+// и печатает его хэш алгоритмом из TODO_PASSWORD_ALGO. Хэш можно записать в TODO_PASSWORD вместо пароля.
+// Пароль не передаётся аргументом, чтобы не попасть в историю команд и список процессов.
+func runHashPassword(cfg config.Config, in io.Reader, out io.Writer) error {
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("ошибка чтения пароля: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return errors.New("пароль не указан: передайте его первой строкой стандартного ввода")
+	}
+
+	hash, err := users.Hasher{Algorithm: cfg.PasswordAlgo, BcryptCost: cfg.BcryptCost}.Hash(password)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, hash)
+	return err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/notify"
+	"go_final_project_avp/internal/repository"
+)
+
+// runReminders рассылает напоминания о задачах их владельцам по настроенным каналам, пока работает сервер.
+// С нулевым TODO_REMIND_INTERVAL напоминания не отправляются.
+func runReminders(repo *repository.Repository, cfg config.Config, app *slogavp.Application) {
+	if cfg.RemindInterval <= 0 {
+		return
+	}
+	notifiers := notify.NewNotifiers(cfg)
+
+	leadDays, err := notify.ParseLeadDays(cfg.RemindDays)
+	if err != nil {
+		app.Log.Errorf("Напоминания отключены: %v", err)
+		return
+	}
+	at, err := notify.ParseTimeOfDay(cfg.RemindAt)
+	if err != nil {
+		app.Log.Errorf("Напоминания отключены: %v", err)
+		return
+	}
+
+	names := make([]string, 0, len(notifiers))
+	for _, n := range notifiers {
+		names = append(names, n.Name())
+	}
+	app.Log.Infof("Напоминания включены, каналы: %v", names)
+
+	worker := &notify.Worker{Store: repo, Notifiers: notifiers, LeadDays: leadDays, At: at, App: app}
+	worker.Run(context.Background(), cfg.RemindInterval)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"time"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"go_final_project_avp/internal/repository"
+)
+
+// sessionPurgeInterval как часто удалять истёкшие сессии входа.
+const sessionPurgeInterval = time.Hour
+
+// purgeSessions при запуске и затем раз в sessionPurgeInterval удаляет истёкшие сессии входа.
+func purgeSessions(repo *repository.Repository, app *slogavp.Application) {
+	ticker := time.NewTicker(sessionPurgeInterval)
+	defer ticker.Stop()
+	for {
+		count, err := repo.PurgeSessions(time.Now())
+		if err != nil {
+			app.Log.Errorf("Не удалось удалить истёкшие сессии: %v", err)
+		} else if count > 0 {
+			app.Log.Infof("Удалено истёкших сессий: %d", count)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"time"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"go_final_project_avp/internal/repository"
+)
+
+// trashPurgeInterval как часто удалять из корзины задачи старше срока хранения.
+const trashPurgeInterval = time.Hour
+
+// purgeTrash при запуске и затем раз в trashPurgeInterval окончательно удаляет задачи,
+// пролежавшие в корзине дольше retention. Нулевой срок хранения отключает очистку.
+func purgeTrash(repo *repository.Repository, retention time.Duration, app *slogavp.Application) {
+	if retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		count, err := repo.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			app.Log.Errorf("Не удалось очистить корзину: %v", err)
+		} else if count > 0 {
+			app.Log.Infof("Из корзины удалено задач: %d", count)
+		}
+		<-ticker.C
+	}
+}
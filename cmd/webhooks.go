@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/webhooks"
+)
+
+// dispatchWebhooks доставляет события задач из очереди подписчикам, пока работает сервер.
+// С нулевым TODO_HOOKS_INTERVAL события копятся в очереди и не отправляются.
+func dispatchWebhooks(repo *repository.Repository, cfg config.Config, app *slogavp.Application) {
+	if cfg.HooksInterval <= 0 {
+		return
+	}
+
+	dispatcher := &webhooks.Dispatcher{
+		Store:        repo,
+		MaxAttempts:  cfg.HooksAttempts,
+		Backoff:      cfg.HooksBackoff,
+		AllowPrivate: cfg.HooksAllowPrivate,
+		App:          app,
+	}
+	dispatcher.Run(context.Background(), cfg.HooksInterval)
+}
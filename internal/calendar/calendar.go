@@ -0,0 +1,101 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go_final_project_avp/internal/tasks"
+)
+
+// ComponentEvent и ComponentTodo виды компонентов iCalendar для задач.
+const (
+	ComponentEvent = "VEVENT"
+	ComponentTodo  = "VTODO"
+)
+
+// Feed подписка на календарь задач, доступная по долгоживущему токену.
+type Feed struct {
+	Id        string `json:"id"`
+	UserId    int64  `json:"-"`
+	Name      string `json:"name"`
+	TokenHash string `json:"-"`
+	CreatedAt string `json:"created_at"`
+}
+
+// maxLineOctets максимальная длина строки iCalendar без переноса по RFC 5545.
+const maxLineOctets = 75
+
+// Render выводит задачи в формате iCalendar (RFC 5545) компонентами component.
+func Render(w io.Writer, list []tasks.Task, component string, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:-//go_final_project_avp//scheduler//RU")
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "X-WR-CALNAME:Планировщик задач")
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, t := range list {
+		date, err := time.Parse(tasks.TimeFormat, t.Date)
+		if err != nil {
+			return fmt.Errorf("некорректная дата задачи %s: %w", t.Id, err)
+		}
+
+		writeLine(bw, "BEGIN:"+component)
+		writeLine(bw, "UID:task-"+t.Id+"@go_final_project_avp")
+		writeLine(bw, "DTSTAMP:"+stamp)
+		writeLine(bw, "DTSTART;VALUE=DATE:"+date.Format(tasks.TimeFormat))
+		if component == ComponentTodo {
+			writeLine(bw, "DUE;VALUE=DATE:"+date.AddDate(0, 0, 1).Format(tasks.TimeFormat))
+		} else {
+			writeLine(bw, "DTEND;VALUE=DATE:"+date.AddDate(0, 0, 1).Format(tasks.TimeFormat))
+		}
+		writeLine(bw, "SUMMARY:"+escapeText(t.Title))
+		if t.Comment != "" {
+			writeLine(bw, "DESCRIPTION:"+escapeText(t.Comment))
+		}
+
+		// Правило без эквивалента в RRULE выводится одной ближайшей датой
+		if rule, err := tasks.Parse(t.Repeat); err == nil && rule != nil {
+			if rrule, err := tasks.FormatRRule(rule, date); err == nil {
+				writeLine(bw, rrule)
+			}
+		}
+		writeLine(bw, "END:"+component)
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// escapeText экранирование значения типа TEXT по RFC 5545.
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeLine записывает строку с переносом длинных строк по 75 октетов, не разрывая символы UTF-8.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		_, _ = w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Продолжение строки начинается с пробела, который тоже занимает октет
+		limit = maxLineOctets - 1
+	}
+	_, _ = w.WriteString(line + "\r\n")
+}
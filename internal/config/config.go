@@ -1,6 +1,9 @@
 package config
 
 import (
+	"path/filepath"
+	"time"
+
 	slogavp "github.com/Anatoly8853/slog-avp/v2"
 	"github.com/spf13/viper"
 )
@@ -10,6 +13,56 @@ type Config struct {
 	DBFile    string `mapstructure:"TODO_DBFILE"`
 	Password  string `mapstructure:"TODO_PASSWORD"`
 	JwtSecret string `mapstructure:"TODO_JWT_SECRET"`
+	DBDriver  string `mapstructure:"TODO_DB_DRIVER"` // sqlite (по умолчанию, прежнее имя sqlite3) или postgres
+	DBDSN     string `mapstructure:"TODO_DB_DSN"`    // строка подключения для postgres
+	// PasswordAlgo алгоритм хэширования новых паролей: bcrypt (по умолчанию) или argon2id
+	PasswordAlgo string `mapstructure:"TODO_PASSWORD_ALGO"`
+	// BcryptCost стоимость bcrypt для новых паролей
+	BcryptCost int `mapstructure:"TODO_BCRYPT_COST"`
+	// AccessTTL время жизни токена доступа, RefreshTTL — сессии без обновления
+	AccessTTL  time.Duration `mapstructure:"TODO_ACCESS_TTL"`
+	RefreshTTL time.Duration `mapstructure:"TODO_REFRESH_TTL"`
+	// TOTPIssuer название сервиса в приложении-аутентификаторе
+	TOTPIssuer string `mapstructure:"TODO_TOTP_ISSUER"`
+	// TrashRetention сколько задача хранится в корзине до окончательного удаления, 0 — хранить всегда
+	TrashRetention time.Duration `mapstructure:"TODO_TRASH_RETENTION"`
+	// BackupDir директория снимков БД, по умолчанию backups рядом с файлом БД
+	BackupDir string `mapstructure:"TODO_BACKUP_DIR"`
+	// BackupInterval как часто сервер снимает копию БД, 0 — только вручную
+	BackupInterval time.Duration `mapstructure:"TODO_BACKUP_INTERVAL"`
+	// BackupKeep сколько последних снимков хранить, 0 — хранить все
+	BackupKeep int `mapstructure:"TODO_BACKUP_KEEP"`
+
+	// Напоминания: за сколько дней до даты задачи (через запятую), с какого времени суток ЧЧ:ММ
+	// и как часто проверять задачи, 0 — не напоминать
+	RemindDays     string        `mapstructure:"TODO_REMIND_DAYS"`
+	RemindAt       string        `mapstructure:"TODO_REMIND_AT"`
+	RemindInterval time.Duration `mapstructure:"TODO_REMIND_INTERVAL"`
+	// Канал напоминаний письмом: адрес сервера host:port, учётная запись и отправитель.
+	// Получателя каждый пользователь указывает сам
+	SMTPAddr     string `mapstructure:"TODO_SMTP_ADDR"`
+	SMTPUser     string `mapstructure:"TODO_SMTP_USER"`
+	SMTPPassword string `mapstructure:"TODO_SMTP_PASSWORD"`
+	SMTPFrom     string `mapstructure:"TODO_SMTP_FROM"`
+	// Канал напоминаний через Telegram-бота: токен бота и адрес Bot API, чат каждый пользователь указывает сам
+	TelegramToken string `mapstructure:"TODO_TELEGRAM_TOKEN"`
+	TelegramAPI   string `mapstructure:"TODO_TELEGRAM_API"`
+
+	// Веб-хуки событий задач: как часто проверять очередь доставки (0 — не доставлять),
+	// сколько попыток делать и задержка после первой неудачи, дальше она удваивается
+	HooksInterval time.Duration `mapstructure:"TODO_HOOKS_INTERVAL"`
+	HooksAttempts int           `mapstructure:"TODO_HOOKS_ATTEMPTS"`
+	HooksBackoff  time.Duration `mapstructure:"TODO_HOOKS_BACKOFF"`
+	// HooksAllowPrivate разрешает подписки на localhost и адреса внутренней сети
+	HooksAllowPrivate bool `mapstructure:"TODO_HOOKS_ALLOW_PRIVATE"`
+}
+
+// BackupDirectory директория снимков БД: TODO_BACKUP_DIR или backups в директории файла БД.
+func (c Config) BackupDirectory() string {
+	if c.BackupDir != "" {
+		return c.BackupDir
+	}
+	return filepath.Join(filepath.Dir(c.DBFile), "backups")
 }
 
 func LoadConfig(app *slogavp.Application) (cfg Config) {
@@ -20,6 +73,29 @@ func LoadConfig(app *slogavp.Application) (cfg Config) {
 
 	// Чтение переменных окружения
 	viper.AutomaticEnv() // Автоматически читать переменные окружения
+	// Необязательные параметры, без значения по умолчанию viper не видит их в окружении
+	viper.SetDefault("TODO_DB_DRIVER", "sqlite")
+	viper.SetDefault("TODO_DB_DSN", "")
+	viper.SetDefault("TODO_PASSWORD_ALGO", "bcrypt")
+	viper.SetDefault("TODO_BCRYPT_COST", 10)
+	viper.SetDefault("TODO_ACCESS_TTL", "15m")
+	viper.SetDefault("TODO_REFRESH_TTL", "720h")
+	viper.SetDefault("TODO_TOTP_ISSUER", "Планировщик задач")
+	viper.SetDefault("TODO_TRASH_RETENTION", "720h")
+	viper.SetDefault("TODO_BACKUP_DIR", "")
+	viper.SetDefault("TODO_BACKUP_INTERVAL", "0")
+	viper.SetDefault("TODO_BACKUP_KEEP", 7)
+	viper.SetDefault("TODO_REMIND_DAYS", "0")
+	viper.SetDefault("TODO_REMIND_AT", "09:00")
+	viper.SetDefault("TODO_REMIND_INTERVAL", "5m")
+	for _, key := range []string{"TODO_SMTP_ADDR", "TODO_SMTP_USER", "TODO_SMTP_PASSWORD", "TODO_SMTP_FROM", "TODO_TELEGRAM_TOKEN"} {
+		viper.SetDefault(key, "")
+	}
+	viper.SetDefault("TODO_TELEGRAM_API", "https://api.telegram.org")
+	viper.SetDefault("TODO_HOOKS_INTERVAL", "5s")
+	viper.SetDefault("TODO_HOOKS_ATTEMPTS", 8)
+	viper.SetDefault("TODO_HOOKS_BACKOFF", "30s")
+	viper.SetDefault("TODO_HOOKS_ALLOW_PRIVATE", false)
 	// Попытка чтения из файла конфигурации
 	if err := viper.MergeInConfig(); err != nil {
 		app.Log.Printf("Error reading .env file, %s", err)
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roleKey ключ контекста gin с ролью авторизованного пользователя.
+const roleKey = "role"
+
+// currentRole роль пользователя, проверенного AuthMiddleware.
+func currentRole(c *gin.Context) string {
+	return c.GetString(roleKey)
+}
+
+// RequireRole пропускает пользователей с ролью role и выше, ставится после AuthMiddleware.
+func (h *Handler) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !users.RoleAllows(currentRole(c), role) {
+			h.app.Log.Debugf("RequireRole доступ к %s запрещён пользователю %d с ролью %q", c.FullPath(), currentUserID(c), currentRole(c))
+			message := "Недостаточно прав, нужна роль " + role
+			if role == users.RoleAdmin {
+				message = "Доступно только администратору"
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": message})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// viewerRoutes маршруты задач, доступные viewer: только чтение списка и одной задачи.
+var viewerRoutes = map[string]bool{
+	"/api/tasks": true,
+	"/api/task":  true,
+}
+
+// TaskRole ставится после AuthMiddleware на маршруты задач: viewer может только GET /api/tasks
+// и GET /api/task, остальные маршруты, в том числе чтение истории, корзины и экспорт, — editor.
+func (h *Handler) TaskRole() gin.HandlerFunc {
+	viewer, editor := h.RequireRole(users.RoleViewer), h.RequireRole(users.RoleEditor)
+	return func(c *gin.Context) {
+		if (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) && viewerRoutes[c.FullPath()] {
+			viewer(c)
+			return
+		}
+		editor(c)
+	}
+}
+
+// AdminMiddleware пропускает только администратора, ставится после AuthMiddleware.
+func (h *Handler) AdminMiddleware() gin.HandlerFunc {
+	return h.RequireRole(users.RoleAdmin)
+}
+
+// GetUsers обработчик для маршрута GET /api/admin/users: все пользователи с ролями.
+func (h *Handler) GetUsers(c *gin.Context) {
+	list, err := h.repo.GetUsers()
+	if err != nil {
+		h.app.Log.Debugf("GetUsers repo.GetUsers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": list})
+}
+
+// SetUserRole обработчик для маршрута PUT /api/admin/users: смена роли пользователя.
+// Сессии пользователя при этом завершаются, свою роль администратор не меняет, чтобы не потерять доступ.
+func (h *Handler) SetUserRole(c *gin.Context) {
+	var request struct {
+		Id   string `json:"id"`
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("SetUserRole ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return
+	}
+	if !users.ValidRole(request.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("неизвестная роль '%s', допустимы: %s", request.Role, strings.Join(users.Roles, ", "))})
+		return
+	}
+	id, err := strconv.ParseInt(request.Id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный идентификатор пользователя"})
+		return
+	}
+	if id == currentUserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменить свою роль"})
+		return
+	}
+
+	user, err := h.repo.GetUserById(id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		h.app.Log.Debugf("SetUserRole repo.GetUserById: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка смены роли"})
+		return
+	}
+	if user.Role != request.Role {
+		if err = h.repo.SetUserRole(id, request.Role, time.Now()); err != nil {
+			h.app.Log.Debugf("SetUserRole repo.SetUserRole: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка смены роли"})
+			return
+		}
+		h.app.Log.Infof("Роль пользователя %s изменена: %s -> %s", user.Login, user.Role, request.Role)
+		user.Role = request.Role
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// CreateBackup обработчик для маршрута POST /api/admin/backup: снимок БД в директорию снимков,
+// после него удаляются снимки сверх TODO_BACKUP_KEEP.
+func (h *Handler) CreateBackup(c *gin.Context) {
+	dir, name := h.config.BackupDirectory(), repository.BackupName(h.config.DBFile)
+
+	file, err := h.repo.Backup(dir, name, time.Now())
+	if errors.Is(err, repository.ErrBackupUnsupported) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.app.Log.Errorf("CreateBackup repo.Backup: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка создания снимка БД"})
+		return
+	}
+	h.app.Log.Infof("Снимок БД сохранён: %s", file.Path)
+
+	if _, err = repository.PruneBackups(dir, name, h.config.BackupKeep); err != nil {
+		h.app.Log.Errorf("CreateBackup repository.PruneBackups: %v", err)
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// GetBackups обработчик для маршрута GET /api/admin/backups: снимки БД, последние первыми.
+func (h *Handler) GetBackups(c *gin.Context) {
+	files, err := repository.ListBackups(h.config.BackupDirectory(), repository.BackupName(h.config.DBFile))
+	if err != nil {
+		h.app.Log.Debugf("GetBackups repository.ListBackups: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backups": files})
+}
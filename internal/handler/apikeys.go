@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyBytes длина случайной части API-ключа в байтах.
+const apiKeyBytes = 32
+
+// apiKeyPrefixLen сколько символов начала ключа показывать в списке.
+const apiKeyPrefixLen = len(users.APIKeyPrefix) + 8
+
+// apiKeyKey ключ контекста gin с API-ключом, которым авторизован запрос.
+const apiKeyKey = "api_key"
+
+// currentAPIKey API-ключ запроса, ok — запрос авторизован ключом, а не сессией.
+func currentAPIKey(c *gin.Context) (users.APIKey, bool) {
+	v, ok := c.Get(apiKeyKey)
+	if !ok {
+		return users.APIKey{}, false
+	}
+	key, ok := v.(users.APIKey)
+	return key, ok
+}
+
+// authByAPIKey авторизация запроса API-ключом из заголовка Authorization.
+func (h *Handler) authByAPIKey(c *gin.Context, secret string, now time.Time) {
+	key, err := h.repo.GetAPIKeyByHash(hashToken(secret))
+	if err != nil {
+		h.app.Log.Debugf("AuthMiddleware repo.GetAPIKeyByHash: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный API-ключ"})
+		c.Abort()
+		return
+	}
+	user, err := h.repo.GetUserById(key.UserId)
+	if err != nil {
+		h.app.Log.Debugf("AuthMiddleware пользователь не найден: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден"})
+		c.Abort()
+		return
+	}
+	// Отметка использования не должна мешать самому запросу
+	if err = h.repo.TouchAPIKey(key.Id, now); err != nil {
+		h.app.Log.Errorf("Не удалось отметить использование API-ключа %s: %v", key.Id, err)
+	}
+
+	c.Set(userIDKey, user.Id)
+	c.Set(roleKey, user.Role)
+	c.Set(apiKeyKey, key)
+	c.Next()
+}
+
+// TaskScope ставится после AuthMiddleware на маршруты задач: запросу с API-ключом нужна область
+// tasks:read для чтения и tasks:write для изменений. Запросы с токеном сессии проходят без проверки.
+func (h *Handler) TaskScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := currentAPIKey(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		scope := users.ScopeTasksWrite
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scope = users.ScopeTasksRead
+		}
+		if !key.Allows(scope) {
+			h.app.Log.Debugf("TaskScope у API-ключа %s нет области %s", key.Id, scope)
+			c.JSON(http.StatusForbidden, gin.H{"error": "У API-ключа нет доступа " + scope})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly ставится после AuthMiddleware на маршруты, недоступные по API-ключу:
+// управление ключами, подписками, сессиями и обслуживание сервера.
+func (h *Handler) SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := currentAPIKey(c); ok {
+			h.app.Log.Debugf("SessionOnly запрос с API-ключом %s к %s", key.Id, c.FullPath())
+			c.JSON(http.StatusForbidden, gin.H{"error": "Недоступно по API-ключу, войдите с паролем"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CreateAPIKey создаём именованный API-ключ и выдаём его один раз.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var request struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("CreateAPIKey ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || utf8.RuneCountInString(request.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "поле 'name' обязательно, не длиннее 100 символов"})
+		return
+	}
+	scopes, err := users.ParseScopes(request.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	raw := make([]byte, apiKeyBytes)
+	if _, err = rand.Read(raw); err != nil {
+		h.app.Log.Debugf("CreateAPIKey Ошибка при создании ключа: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании ключа"})
+		return
+	}
+	secret := users.APIKeyPrefix + hex.EncodeToString(raw)
+
+	key := users.APIKey{Name: request.Name, Prefix: secret[:apiKeyPrefixLen], KeyHash: hashToken(secret), Scopes: scopes}
+	if _, err = h.repo.CreateAPIKey(currentUserID(c), &key); err != nil {
+		h.app.Log.Debugf("CreateAPIKey repo.CreateAPIKey ошибка добавления в бд: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка создания ключа"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":         key.Id,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"scopes":     key.Scopes,
+		"key":        secret,
+		"created_at": key.CreatedAt,
+	})
+}
+
+// GetAPIKeys список API-ключей пользователя без самих ключей.
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	list, err := h.repo.GetAPIKeys(currentUserID(c))
+	if err != nil {
+		h.app.Log.Debugf("GetAPIKeys repo.GetAPIKeys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": list})
+}
+
+// DeleteAPIKey отзываем API-ключ, запросы с ним сразу перестают проходить.
+func (h *Handler) DeleteAPIKey(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "идентификатор ключа обязателен"})
+		return
+	}
+
+	err := h.repo.DeleteAPIKey(currentUserID(c), id)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		h.app.Log.Debugf("DeleteAPIKey repo.DeleteAPIKey: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ключ не найден"})
+		return
+	}
+	if err != nil {
+		h.app.Log.Debugf("DeleteAPIKey repo.DeleteAPIKey: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка удаления ключа"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
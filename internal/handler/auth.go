@@ -1,21 +1,91 @@
 package handler
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/users"
+
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
-// TokenTimeHour время жизни токена.
-const TokenTimeHour = 8
+// userIDKey ключ контекста gin с id авторизованного пользователя.
+const userIDKey = "user_id"
+
+// currentUserID id пользователя, проверенного AuthMiddleware.
+func currentUserID(c *gin.Context) int64 {
+	return c.GetInt64(userIDKey)
+}
+
+// credentialClaim claim токена с меткой учётных данных, см. users.CredentialVersion.
+const credentialClaim = "cred"
+
+// hasher хэширование новых паролей по настройкам TODO_PASSWORD_ALGO и TODO_BCRYPT_COST.
+func (h *Handler) hasher() users.Hasher {
+	return users.Hasher{Algorithm: h.config.PasswordAlgo, BcryptCost: h.config.BcryptCost}
+}
+
+// credentialVersion метка текущих учётных данных пользователя: хэш пароля из БД или TODO_PASSWORD.
+func (h *Handler) credentialVersion(user users.User) string {
+	credential := user.PasswordHash
+	if credential == "" {
+		credential = h.config.Password
+	}
+	return users.CredentialVersion(h.config.JwtSecret, credential)
+}
+
+// Register регистрация нового пользователя.
+func (h *Handler) Register(c *gin.Context) {
+	var request struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("Register неверный формат запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+
+	request.Login = strings.TrimSpace(request.Login)
+	if err := users.Validate(request.Login, request.Password); err != nil {
+		h.app.Log.Debugf("Register users.Validate: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := h.hasher().Hash(request.Password)
+	if err != nil {
+		h.app.Log.Debugf("Register hasher.Hash: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка регистрации"})
+		return
+	}
+
+	user := users.User{Login: request.Login, PasswordHash: hash}
+	if _, err = h.repo.CreateUser(&user); err != nil {
+		h.app.Log.Debugf("Register repo.CreateUser: %v", err)
+		if errors.Is(err, repository.ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка регистрации"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": strconv.FormatInt(user.Id, 10), "login": user.Login})
+}
 
 // SignIn обработчик введенного пароля.
 func (h *Handler) SignIn(c *gin.Context) {
-	// Получаем пароль из запроса
+	// Получаем логин и пароль из запроса, без логина входит администратор
 	var request struct {
+		Login    string `json:"login"`
 		Password string `json:"password"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -24,52 +94,77 @@ func (h *Handler) SignIn(c *gin.Context) {
 		return
 	}
 
-	// Проверяем пароль
-	storedPassword := h.config.Password
-	if storedPassword == "" || request.Password != storedPassword {
-		h.app.Log.Debugf("SignIn Неверный пароль: %v", request.Password)
+	login := strings.TrimSpace(request.Login)
+	if login == "" {
+		login = users.AdminLogin
+	}
+
+	user, err := h.repo.GetUserByLogin(login)
+	if err != nil {
+		h.app.Log.Debugf("SignIn repo.GetUserByLogin: %v", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный пароль"})
 		return
 	}
 
-	// Округляем текущее время до начала ближайшего 8-часового периода
-	now := time.Now()
-	startOf8HourPeriod := now.Truncate(TokenTimeHour * time.Hour)
-
-	// Формируем JWT-токен с хэшем пароля
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		// Вставляем контрольную сумму пароля
-		"password_hash": fmt.Sprintf("%x", request.Password),
-		// Устанавливаем срок жизни токена 8 часов
-		"exp": startOf8HourPeriod.Add(TokenTimeHour * time.Hour).Unix(),
-	})
+	// Проверяем пароль, у администратора без хэша в БД — по TODO_PASSWORD
+	var valid bool
+	if user.PasswordHash == "" {
+		valid = users.CheckConfigPassword(h.config.Password, request.Password)
+	} else {
+		valid = users.CheckPassword(user.PasswordHash, request.Password)
+	}
+	if !valid {
+		h.app.Log.Debugf("SignIn Неверный пароль пользователя %s", user.Login)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный пароль"})
+		return
+	}
 
-	tokenString, err := token.SignedString([]byte(h.config.JwtSecret))
+	// С подключённым вторым фактором токены выдаются только после кода, см. SignInSecondFactor
+	_, enabled, err := h.enabledTOTP(user.Id)
 	if err != nil {
-		h.app.Log.Debugf("SignIn Ошибка при создании токена: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токена"})
+		h.app.Log.Debugf("SignIn enabledTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка входа"})
+		return
+	}
+	if enabled {
+		mfa, err := h.mfaToken(user, time.Now())
+		if err != nil {
+			h.app.Log.Debugf("SignIn Ошибка при создании токена: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токена"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfa})
 		return
 	}
 
-	// Устанавливаем токен в куку
-	c.SetCookie("token", tokenString, TokenTimeHour*3600, "/", "", false, true)
-
-	// Возвращаем токен в JSON-ответе
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	h.startSession(c, user)
 }
 
-// AuthMiddleware проверка JWT-токена.
+// AuthMiddleware проверка JWT-токена и его сессии.
+// Если кука с токеном доступа истекла, новый токен выдаётся по куке с токеном обновления.
 func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Получаем токен из куки
-		tokenString, err := c.Cookie("token")
+		now := time.Now()
+
+		// Скрипты передают токен или API-ключ в заголовке Authorization, браузер — токен в куке
+		tokenString, fromHeader, err := bearerToken(c)
 		if err != nil {
-			h.app.Log.Debugf("AuthMiddleware Токен отсутствует: %v", err)
-			// Перенаправляем на страницу логина
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+			h.app.Log.Debugf("AuthMiddleware bearerToken: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный заголовок Authorization, ожидается Bearer"})
 			c.Abort()
 			return
 		}
+		if strings.HasPrefix(tokenString, users.APIKeyPrefix) {
+			h.authByAPIKey(c, tokenString, now)
+			return
+		}
+		if !fromHeader {
+			if tokenString, err = c.Cookie(accessCookie); err != nil {
+				h.app.Log.Debugf("AuthMiddleware Токен отсутствует: %v", err)
+				h.authByRefresh(c, now, "Токен отсутствует")
+				return
+			}
+		}
 
 		// Проверяем токен
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -81,7 +176,19 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 			return []byte(h.config.JwtSecret), nil
 		})
 
-		// Если произошла ошибка или токен недействителен
+		// Истёкший токен доступа из куки заменяем по токену обновления, остальные ошибки — отказ.
+		// Клиент с токеном в заголовке обновляет его сам через /api/refresh
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			h.app.Log.Debugf("AuthMiddleware Токен истёк: %v", err)
+			if fromHeader {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен истёк"})
+				c.Abort()
+				return
+			}
+			h.authByRefresh(c, now, "Токен истёк")
+			return
+		}
 		if err != nil || !token.Valid {
 			h.app.Log.Debugf("AuthMiddleware Неверный токен: %v", err)
 			// Перенаправляем на страницу логина
@@ -90,7 +197,7 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Проверяем хэш пароля
+		// Проверяем учётные данные из токена
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
 			h.app.Log.Debugf("AuthMiddleware Неверный формат claims")
@@ -99,23 +206,96 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		passwordHash, ok := claims["password_hash"].(string)
+		// Токен второго шага входа не даёт доступа к API
+		if _, ok = claims[purposeClaim]; ok {
+			h.app.Log.Debugf("AuthMiddleware Токен с назначением %v вместо токена доступа", claims[purposeClaim])
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный токен"})
+			c.Abort()
+			return
+		}
+
+		id, ok := claims["user_id"].(float64)
 		if !ok {
-			h.app.Log.Debugf("AuthMiddleware Отсутствует хэш пароля в токене")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Отсутствует хэш пароля в токене"})
+			h.app.Log.Debugf("AuthMiddleware Отсутствует id пользователя в токене")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный токен"})
+			c.Abort()
+			return
+		}
+		user, err := h.repo.GetUserById(int64(id))
+		if err != nil {
+			h.app.Log.Debugf("AuthMiddleware пользователь не найден: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден"})
 			c.Abort()
 			return
 		}
 
-		// Проверяем, что хэш пароля в токене соответствует текущему паролю
-		currentPasswordHash := fmt.Sprintf("%x", h.config.Password)
-		if passwordHash != currentPasswordHash {
-			h.app.Log.Debugf("AuthMiddleware Несоответствие хэша пароля")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Несоответствие хэша пароля"})
+		// Метка в токене должна совпадать с текущими учётными данными: после смены пароля старые токены не подходят.
+		// Токены прежнего формата без метки тоже отклоняются, с ними нужно войти заново
+		credential, _ := claims[credentialClaim].(string)
+		if subtle.ConstantTimeCompare([]byte(credential), []byte(h.credentialVersion(user))) != 1 {
+			h.app.Log.Debugf("AuthMiddleware Учётные данные пользователя %s изменились", user.Login)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": credentialChangedMessage})
 			c.Abort()
 			return
 		}
-		// Если токен валиден и хэш пароля совпадает, продолжаем выполнение запроса
+
+		// Роль в токене должна совпадать с текущей: после смены роли нужно войти заново
+		if role, _ := claims[roleClaim].(string); role != user.Role {
+			h.app.Log.Debugf("AuthMiddleware Роль пользователя %s изменилась: %q -> %q", user.Login, role, user.Role)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Роль изменена, войдите заново"})
+			c.Abort()
+			return
+		}
+
+		// Сессия токена не должна быть отозвана: после выхода токен доступа перестаёт действовать сразу
+		sid, _ := claims[sessionClaim].(float64)
+		session, err := h.repo.GetSession(int64(sid))
+		if err != nil || session.UserId != user.Id || !session.Active(now) {
+			h.app.Log.Debugf("AuthMiddleware Сессия %v пользователя %s завершена: %v", claims[sessionClaim], user.Login, err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": sessionEndedMessage})
+			c.Abort()
+			return
+		}
+
+		// Если токен валиден, запоминаем пользователя и продолжаем выполнение запроса
+		c.Set(userIDKey, user.Id)
+		c.Set(roleKey, user.Role)
+		c.Set(sessionIDKey, session.Id)
 		c.Next()
 	}
 }
+
+// bearerToken токен или API-ключ из заголовка Authorization: Bearer, fromHeader — заголовок передан.
+func bearerToken(c *gin.Context) (token string, fromHeader bool, err error) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return "", false, nil
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", true, fmt.Errorf("неверная схема авторизации: %q", scheme)
+	}
+	return token, true, nil
+}
+
+// authByRefresh продолжаем запрос с новым токеном доступа по куке с токеном обновления,
+// без неё отвечаем 401 с текстом reason.
+func (h *Handler) authByRefresh(c *gin.Context, now time.Time, reason string) {
+	session, user, err := h.renewAccess(c, now)
+	if err != nil {
+		h.app.Log.Debugf("AuthMiddleware renewAccess: %v", err)
+		if errors.Is(err, errSessionEnded) || errors.Is(err, errCredentialChanged) {
+			reason = sessionErrorMessage(err)
+		}
+		// Перенаправляем на страницу логина
+		c.JSON(http.StatusUnauthorized, gin.H{"error": reason})
+		c.Abort()
+		return
+	}
+
+	c.Set(userIDKey, user.Id)
+	c.Set(roleKey, user.Role)
+	c.Set(sessionIDKey, session.Id)
+	c.Next()
+}
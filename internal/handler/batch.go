@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/tasks"
+	"go_final_project_avp/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchEvents событие, которое порождает операция пакета; shift меняет дату и считается изменением задачи.
+var batchEvents = map[string]string{
+	tasks.OpCreate: webhooks.EventTaskCreated,
+	tasks.OpUpdate: webhooks.EventTaskUpdated,
+	tasks.OpShift:  webhooks.EventTaskUpdated,
+	tasks.OpDone:   webhooks.EventTaskDone,
+	tasks.OpDelete: webhooks.EventTaskDeleted,
+}
+
+// prepareBatchOp проверяет операцию пакета так же, как одиночные маршруты, и приводит
+// дату и правило повторения create и update к каноническому виду.
+func prepareBatchOp(format string, op *tasks.BatchOp, now time.Time) error {
+	if op.Op != tasks.OpCreate {
+		if _, err := strconv.ParseInt(op.Id, 10, 64); err != nil {
+			return errors.New("идентификатор задачи обязателен")
+		}
+	}
+
+	switch op.Op {
+	case tasks.OpCreate:
+		task := op.Task()
+		if err := importRepeat(format, &task); err != nil {
+			return errors.New(ruleErrorMessage(err, errRepeatFormat.Error()))
+		}
+		if err := tasks.ValidateAndSetDate(&task, now); err != nil {
+			return errors.New(ruleErrorMessage(err, "дата представлена в формате, отличном от 20060102"))
+		}
+		op.Date, op.Repeat = task.Date, task.Repeat
+
+	case tasks.OpUpdate:
+		if op.Title == "" {
+			return errors.New("поле 'title' обязательно для заполнения")
+		}
+		if _, err := time.Parse(tasks.TimeFormat, op.Date); err != nil {
+			return errors.New("дата представлена в формате, отличном от 20060102")
+		}
+		task := op.Task()
+		if err := importRepeat(format, &task); err != nil {
+			return errors.New(ruleErrorMessage(err, errRepeatFormat.Error()))
+		}
+		// Разбор правила повторения
+		rule, err := tasks.Parse(task.Repeat)
+		if err != nil {
+			return errors.New(ruleErrorMessage(err, "правило повторения указано в неправильном формате"))
+		}
+		op.Repeat = ""
+		if rule != nil {
+			op.Repeat = rule.String()
+		}
+
+	case tasks.OpDone:
+		op.Note = strings.TrimSpace(op.Note)
+
+	case tasks.OpDelete:
+
+	case tasks.OpShift:
+		if op.Days == 0 {
+			return errors.New("поле 'days' должно быть ненулевым числом дней")
+		}
+
+	default:
+		return fmt.Errorf("неизвестная операция %q, ожидается create, update, done, delete или shift", op.Op)
+	}
+
+	return nil
+}
+
+// Batch обработчик для маршрута POST /api/tasks/batch: операции {"ops": [...]} над задачами
+// выполняются по порядку в одной транзакции. Если хотя бы одна не прошла, не применяется ни одна,
+// а в results у неё указана причина.
+func (h *Handler) Batch(c *gin.Context) {
+	var request struct {
+		Ops []tasks.BatchOp `json:"ops"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("Batch ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return
+	}
+	if len(request.Ops) == 0 || len(request.Ops) > repository.MaxBatchOps {
+		h.app.Log.Debugf("Batch недопустимое число операций: %d", len(request.Ops))
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("в пакете должно быть от 1 до %d операций", repository.MaxBatchOps)})
+		return
+	}
+
+	// Сначала проверяем все операции, чтобы сообщить обо всех ошибках сразу
+	now := time.Now()
+	results := make([]tasks.BatchResult, len(request.Ops))
+	valid := true
+	for i := range request.Ops {
+		results[i].Op = request.Ops[i].Op
+		if err := prepareBatchOp(c.Query("repeat_format"), &request.Ops[i], now); err != nil {
+			results[i].Error = err.Error()
+			valid = false
+		}
+	}
+	if !valid {
+		h.app.Log.Debugf("Batch неверные операции: %v", results)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "пакет не выполнен: неверные операции", "results": results})
+		return
+	}
+
+	done, err := h.repo.Batch(currentUserID(c), request.Ops, now)
+	var batchErr *repository.BatchError
+	if errors.As(err, &batchErr) {
+		h.app.Log.Debugf("Batch repo.Batch: %v", err)
+		var (
+			conflict *repository.ConflictError
+			ruleErr  *tasks.RuleError
+			status   int
+		)
+		item := &results[batchErr.Index]
+		switch {
+		case errors.As(err, &conflict):
+			status, item.Error, item.Task = http.StatusConflict, repository.ErrVersionConflict.Error(), &conflict.Current
+		case errors.As(err, &ruleErr):
+			status, item.Error = http.StatusBadRequest, ruleErr.Error()
+		case errors.Is(err, repository.ErrTaskNotFound):
+			status, item.Error = http.StatusNotFound, "Задача не найдена"
+		default:
+			status, item.Error = http.StatusInternalServerError, "ошибка выполнения операции"
+		}
+		c.JSON(status, gin.H{"error": fmt.Sprintf("пакет не выполнен: ошибка в операции %d", batchErr.Index), "results": results})
+		return
+	}
+	if err != nil {
+		h.app.Log.Debugf("Batch repo.Batch: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка выполнения пакета"})
+		return
+	}
+
+	// События отправляем после фиксации пакета, по одному на операцию в её порядке
+	for _, result := range done {
+		h.emitTaskEvent(currentUserID(c), batchEvents[result.Op], result.Current, result.Completion)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": done})
+}
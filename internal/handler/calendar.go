@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"go_final_project_avp/internal/calendar"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/search"
+	"go_final_project_avp/internal/tasks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// feedTokenBytes длина случайного токена подписки в байтах.
+const feedTokenBytes = 32
+
+// hashToken хэш токена для хранения в БД, сам токен не сохраняется.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateFeed создаём подписку на календарь и выдаём её токен один раз.
+func (h *Handler) CreateFeed(c *gin.Context) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("CreateFeed ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return
+	}
+	if strings.TrimSpace(request.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "поле 'name' обязательно для заполнения"})
+		return
+	}
+
+	raw := make([]byte, feedTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		h.app.Log.Debugf("CreateFeed Ошибка при создании токена: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токена"})
+		return
+	}
+	token := hex.EncodeToString(raw)
+
+	feed := calendar.Feed{Name: request.Name, TokenHash: hashToken(token)}
+	if _, err := h.repo.CreateFeed(currentUserID(c), &feed); err != nil {
+		h.app.Log.Debugf("CreateFeed repo.CreateFeed ошибка добавления в бд: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка создания подписки"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":    feed.Id,
+		"token": token,
+		"url":   "/api/calendar.ics?token=" + token,
+	})
+}
+
+// GetFeeds список подписок на календарь без токенов.
+func (h *Handler) GetFeeds(c *gin.Context) {
+	feeds, err := h.repo.GetFeeds(currentUserID(c))
+	if err != nil {
+		h.app.Log.Debugf("GetFeeds repo.GetFeeds: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feeds": feeds})
+}
+
+// DeleteFeed отзываем подписку на календарь.
+func (h *Handler) DeleteFeed(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "идентификатор подписки обязателен"})
+		return
+	}
+
+	if err := h.repo.DeleteFeed(currentUserID(c), id); err != nil {
+		h.app.Log.Debugf("DeleteFeed repo.DeleteFeed: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Подписка не найдена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// GetCalendar обработчик для маршрута /api/calendar.ics, все задачи в формате iCalendar.
+// Доступ по токену подписки, так как календарные клиенты не умеют входить по паролю.
+func (h *Handler) GetCalendar(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен отсутствует"})
+		return
+	}
+
+	feed, err := h.repo.GetFeedByToken(hashToken(token))
+	if err != nil {
+		h.app.Log.Debugf("GetCalendar repo.GetFeedByToken: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный токен"})
+		return
+	}
+
+	component := calendar.ComponentEvent
+	switch strings.ToUpper(c.Query("component")) {
+	case "", calendar.ComponentEvent:
+	case calendar.ComponentTodo:
+		component = calendar.ComponentTodo
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "параметр 'component' должен быть VEVENT или VTODO"})
+		return
+	}
+
+	query, err := search.Parse(c.Query("search"))
+	if err != nil {
+		h.app.Log.Debugf("GetCalendar search.Parse: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var repoTasks []tasks.Task
+	if !query.IsEmpty() {
+		repoTasks, err = h.repo.GetSearch(feed.UserId, query, repository.Cursor{}, repository.NoLimit)
+	} else {
+		repoTasks, err = h.repo.GetTasks(feed.UserId, repository.Cursor{}, repository.NoLimit)
+	}
+	if err != nil {
+		h.app.Log.Debugf("GetCalendar repoTasks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", `inline; filename="calendar.ics"`)
+	c.Status(http.StatusOK)
+	if err = calendar.Render(c.Writer, repoTasks, component, time.Now()); err != nil {
+		h.app.Log.Debugf("GetCalendar calendar.Render: %v", err)
+	}
+}
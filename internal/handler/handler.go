@@ -4,8 +4,13 @@ import (
 	slogavp "github.com/Anatoly8853/slog-avp/v2"
 	"go_final_project_avp/internal/config"
 	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/search"
 	"go_final_project_avp/internal/tasks"
+	"go_final_project_avp/internal/webhooks"
 
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,11 +22,78 @@ import (
 type Handler struct {
 	config config.Config
 	app    *slogavp.Application
-	repo   *repository.Repository
+	repo   repository.Store
+	// now часы для проверки кодов второго фактора
+	now func() time.Time
 }
 
-func NewHandler(config config.Config, repo *repository.Repository, app *slogavp.Application) *Handler {
-	return &Handler{config: config, repo: repo, app: app}
+func NewHandler(config config.Config, repo repository.Store, app *slogavp.Application) *Handler {
+	return &Handler{config: config, repo: repo, app: app, now: time.Now}
+}
+
+// SetClock подменяет часы проверки кодов второго фактора, нужно тестам с фиксированным временем.
+func (h *Handler) SetClock(now func() time.Time) {
+	h.now = now
+}
+
+// ruleErrorMessage подробный текст ошибки разбора правила повторения или fallback для остальных ошибок.
+func ruleErrorMessage(err error, fallback string) string {
+	var ruleErr *tasks.RuleError
+	if errors.As(err, &ruleErr) {
+		return ruleErr.Error()
+	}
+	return fallback
+}
+
+// repeatFormatRRule формат правил повторения RFC 5545 для параметра repeat_format.
+const repeatFormatRRule = "rrule"
+
+var errRepeatFormat = errors.New("неподдерживаемый формат правила повторения, ожидается repeat_format=rrule")
+
+// importRepeat переводит правило повторения задачи из формата repeat_format во внутренний формат.
+func importRepeat(format string, task *tasks.Task) error {
+	switch format {
+	case "":
+		return nil
+	case repeatFormatRRule:
+		if task.Repeat == "" {
+			return nil
+		}
+		// Дата начала нужна для правил, зависящих от неё; некорректную дату отклонит валидация задачи
+		start := tasks.TruncateToDate(time.Now())
+		if date, err := time.Parse(tasks.TimeFormat, task.Date); err == nil {
+			start = date
+		}
+		rule, err := tasks.ParseRRule(task.Repeat, start)
+		if err != nil {
+			return err
+		}
+		task.Repeat = rule.String()
+		return nil
+	default:
+		return errRepeatFormat
+	}
+}
+
+// exportRepeat переводит правило повторения задачи во формат repeat_format.
+func exportRepeat(format string, task *tasks.Task) error {
+	switch format {
+	case "":
+		return nil
+	case repeatFormatRRule:
+		rule, err := tasks.Parse(task.Repeat)
+		if err != nil {
+			return err
+		}
+		date, err := time.Parse(tasks.TimeFormat, task.Date)
+		if err != nil {
+			return fmt.Errorf("некорректная дата задачи: %v", err)
+		}
+		task.Repeat, err = tasks.FormatRRule(rule, date)
+		return err
+	default:
+		return errRepeatFormat
+	}
 }
 
 // Index главная страница.
@@ -29,24 +101,121 @@ func Index(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.html", gin.H{})
 }
 
+// listErrorMessage текст ошибки списка задач: причина для неверного курсора, иначе fallback.
+func listErrorMessage(err error, fallback string) string {
+	if errors.Is(err, repository.ErrCursor) {
+		return err.Error()
+	}
+	return fallback
+}
+
+// listFilter фильтры списка задач из параметров from, to, overdue и repeating.
+type listFilter struct {
+	from, to string        // интервал дат, пустая строка не ограничивает
+	terms    []search.Term // те же фильтры условиями поиска
+}
+
+// parseListFilter разбирает фильтры списка задач, просроченными считаются задачи с датой раньше today.
+// Ошибка содержит текст для клиента.
+func parseListFilter(c *gin.Context, today string) (listFilter, error) {
+	var f listFilter
+
+	for _, p := range []struct {
+		name  string
+		value *string
+		op    search.Op
+	}{
+		{"from", &f.from, search.OpGe},
+		{"to", &f.to, search.OpLe},
+	} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse(tasks.TimeFormat, v); err != nil {
+			return listFilter{}, fmt.Errorf("Некорректная дата '%s', ожидается формат 20060102", p.name)
+		}
+		*p.value = v
+		f.terms = append(f.terms, search.Term{Field: search.FieldDate, Op: p.op, Value: v})
+	}
+	if f.from != "" && f.to != "" && f.to < f.from {
+		return listFilter{}, errors.New("Дата 'to' не может быть раньше даты 'from'")
+	}
+
+	if v := c.Query("overdue"); v != "" {
+		overdue, err := strconv.ParseBool(v)
+		if err != nil {
+			return listFilter{}, errors.New("параметр 'overdue' должен быть true или false")
+		}
+		// overdue=false — задачи на сегодня и позже
+		term := search.Term{Field: search.FieldDate, Op: search.OpLt, Value: today}
+		if !overdue {
+			term.Op = search.OpGe
+		}
+		f.terms = append(f.terms, term)
+	}
+
+	if v := c.Query("repeating"); v != "" {
+		repeating, err := strconv.ParseBool(v)
+		if err != nil {
+			return listFilter{}, errors.New("параметр 'repeating' должен быть true или false")
+		}
+		f.terms = append(f.terms, search.Term{Field: search.FieldHas, Op: search.OpEq, Value: string(search.FieldRepeat), Negate: !repeating})
+	}
+
+	return f, nil
+}
+
 // GetTasks данные главной страницы.
 func (h *Handler) GetTasks(c *gin.Context) {
-	search := c.Query("search")
+	query, err := search.Parse(c.Query("search"))
+	if err != nil {
+		h.app.Log.Debugf("GetTasks search.Parse: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := repository.DefaultLimit
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > repository.MaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("параметр 'limit' должен быть числом от 1 до %d", repository.MaxLimit)})
+			return
+		}
+		limit = n
+	}
 
-	repoTasks, err := h.repo.GetTasks()
+	after, err := repository.ParseCursor(c.Query("cursor"))
 	if err != nil {
-		h.app.Log.Debugf("GetTasks repoTasks: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ошибка вывода данных"})
+		h.app.Log.Debugf("GetTasks ParseCursor: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный параметр 'cursor'"})
+		return
+	}
+
+	today := time.Now().Format(tasks.TimeFormat)
+	filter, err := parseListFilter(c, today)
+	if err != nil {
+		h.app.Log.Debugf("GetTasks parseListFilter: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Фильтры списка — те же условия, что и в строке поиска
+	query.Terms = append(query.Terms, filter.terms...)
 
-	// Убираем все пробелы с начала и конца строки
-	trimmed := strings.TrimSpace(search)
-	if len(trimmed) > 0 {
-		repoTasks, err = h.repo.GetSearch(search)
+	// Запрашиваем на одну задачу больше, чтобы узнать, есть ли следующая страница
+	var repoTasks []tasks.Task
+	if !query.IsEmpty() {
+		repoTasks, err = h.repo.GetSearch(currentUserID(c), query, after, limit+1)
+		if err != nil {
+			h.app.Log.Debugf("GetTasks repoTasks: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": listErrorMessage(err, "ошибка поиска")})
+			return
+		}
+	} else {
+		repoTasks, err = h.repo.GetTasks(currentUserID(c), after, limit+1)
 		if err != nil {
 			h.app.Log.Debugf("GetTasks repoTasks: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ошибка поиска"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": listErrorMessage(err, "ошибка вывода данных")})
 			return
 		}
 	}
@@ -56,7 +225,29 @@ func (h *Handler) GetTasks(c *gin.Context) {
 		repoTasks = []tasks.Task{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"tasks": repoTasks})
+	response := gin.H{}
+	// next_cursor есть только если за страницей остались задачи
+	if len(repoTasks) > limit {
+		repoTasks = repoTasks[:limit]
+		next, err := repository.CursorAfter(repoTasks[limit-1])
+		if err != nil {
+			h.app.Log.Debugf("GetTasks CursorAfter: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+			return
+		}
+		response["next_cursor"] = next.String()
+	}
+	response["tasks"] = repoTasks
+
+	counts, err := h.repo.CountTasks(currentUserID(c), today, filter.from, filter.to)
+	if err != nil {
+		h.app.Log.Debugf("GetTasks repo.CountTasks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+	response["counts"] = counts
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GetNextDate обработчик для маршрута /api/nextdate правила повторения.
@@ -87,7 +278,7 @@ func (h *Handler) GetNextDate(c *gin.Context) {
 	nextDate, err := tasks.NextDate(nowDate, dateStr, repeat)
 	if err != nil {
 		h.app.Log.Debugf("GetNextDate tasks.NextDate правило повторения указано в неправильном формате: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "правило повторения указано в неправильном формате"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": ruleErrorMessage(err, "правило повторения указано в неправильном формате")})
 		return
 	}
 
@@ -100,6 +291,80 @@ func (h *Handler) GetNextDate(c *gin.Context) {
 	}
 }
 
+// GetOccurrences обработчик для маршрута /api/occurrences, все даты выполнения задачи в интервале.
+// Дат не больше tasks.MaxOccurrences, truncated в ответе показывает, что в интервале есть ещё.
+func (h *Handler) GetOccurrences(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		h.app.Log.Debug("GetOccurrences идентификатор задачи обязателен")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "идентификатор задачи обязателен"})
+		return
+	}
+
+	from, err := time.Parse(tasks.TimeFormat, c.Query("from"))
+	if err != nil {
+		h.app.Log.Debugf("GetOccurrences Некорректная дата 'from', ожидается формат 20060102: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректная дата 'from', ожидается формат 20060102"})
+		return
+	}
+
+	to, err := time.Parse(tasks.TimeFormat, c.Query("to"))
+	if err != nil {
+		h.app.Log.Debugf("GetOccurrences Некорректная дата 'to', ожидается формат 20060102: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректная дата 'to', ожидается формат 20060102"})
+		return
+	}
+
+	if to.Before(from) {
+		h.app.Log.Debug("GetOccurrences дата 'to' раньше даты 'from'")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Дата 'to' не может быть раньше даты 'from'"})
+		return
+	}
+
+	task, err := h.repo.GetTasksId(currentUserID(c), id)
+	if err != nil {
+		h.app.Log.Debugf("GetOccurrences repo.GetTasksId: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	start, err := time.Parse(tasks.TimeFormat, task.Date)
+	if err != nil {
+		h.app.Log.Debugf("GetOccurrences некорректная дата задачи: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "некорректная дата задачи"})
+		return
+	}
+
+	rule, err := tasks.Parse(task.Repeat)
+	if err != nil {
+		h.app.Log.Debugf("GetOccurrences tasks.Parse правило повторения указано в неправильном формате: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": ruleErrorMessage(err, "правило повторения указано в неправильном формате")})
+		return
+	}
+
+	dates, err := tasks.Occurrences(start, rule, from, to)
+	if err != nil {
+		h.app.Log.Debugf("GetOccurrences tasks.Occurrences: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Дата 'to' не может быть раньше даты 'from'"})
+		return
+	}
+
+	// Список обрезан на MaxOccurrences датах, если после последней в интервале есть ещё
+	truncated := false
+	if len(dates) == tasks.MaxOccurrences {
+		rest, err := tasks.Occurrences(start, rule, dates[len(dates)-1].AddDate(0, 0, 1), to)
+		truncated = err == nil && len(rest) > 0
+	}
+
+	// Возвращаем пустой слайс, если в интервале нет дат
+	result := make([]string, 0, len(dates))
+	for _, date := range dates {
+		result = append(result, date.Format(tasks.TimeFormat))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": task.Id, "dates": result, "truncated": truncated})
+}
+
 // CreateTask добавляем задачи.
 func (h *Handler) CreateTask(c *gin.Context) {
 	var newTask *tasks.Task
@@ -111,19 +376,30 @@ func (h *Handler) CreateTask(c *gin.Context) {
 		return
 	}
 
+	// Перевод правила повторения из формата repeat_format
+	if err := importRepeat(c.Query("repeat_format"), newTask); err != nil {
+		h.app.Log.Debugf("CreateTask importRepeat: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": ruleErrorMessage(err, errRepeatFormat.Error())})
+		return
+	}
+
 	// Валидация даты
 	if err := tasks.ValidateAndSetDate(newTask, time.Now()); err != nil {
 		h.app.Log.Debugf("CreateTask дата представлена в формате, отличном от 20060102: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "дата представлена в формате, отличном от 20060102"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": ruleErrorMessage(err, "дата представлена в формате, отличном от 20060102")})
 		return
 	}
 
-	id, err := h.repo.CreateTask(newTask)
+	id, err := h.repo.CreateTask(currentUserID(c), newTask)
 	if err != nil {
 		h.app.Log.Debugf("CreateTask repo.CreateTask ошибка добавления в бд: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не указан заголовок задачи"})
+		return
 	}
 
+	newTask.Id, newTask.Version = strconv.FormatInt(id, 10), 1
+	h.emitTaskEvent(currentUserID(c), webhooks.EventTaskCreated, newTask, nil)
+
 	// Ответ в формате JSON
 	c.JSON(http.StatusOK, gin.H{"id": strconv.Itoa(int(id))})
 
@@ -138,13 +414,20 @@ func (h *Handler) GetTasksId(c *gin.Context) {
 		return
 	}
 
-	repoTasks, err := h.repo.GetTasksId(id)
+	repoTasks, err := h.repo.GetTasksId(currentUserID(c), id)
 	if err != nil {
 		h.app.Log.Debugf("GetTasks repoTasks: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "нет задачи с таким id"})
 		return
 	}
 
+	// Перевод правила повторения в формат repeat_format
+	if err = exportRepeat(c.Query("repeat_format"), &repoTasks); err != nil {
+		h.app.Log.Debugf("GetTasksId exportRepeat: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": ruleErrorMessage(err, errRepeatFormat.Error())})
+		return
+	}
+
 	c.JSON(http.StatusOK, repoTasks)
 }
 
@@ -165,29 +448,46 @@ func (h *Handler) UpdateTask(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "дата представлена в формате, отличном от 20060102"})
 		return
 	}
-	// Сбрасываем время
-	nowDate := tasks.TruncateToDate(time.Now())
 
-	// Вызов функции NextDate для вычисления следующей даты
-	_, err = tasks.NextDate(nowDate, newTask.Date, newTask.Repeat)
+	// Перевод правила повторения из формата repeat_format
+	if err = importRepeat(c.Query("repeat_format"), newTask); err != nil {
+		h.app.Log.Debugf("UpdateTask importRepeat: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": ruleErrorMessage(err, errRepeatFormat.Error())})
+		return
+	}
+
+	// Разбор правила повторения
+	rule, err := tasks.Parse(newTask.Repeat)
 	if err != nil {
-		h.app.Log.Debugf("UpdateTask tasks.NextDate правило повторения указано в неправильном формате: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "правило повторения указано в неправильном формате"})
+		h.app.Log.Debugf("UpdateTask tasks.Parse правило повторения указано в неправильном формате: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": ruleErrorMessage(err, "правило повторения указано в неправильном формате")})
 		return
 	}
+	if rule != nil {
+		newTask.Repeat = rule.String()
+	}
 
-	err = h.repo.UpdateTask(newTask)
+	err = h.repo.UpdateTask(currentUserID(c), newTask)
+	var conflict *repository.ConflictError
+	if errors.As(err, &conflict) {
+		h.app.Log.Debugf("UpdateTask repo.UpdateTask: %v", err)
+		c.JSON(http.StatusConflict, gin.H{"error": repository.ErrVersionConflict.Error(), "task": conflict.Current})
+		return
+	}
 	if err != nil {
 		h.app.Log.Debugf("UpdateTask repo.UpdateTask ошибка добавления в бд: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "задача не найдена"})
 		return
 	}
+	h.emitTaskEvent(currentUserID(c), webhooks.EventTaskUpdated, newTask, nil)
 
 	// Ответ в формате JSON
 	c.JSON(http.StatusOK, gin.H{})
 }
 
-// DoneTask обновляем данные задачи.
+// DoneTask отметка о выполнении задачи: разовая задача уходит в корзину, повторяющаяся переносится на следующую дату.
+// Выполнение записывается в историю, в теле запроса можно передать заметку {"note": "..."}.
+// Параметр version включает проверку версии: если задачу уже изменили, ответ 409 с текущей задачей.
 func (h *Handler) DoneTask(c *gin.Context) {
 	id := c.Query("id")
 	if id == "" {
@@ -196,45 +496,111 @@ func (h *Handler) DoneTask(c *gin.Context) {
 		return
 	}
 
-	newTask, err := h.repo.GetTasksId(id)
+	version, err := parseVersion(c.Query("version"))
 	if err != nil {
-		h.app.Log.Debugf("DoneTask repo.GetTasksId: %v", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		h.app.Log.Debugf("DoneTask parseVersion: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if newTask.Repeat == "" {
-		err = h.repo.DeleteTask(newTask.Id)
-		if err != nil {
-			h.app.Log.Debugf("DoneTask repoTasks: %v", err)
+	// Тело запроса необязательно
+	var request struct {
+		Note string `json:"note"`
+	}
+	if err = c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		h.app.Log.Debugf("DoneTask ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return
+	}
+
+	// Чтение задачи, вычисление следующей даты и запись идут в одной транзакции
+	completion, err := h.repo.DoneTask(currentUserID(c), id, version, strings.TrimSpace(request.Note), time.Now())
+	if err != nil {
+		h.app.Log.Debugf("DoneTask repo.DoneTask: %v", err)
+		var (
+			conflict *repository.ConflictError
+			ruleErr  *tasks.RuleError
+		)
+		switch {
+		case errors.As(err, &conflict):
+			c.JSON(http.StatusConflict, gin.H{"error": repository.ErrVersionConflict.Error(), "task": conflict.Current})
+		case errors.As(err, &ruleErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": ruleErr.Error()})
+		default:
 			c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
-			return
 		}
-		// Ответ в формате JSON
-		c.JSON(http.StatusOK, gin.H{})
 		return
 	}
 
-	// Сбрасываем время
-	nowDate := tasks.TruncateToDate(time.Now())
+	// Повторяющаяся задача в событии с новой датой, разовая после выполнения в корзине и в событие не попадает
+	var task *tasks.Task
+	if next, err := h.repo.GetTasksId(currentUserID(c), id); err == nil {
+		task = &next
+	}
+	h.emitTaskEvent(currentUserID(c), webhooks.EventTaskDone, task, &completion)
 
-	// Вызов функции NextDate для вычисления следующей даты
-	nextDate, err := tasks.NextDate(nowDate, newTask.Date, newTask.Repeat)
-	if err != nil {
-		h.app.Log.Debugf("UpdateTask tasks.NextDate правило повторения указано в неправильном формате: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "правило повторения указано в неправильном формате"})
+	// Ответ в формате JSON
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// parseVersion версия задачи из параметра запроса, пустая строка — без проверки версии.
+func parseVersion(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || version < 1 {
+		return 0, errors.New("параметр 'version' должен быть положительным числом")
+	}
+	return version, nil
+}
+
+// GetTaskHistory обработчик для маршрута /api/task/history, история выполнения задач.
+// id выбирает одну задачу, from и to — интервал дат выполнения включительно, все параметры необязательны.
+func (h *Handler) GetTaskHistory(c *gin.Context) {
+	id := c.Query("id")
+	if id != "" {
+		if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+			h.app.Log.Debugf("GetTaskHistory некорректный id: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный идентификатор задачи"})
+			return
+		}
+	}
+
+	// Даты интервала — дни по местному времени сервера, to включается целиком
+	var from, to time.Time
+	if v := c.Query("from"); v != "" {
+		date, err := time.ParseInLocation(tasks.TimeFormat, v, time.Local)
+		if err != nil {
+			h.app.Log.Debugf("GetTaskHistory Некорректная дата 'from', ожидается формат 20060102: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректная дата 'from', ожидается формат 20060102"})
+			return
+		}
+		from = date
+	}
+	if v := c.Query("to"); v != "" {
+		date, err := time.ParseInLocation(tasks.TimeFormat, v, time.Local)
+		if err != nil {
+			h.app.Log.Debugf("GetTaskHistory Некорректная дата 'to', ожидается формат 20060102: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректная дата 'to', ожидается формат 20060102"})
+			return
+		}
+		to = date.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		h.app.Log.Debug("GetTaskHistory дата 'to' раньше даты 'from'")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Дата 'to' не может быть раньше даты 'from'"})
 		return
 	}
 
-	err = h.repo.DoneTask(nextDate, newTask.Id)
+	completions, err := h.repo.GetCompletions(currentUserID(c), id, from, to)
 	if err != nil {
-		h.app.Log.Debugf("UpdateTask repo.UpdateTask ошибка добавления в бд: %v", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		h.app.Log.Debugf("GetTaskHistory repo.GetCompletions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
 		return
 	}
 
-	// Ответ в формате JSON
-	c.JSON(http.StatusOK, gin.H{})
+	c.JSON(http.StatusOK, gin.H{"completions": completions})
 }
 
 func (h *Handler) DeleteTask(c *gin.Context) {
@@ -245,12 +611,74 @@ func (h *Handler) DeleteTask(c *gin.Context) {
 		return
 	}
 
-	err := h.repo.DeleteTask(id)
+	// Задачу для события читаем до удаления
+	task, err := h.repo.GetTasksId(currentUserID(c), id)
+	if err != nil {
+		task = tasks.Task{Id: id}
+	}
+
+	err = h.repo.DeleteTask(currentUserID(c), id)
 	if err != nil {
 		h.app.Log.Debugf("DeleteTask repoTasks: %v", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
 		return
 	}
+	h.emitTaskEvent(currentUserID(c), webhooks.EventTaskDeleted, &task, nil)
 	// Ответ в формате JSON
 	c.JSON(http.StatusOK, gin.H{})
 }
+
+// GetTrash обработчик для маршрута /api/trash, задачи в корзине.
+func (h *Handler) GetTrash(c *gin.Context) {
+	list, err := h.repo.GetTrash(currentUserID(c))
+	if err != nil {
+		h.app.Log.Debugf("GetTrash repo.GetTrash: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tasks": list})
+}
+
+// RestoreTask обработчик для маршрута /api/task/restore: возвращает задачу из корзины
+// или отменяет последнее выполнение повторяющейся задачи. Параметр version включает проверку версии.
+func (h *Handler) RestoreTask(c *gin.Context) {
+	id := c.Query("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		h.app.Log.Debugf("RestoreTask некорректный id: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "идентификатор задачи обязателен"})
+		return
+	}
+
+	version, err := parseVersion(c.Query("version"))
+	if err != nil {
+		h.app.Log.Debugf("RestoreTask parseVersion: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.repo.RestoreTask(currentUserID(c), id, version)
+	var conflict *repository.ConflictError
+	if errors.As(err, &conflict) {
+		h.app.Log.Debugf("RestoreTask repo.RestoreTask: %v", err)
+		c.JSON(http.StatusConflict, gin.H{"error": repository.ErrVersionConflict.Error(), "task": conflict.Current})
+		return
+	}
+	if errors.Is(err, repository.ErrNotInTrash) {
+		h.app.Log.Debugf("RestoreTask repo.RestoreTask: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.app.Log.Debugf("RestoreTask repo.RestoreTask: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	// Восстановленная задача снова в списке, для подписчиков это изменение задачи
+	if task, err := h.repo.GetTasksId(currentUserID(c), id); err == nil {
+		h.emitTaskEvent(currentUserID(c), webhooks.EventTaskUpdated, &task, nil)
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"go_final_project_avp/internal/notify"
+	"go_final_project_avp/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifyTargets адреса, на которые пользователь получает напоминания о своих задачах.
+func (h *Handler) GetNotifyTargets(c *gin.Context) {
+	targets, err := h.repo.GetNotifyTargets(currentUserID(c))
+	if err != nil {
+		h.app.Log.Debugf("GetNotifyTargets repo.GetNotifyTargets: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, targets)
+}
+
+// SetNotifyTargets сохраняем адреса пользователя для напоминаний. Пустой адрес выключает канал,
+// адрес веб-хука проверяется так же, как адрес подписки на события задач.
+func (h *Handler) SetNotifyTargets(c *gin.Context) {
+	var targets notify.Targets
+	if err := c.ShouldBindJSON(&targets); err != nil {
+		h.app.Log.Debugf("SetNotifyTargets ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return
+	}
+	if err := targets.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if targets.WebhookURL != "" {
+		if err := webhooks.ValidateURL(c.Request.Context(), targets.WebhookURL, h.config.HooksAllowPrivate); err != nil {
+			h.app.Log.Debugf("SetNotifyTargets ValidateURL: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.repo.SetNotifyTargets(currentUserID(c), &targets, time.Now()); err != nil {
+		h.app.Log.Debugf("SetNotifyTargets repo.SetNotifyTargets: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка сохранения адресов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, targets)
+}
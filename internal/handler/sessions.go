@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/users"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Время жизни токенов, если оно не задано в настройках.
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// Куки с токенами. Токен обновления уходит только в /api, скрипты страницы его не видят.
+const (
+	accessCookie      = "token"
+	refreshCookie     = "refresh_token"
+	refreshCookiePath = "/api"
+)
+
+// sessionClaim claim токена доступа с id сессии.
+const sessionClaim = "sid"
+
+// roleClaim claim токена доступа с ролью пользователя.
+const roleClaim = "role"
+
+// sessionIDKey ключ контекста gin с id сессии, проверенной AuthMiddleware.
+const sessionIDKey = "session_id"
+
+// refreshTokenBytes длина случайного токена обновления в байтах.
+const refreshTokenBytes = 32
+
+// errSessionEnded сессия отозвана, истекла или её токен обновления использован повторно.
+var errSessionEnded = errors.New("сессия завершена")
+
+// errCredentialChanged пароль пользователя сменился после входа.
+var errCredentialChanged = errors.New("учётные данные изменились")
+
+// Ответы 401 о завершённой сессии.
+const (
+	sessionEndedMessage      = "Сессия завершена, войдите заново"
+	credentialChangedMessage = "Пароль изменён, войдите заново"
+)
+
+// accessTTL время жизни токена доступа из TODO_ACCESS_TTL.
+func (h *Handler) accessTTL() time.Duration {
+	if h.config.AccessTTL > 0 {
+		return h.config.AccessTTL
+	}
+	return DefaultAccessTTL
+}
+
+// refreshTTL время жизни сессии из TODO_REFRESH_TTL, каждое обновление продлевает её на этот срок.
+func (h *Handler) refreshTTL() time.Duration {
+	if h.config.RefreshTTL > 0 {
+		return h.config.RefreshTTL
+	}
+	return DefaultRefreshTTL
+}
+
+// newRefreshToken случайный токен обновления.
+func newRefreshToken() (string, error) {
+	raw := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("ошибка создания токена обновления: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// accessToken JWT-токен доступа с id пользователя, меткой его учётных данных и id сессии.
+func (h *Handler) accessToken(user users.User, sessionID int64, now time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id":       user.Id,
+		credentialClaim: h.credentialVersion(user),
+		sessionClaim:    sessionID,
+		roleClaim:       user.Role,
+		"iat":           now.Unix(),
+		"exp":           now.Add(h.accessTTL()).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.config.JwtSecret))
+}
+
+// setAccessCookie кука с токеном доступа живёт столько же, сколько сам токен.
+func (h *Handler) setAccessCookie(c *gin.Context, token string) {
+	c.SetCookie(accessCookie, token, int(h.accessTTL().Seconds()), "/", "", false, true)
+}
+
+// setRefreshCookie кука с токеном обновления, не уходит на чужие сайты.
+func (h *Handler) setRefreshCookie(c *gin.Context, token string) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(refreshCookie, token, int(h.refreshTTL().Seconds()), refreshCookiePath, "", false, true)
+}
+
+// clearAuthCookies удаляем куки с токенами после выхода.
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie(accessCookie, "", -1, "/", "", false, true)
+	c.SetCookie(refreshCookie, "", -1, refreshCookiePath, "", false, true)
+}
+
+// issueTokens выдаём пару токенов сессии: в куках для веб-интерфейса и в JSON для остальных клиентов.
+func (h *Handler) issueTokens(c *gin.Context, user users.User, sessionID int64, refresh string, now time.Time) {
+	access, err := h.accessToken(user, sessionID, now)
+	if err != nil {
+		h.app.Log.Debugf("issueTokens Ошибка при создании токена: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токена"})
+		return
+	}
+
+	h.setAccessCookie(c, access)
+	h.setRefreshCookie(c, refresh)
+	c.JSON(http.StatusOK, gin.H{
+		"token":         access,
+		"refresh_token": refresh,
+		"expires_in":    int(h.accessTTL().Seconds()),
+		"role":          user.Role,
+	})
+}
+
+// startSession начинаем сессию после проверки пароля и выдаём её токены.
+func (h *Handler) startSession(c *gin.Context, user users.User) {
+	refresh, err := newRefreshToken()
+	if err != nil {
+		h.app.Log.Debugf("startSession newRefreshToken: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токена"})
+		return
+	}
+
+	now := time.Now()
+	session := users.Session{UserId: user.Id, TokenHash: hashToken(refresh), Credential: h.credentialVersion(user)}
+	if _, err = h.repo.CreateSession(&session, now, now.Add(h.refreshTTL())); err != nil {
+		h.app.Log.Debugf("startSession repo.CreateSession: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании сессии"})
+		return
+	}
+
+	h.issueTokens(c, user, session.Id, refresh, now)
+}
+
+// sessionByRefresh действующая сессия по токену обновления и её пользователь.
+// Предыдущий, уже заменённый токен означает, что его кто-то сохранил: сессия отзывается.
+func (h *Handler) sessionByRefresh(refresh string, now time.Time) (users.Session, users.User, error) {
+	tokenHash := hashToken(refresh)
+	session, err := h.repo.GetSessionByToken(tokenHash)
+	if err != nil {
+		return session, users.User{}, err
+	}
+	if !session.Active(now) {
+		return session, users.User{}, errSessionEnded
+	}
+	if session.TokenHash != tokenHash {
+		h.app.Log.Warnf("Повторное использование токена обновления сессии %d, сессия отозвана", session.Id)
+		if err = h.repo.RevokeSession(session.UserId, session.Id, now); err != nil {
+			h.app.Log.Errorf("Не удалось отозвать сессию %d: %v", session.Id, err)
+		}
+		return session, users.User{}, errSessionEnded
+	}
+
+	user, err := h.repo.GetUserById(session.UserId)
+	if err != nil {
+		return session, user, fmt.Errorf("%w: %v", errSessionEnded, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(session.Credential), []byte(h.credentialVersion(user))) != 1 {
+		if err = h.repo.RevokeSession(user.Id, session.Id, now); err != nil {
+			h.app.Log.Errorf("Не удалось отозвать сессию %d: %v", session.Id, err)
+		}
+		return session, user, errCredentialChanged
+	}
+
+	return session, user, nil
+}
+
+// sessionErrorMessage текст ответа 401 о том, почему сессия не подходит.
+func sessionErrorMessage(err error) string {
+	if errors.Is(err, errCredentialChanged) {
+		return credentialChangedMessage
+	}
+	return sessionEndedMessage
+}
+
+// Refresh обмен токена обновления на новую пару токенов. Старый токен обновления больше не действует.
+func (h *Handler) Refresh(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// Тело необязательно: веб-интерфейс передаёт токен в куке
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		h.app.Log.Debugf("Refresh неверный формат запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+	refresh := request.RefreshToken
+	if refresh == "" {
+		refresh, _ = c.Cookie(refreshCookie)
+	}
+	if refresh == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен обновления отсутствует"})
+		return
+	}
+
+	now := time.Now()
+	session, user, err := h.sessionByRefresh(refresh, now)
+	if err == nil {
+		next, tokenErr := newRefreshToken()
+		if tokenErr != nil {
+			h.app.Log.Debugf("Refresh newRefreshToken: %v", tokenErr)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токена"})
+			return
+		}
+		err = h.repo.RotateSession(session.Id, session.TokenHash, hashToken(next), now, now.Add(h.refreshTTL()))
+		if err == nil {
+			h.issueTokens(c, user, session.Id, next, now)
+			return
+		}
+		// Тот же токен только что обменял параллельный запрос: считаем его повторным использованием
+		if errors.Is(err, repository.ErrSessionRotated) {
+			h.app.Log.Warnf("Повторное использование токена обновления сессии %d, сессия отозвана", session.Id)
+			if revokeErr := h.repo.RevokeSession(session.UserId, session.Id, now); revokeErr != nil {
+				h.app.Log.Errorf("Не удалось отозвать сессию %d: %v", session.Id, revokeErr)
+			}
+		}
+	}
+
+	h.app.Log.Debugf("Refresh сессия не обновлена: %v", err)
+	switch {
+	case errors.Is(err, repository.ErrSessionNotFound):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный токен обновления"})
+	case errors.Is(err, repository.ErrSessionRotated), errors.Is(err, errSessionEnded), errors.Is(err, errCredentialChanged):
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": sessionErrorMessage(err)})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления сессии"})
+	}
+}
+
+// renewAccess новый токен доступа по куке с токеном обновления, когда кука с токеном доступа истекла.
+// Токен обновления при этом не меняется: параллельные запросы страницы не должны отзывать сессию.
+func (h *Handler) renewAccess(c *gin.Context, now time.Time) (users.Session, users.User, error) {
+	refresh, err := c.Cookie(refreshCookie)
+	if err != nil || refresh == "" {
+		return users.Session{}, users.User{}, errors.New("токен обновления отсутствует")
+	}
+	session, user, err := h.sessionByRefresh(refresh, now)
+	if err != nil {
+		return session, user, err
+	}
+
+	access, err := h.accessToken(user, session.Id, now)
+	if err != nil {
+		return session, user, fmt.Errorf("ошибка при создании токена: %w", err)
+	}
+	h.setAccessCookie(c, access)
+
+	return session, user, nil
+}
+
+// currentSessionID id сессии, проверенной AuthMiddleware.
+func currentSessionID(c *gin.Context) int64 {
+	return c.GetInt64(sessionIDKey)
+}
+
+// GetSessions действующие сессии пользователя, current — сессия этого запроса.
+func (h *Handler) GetSessions(c *gin.Context) {
+	list, err := h.repo.GetSessions(currentUserID(c), time.Now())
+	if err != nil {
+		h.app.Log.Debugf("GetSessions repo.GetSessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": list, "current": strconv.FormatInt(currentSessionID(c), 10)})
+}
+
+// Logout завершаем сессию этого запроса, её токен обновления больше не действует.
+func (h *Handler) Logout(c *gin.Context) {
+	if err := h.repo.RevokeSession(currentUserID(c), currentSessionID(c), time.Now()); err != nil {
+		h.app.Log.Debugf("Logout repo.RevokeSession: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения сессии"})
+		return
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// LogoutAll завершаем все сессии пользователя на всех устройствах, включая текущую.
+func (h *Handler) LogoutAll(c *gin.Context) {
+	count, err := h.repo.RevokeUserSessions(currentUserID(c), time.Now())
+	if err != nil {
+		h.app.Log.Debugf("LogoutAll repo.RevokeUserSessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения сессий"})
+		return
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"revoked": count})
+}
@@ -0,0 +1,363 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/users"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Второй шаг входа: токен между проверкой пароля и кода и защита от подбора кода.
+const (
+	// purposeClaim claim токенов, которые не являются токенами доступа
+	purposeClaim = "purpose"
+	// purposeMFA назначение токена второго шага входа
+	purposeMFA = "2fa"
+	// mfaTokenTTL сколько действует токен второго шага
+	mfaTokenTTL = 5 * time.Minute
+	// totpMaxFailures после стольких неверных кодов подряд проверка блокируется на totpLockout
+	totpMaxFailures = 5
+	totpLockout     = 5 * time.Minute
+)
+
+// DefaultTOTPIssuer название сервиса в приложении-аутентификаторе, если оно не задано в настройках.
+const DefaultTOTPIssuer = "Планировщик задач"
+
+// errTOTPInvalid код не подошёл.
+var errTOTPInvalid = errors.New("неверный код")
+
+// errTOTPLocked проверка кодов заблокирована после серии ошибок.
+var errTOTPLocked = errors.New("проверка кодов заблокирована")
+
+// Ответы о неверном коде второго фактора.
+const (
+	totpInvalidMessage = "Неверный код"
+	totpLockedMessage  = "Слишком много неверных кодов, попробуйте через несколько минут"
+)
+
+// totpIssuer название сервиса из TODO_TOTP_ISSUER.
+func (h *Handler) totpIssuer() string {
+	if h.config.TOTPIssuer != "" {
+		return h.config.TOTPIssuer
+	}
+	return DefaultTOTPIssuer
+}
+
+// mfaToken токен второго шага входа: пароль пользователя проверен, осталось ввести код.
+// Доступа к API он не даёт, AuthMiddleware его отклоняет.
+func (h *Handler) mfaToken(user users.User, now time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id":       user.Id,
+		credentialClaim: h.credentialVersion(user),
+		purposeClaim:    purposeMFA,
+		"iat":           now.Unix(),
+		"exp":           now.Add(mfaTokenTTL).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.config.JwtSecret))
+}
+
+// userByMFAToken пользователь из действующего токена второго шага. Пароль не должен смениться после его выдачи.
+func (h *Handler) userByMFAToken(tokenString string) (users.User, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("неправильный метод подписи: %v", token.Header["alg"])
+		}
+		return []byte(h.config.JwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return users.User{}, fmt.Errorf("неверный токен: %w", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims[purposeClaim] != purposeMFA {
+		return users.User{}, errors.New("токен не для второго шага входа")
+	}
+	id, ok := claims["user_id"].(float64)
+	if !ok {
+		return users.User{}, errors.New("отсутствует id пользователя в токене")
+	}
+	user, err := h.repo.GetUserById(int64(id))
+	if err != nil {
+		return user, err
+	}
+	credential, _ := claims[credentialClaim].(string)
+	if subtle.ConstantTimeCompare([]byte(credential), []byte(h.credentialVersion(user))) != 1 {
+		return user, errCredentialChanged
+	}
+	return user, nil
+}
+
+// enabledTOTP подключённый второй фактор пользователя, ok — он требуется при входе.
+func (h *Handler) enabledTOTP(userID int64) (users.TOTP, bool, error) {
+	totp, err := h.repo.GetTOTP(userID)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return totp, false, nil
+	}
+	if err != nil {
+		return totp, false, err
+	}
+	return totp, totp.Enabled(), nil
+}
+
+// checkSecondFactor проверка кода из приложения или кода восстановления.
+// Принятый код второй раз не подходит, после totpMaxFailures ошибок подряд проверка блокируется.
+func (h *Handler) checkSecondFactor(totp users.TOTP, code string) error {
+	now := h.now()
+	if totp.Locked(now) {
+		return errTOTPLocked
+	}
+
+	var err error
+	if users.IsTOTPCode(code) {
+		err = errTOTPInvalid
+		if step, ok := users.VerifyTOTP(totp.Secret, code, now, totp.LastStep); ok {
+			err = h.repo.AcceptTOTPStep(totp.UserId, step)
+		}
+	} else {
+		err = h.repo.UseRecoveryCode(totp.UserId, hashToken(users.NormalizeRecoveryCode(code)), now)
+	}
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, errTOTPInvalid) && !errors.Is(err, repository.ErrTOTPReplay) &&
+		!errors.Is(err, repository.ErrRecoveryCodeInvalid) {
+		return err
+	}
+
+	locked, failErr := h.repo.RecordTOTPFailure(totp.UserId, totpMaxFailures, totpLockout, now)
+	if failErr != nil {
+		return failErr
+	}
+	if locked {
+		h.app.Log.Warnf("Проверка кодов второго фактора пользователя %d заблокирована после %d ошибок", totp.UserId, totpMaxFailures)
+		return errTOTPLocked
+	}
+	return errTOTPInvalid
+}
+
+// secondFactorError ответ на код, который не прошёл проверку, invalidStatus — статус неверного кода.
+func (h *Handler) secondFactorError(c *gin.Context, err error, invalidStatus int) {
+	switch {
+	case errors.Is(err, errTOTPLocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": totpLockedMessage})
+	case errors.Is(err, errTOTPInvalid):
+		c.JSON(invalidStatus, gin.H{"error": totpInvalidMessage})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки кода"})
+	}
+}
+
+// newRecoveryCodes новые коды восстановления и их хэши для БД.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := users.NewRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, hashToken(users.NormalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// SignInSecondFactor второй шаг входа: токен из ответа POST /api/signin и код из приложения или код восстановления.
+func (h *Handler) SignInSecondFactor(c *gin.Context) {
+	var request struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("SignInSecondFactor неверный формат запроса: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+
+	user, err := h.userByMFAToken(request.MFAToken)
+	if err != nil {
+		h.app.Log.Debugf("SignInSecondFactor userByMFAToken: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Вход не начат или устарел, введите пароль заново"})
+		return
+	}
+	totp, enabled, err := h.enabledTOTP(user.Id)
+	if err != nil {
+		h.app.Log.Debugf("SignInSecondFactor enabledTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки кода"})
+		return
+	}
+	// Второй фактор отключили после ввода пароля
+	if !enabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Вход не начат или устарел, введите пароль заново"})
+		return
+	}
+
+	if err = h.checkSecondFactor(totp, request.Code); err != nil {
+		h.app.Log.Debugf("SignInSecondFactor пользователь %s: %v", user.Login, err)
+		h.secondFactorError(c, err, http.StatusUnauthorized)
+		return
+	}
+
+	h.startSession(c, user)
+}
+
+// GetTOTP состояние второго фактора пользователя.
+func (h *Handler) GetTOTP(c *gin.Context) {
+	userID := currentUserID(c)
+	_, enabled, err := h.enabledTOTP(userID)
+	if err != nil {
+		h.app.Log.Debugf("GetTOTP enabledTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+	left := 0
+	if enabled {
+		if left, err = h.repo.CountRecoveryCodes(userID); err != nil {
+			h.app.Log.Debugf("GetTOTP repo.CountRecoveryCodes: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "recovery_codes_left": left})
+}
+
+// SetupTOTP начинаем подключение второго фактора: новый секрет и URI для QR-кода.
+// Вход с кодом требуется только после подтверждения в EnableTOTP.
+func (h *Handler) SetupTOTP(c *gin.Context) {
+	user, err := h.repo.GetUserById(currentUserID(c))
+	if err != nil {
+		h.app.Log.Debugf("SetupTOTP repo.GetUserById: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения второго фактора"})
+		return
+	}
+	_, enabled, err := h.enabledTOTP(user.Id)
+	if err != nil {
+		h.app.Log.Debugf("SetupTOTP enabledTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения второго фактора"})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Второй фактор уже подключён, сначала отключите его"})
+		return
+	}
+
+	secret, err := users.NewTOTPSecret()
+	if err == nil {
+		err = h.repo.SaveTOTPSecret(user.Id, secret, h.now())
+	}
+	if err != nil {
+		h.app.Log.Debugf("SetupTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения второго фактора"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "uri": users.TOTPURI(h.totpIssuer(), user.Login, secret)})
+}
+
+// EnableTOTP завершаем подключение кодом из приложения и выдаём коды восстановления, они есть только в этом ответе.
+func (h *Handler) EnableTOTP(c *gin.Context) {
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("EnableTOTP ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return
+	}
+
+	userID := currentUserID(c)
+	totp, err := h.repo.GetTOTP(userID)
+	if errors.Is(err, repository.ErrTOTPNotFound) || (err == nil && totp.Enabled()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Сначала получите новый секрет в POST /api/2fa/setup"})
+		return
+	}
+	if err != nil {
+		h.app.Log.Debugf("EnableTOTP repo.GetTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения второго фактора"})
+		return
+	}
+
+	now := h.now()
+	step, ok := users.VerifyTOTP(totp.Secret, request.Code, now, totp.LastStep)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": totpInvalidMessage})
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = h.repo.EnableTOTP(userID, step, hashes, now)
+	}
+	if err != nil {
+		h.app.Log.Debugf("EnableTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения второго фактора"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// requireSecondFactor проверяем код подключённого второго фактора перед его изменением.
+func (h *Handler) requireSecondFactor(c *gin.Context) bool {
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("requireSecondFactor ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return false
+	}
+
+	totp, enabled, err := h.enabledTOTP(currentUserID(c))
+	if err != nil {
+		h.app.Log.Debugf("requireSecondFactor enabledTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки кода"})
+		return false
+	}
+	if !enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Второй фактор не подключён"})
+		return false
+	}
+	if err = h.checkSecondFactor(totp, request.Code); err != nil {
+		h.app.Log.Debugf("requireSecondFactor: %v", err)
+		h.secondFactorError(c, err, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// DisableTOTP отключаем второй фактор, нужен код из приложения или код восстановления.
+func (h *Handler) DisableTOTP(c *gin.Context) {
+	if !h.requireSecondFactor(c) {
+		return
+	}
+	if err := h.repo.DeleteTOTP(currentUserID(c)); err != nil {
+		h.app.Log.Debugf("DisableTOTP repo.DeleteTOTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отключения второго фактора"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// RegenerateRecoveryCodes новые коды восстановления вместо прежних, нужен код из приложения или код восстановления.
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	if !h.requireSecondFactor(c) {
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = h.repo.ReplaceRecoveryCodes(currentUserID(c), hashes)
+	}
+	if err != nil {
+		h.app.Log.Debugf("RegenerateRecoveryCodes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания кодов восстановления"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
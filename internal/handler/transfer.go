@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project_avp/internal/tasks"
+	"go_final_project_avp/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Форматы файлов выгрузки и загрузки задач, по умолчанию JSON.
+const (
+	transferJSON = "json"
+	transferCSV  = "csv"
+)
+
+// Режимы импорта: append добавляет все задачи новыми, upsert изменяет задачи с существующим id.
+const (
+	importAppend = "append"
+	importUpsert = "upsert"
+)
+
+// Ограничения файла импорта: размер в байтах и число задач.
+const (
+	maxImportSize = 10 << 20
+	maxImportRows = 10000
+)
+
+// csvColumns колонки CSV в порядке выгрузки, при загрузке порядок берётся из заголовка.
+var csvColumns = []string{"id", "date", "title", "comment", "repeat"}
+
+// transferFormat формат файла из параметра format.
+func transferFormat(format string) (string, error) {
+	switch format {
+	case "", transferJSON:
+		return transferJSON, nil
+	case transferCSV:
+		return transferCSV, nil
+	default:
+		return "", errors.New("неподдерживаемый формат, ожидается format=json или format=csv")
+	}
+}
+
+// ExportTasks обработчик для маршрута GET /api/export: все задачи пользователя без корзины
+// файлом JSON (массив задач) или CSV с заголовком. Задачи пишутся в ответ по мере чтения из БД.
+func (h *Handler) ExportTasks(c *gin.Context) {
+	format, err := transferFormat(c.Query("format"))
+	if err != nil {
+		h.app.Log.Debugf("ExportTasks transferFormat: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	repeatFormat := c.Query("repeat_format")
+	if repeatFormat != "" && repeatFormat != repeatFormatRRule {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errRepeatFormat.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("scheduler-%s.%s", time.Now().Format(tasks.TimeFormat), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	var (
+		write  func(tasks.Task) error
+		finish func() error
+	)
+	switch format {
+	case transferCSV:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		if err = w.Write(csvColumns); err != nil {
+			h.app.Log.Debugf("ExportTasks csv.Write: %v", err)
+			return
+		}
+		write = func(t tasks.Task) error {
+			return w.Write([]string{t.Id, t.Date, t.Title, t.Comment, t.Repeat})
+		}
+		finish = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	default:
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		count := 0
+		if _, err = io.WriteString(c.Writer, "["); err != nil {
+			h.app.Log.Debugf("ExportTasks WriteString: %v", err)
+			return
+		}
+		write = func(t tasks.Task) error {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				if _, err = io.WriteString(c.Writer, ","); err != nil {
+					return err
+				}
+			}
+			count++
+			_, err = c.Writer.Write(data)
+			return err
+		}
+		finish = func() error {
+			_, err := io.WriteString(c.Writer, "]\n")
+			return err
+		}
+	}
+
+	err = h.repo.ExportTasks(currentUserID(c), func(t tasks.Task) error {
+		// Правило, которое не переводится в repeat_format, выгружаем как есть
+		if err := exportRepeat(repeatFormat, &t); err != nil {
+			h.app.Log.Debugf("ExportTasks exportRepeat задача %s: %v", t.Id, err)
+		}
+		return write(t)
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		// Заголовки уже отправлены, файл остаётся неполным
+		h.app.Log.Debugf("ExportTasks repo.ExportTasks: %v", err)
+	}
+}
+
+// readImport задачи из файла импорта в формате format.
+func readImport(r io.Reader, format string) ([]tasks.Task, error) {
+	if format == transferJSON {
+		var list []tasks.Task
+		if err := json.NewDecoder(r).Decode(&list); err != nil {
+			return nil, fmt.Errorf("файл JSON должен содержать массив задач: %v", err)
+		}
+		return list, nil
+	}
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("в файле CSV нет заголовка: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Excel добавляет в начало файла BOM
+		name = strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")
+		known := false
+		for _, column := range csvColumns {
+			known = known || name == column
+		}
+		if !known {
+			return nil, fmt.Errorf("неизвестная колонка CSV %q, ожидаются %s", name, strings.Join(csvColumns, ", "))
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("в файле CSV нет колонки title")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	var list []tasks.Task
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CSV: %v", err)
+		}
+		if len(list) == maxImportRows {
+			return nil, fmt.Errorf("в файле больше %d задач", maxImportRows)
+		}
+		list = append(list, tasks.Task{
+			Id:      field(record, "id"),
+			Date:    field(record, "date"),
+			Title:   field(record, "title"),
+			Comment: field(record, "comment"),
+			Repeat:  field(record, "repeat"),
+		})
+	}
+
+	return list, nil
+}
+
+// prepareImportTask проверяет задачу из файла импорта так же, как POST /api/task.
+func prepareImportTask(repeatFormat, mode string, task *tasks.Task, now time.Time) error {
+	if mode == importAppend {
+		task.Id = ""
+	} else if task.Id != "" {
+		if _, err := strconv.ParseInt(task.Id, 10, 64); err != nil {
+			return errors.New("некорректный идентификатор задачи")
+		}
+	}
+	task.Version, task.DeletedAt = 0, ""
+
+	// Перевод правила повторения из формата repeat_format
+	if err := importRepeat(repeatFormat, task); err != nil {
+		return errors.New(ruleErrorMessage(err, errRepeatFormat.Error()))
+	}
+
+	return tasks.ValidateAndSetDate(task, now)
+}
+
+// ImportTasks обработчик для маршрута POST /api/import: загружает задачи из файла JSON или CSV
+// в формате GET /api/export одной транзакцией. Если хоть одна задача не прошла проверку, не загружается ни одна.
+// dry_run=true только проверяет файл и показывает, что было бы сделано с каждой задачей.
+func (h *Handler) ImportTasks(c *gin.Context) {
+	format, err := transferFormat(c.Query("format"))
+	if err != nil {
+		h.app.Log.Debugf("ImportTasks transferFormat: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mode := c.DefaultQuery("mode", importAppend)
+	if mode != importAppend && mode != importUpsert {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неподдерживаемый режим, ожидается mode=append или mode=upsert"})
+		return
+	}
+	dryRun := false
+	if v := c.Query("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "параметр 'dry_run' должен быть true или false"})
+			return
+		}
+	}
+
+	list, err := readImport(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize), format)
+	if err != nil {
+		h.app.Log.Debugf("ImportTasks readImport: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(list) == 0 || len(list) > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("в файле должно быть от 1 до %d задач", maxImportRows)})
+		return
+	}
+
+	// Проверяем все задачи, чтобы сообщить обо всех ошибках сразу
+	now := time.Now()
+	results := make([]tasks.ImportResult, len(list))
+	valid := make([]tasks.Task, 0, len(list))
+	rows := make([]int, 0, len(list))
+	for i := range list {
+		results[i].Row = i + 1
+		if err = prepareImportTask(c.Query("repeat_format"), mode, &list[i], now); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, list[i])
+		rows = append(rows, i)
+	}
+	invalid := len(list) - len(valid)
+	if invalid > 0 && !dryRun {
+		h.app.Log.Debugf("ImportTasks задач с ошибками: %d", invalid)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("импорт не выполнен: задач с ошибками %d", invalid),
+			"invalid": invalid,
+			"results": results,
+		})
+		return
+	}
+
+	imported, err := h.repo.ImportTasks(currentUserID(c), valid, mode == importUpsert, dryRun)
+	if err != nil {
+		h.app.Log.Debugf("ImportTasks repo.ImportTasks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка импорта задач"})
+		return
+	}
+
+	counts := map[string]int{}
+	for j, result := range imported {
+		item := &results[rows[j]]
+		item.Id, item.Action = result.Id, result.Action
+		counts[result.Action]++
+	}
+
+	// События по каждой загруженной задаче после фиксации импорта; пробный импорт ничего не меняет
+	if !dryRun {
+		for _, result := range imported {
+			event := webhooks.EventTaskUpdated
+			if result.Action == tasks.ImportCreated {
+				event = webhooks.EventTaskCreated
+			}
+			h.emitTaskEvent(currentUserID(c), event, result.Task, nil)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run": dryRun,
+		"created": counts[tasks.ImportCreated],
+		"updated": counts[tasks.ImportUpdated],
+		"invalid": invalid,
+		"results": results,
+	})
+}
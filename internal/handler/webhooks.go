@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/tasks"
+	"go_final_project_avp/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookSecretBytes длина случайного секрета подписки в байтах.
+const webhookSecretBytes = 32
+
+// CreateWebhook создаём подписку на события задач и выдаём её секрет один раз.
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var request struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.app.Log.Debugf("CreateWebhook ShouldBindJSON неверные данные: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверные данные"})
+		return
+	}
+	if err := webhooks.ValidateURL(c.Request.Context(), request.URL, h.config.HooksAllowPrivate); err != nil {
+		h.app.Log.Debugf("CreateWebhook ValidateURL: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, err := webhooks.ParseEvents(request.Events)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	raw := make([]byte, webhookSecretBytes)
+	if _, err = rand.Read(raw); err != nil {
+		h.app.Log.Debugf("CreateWebhook Ошибка при создании секрета: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании секрета"})
+		return
+	}
+
+	hook := webhooks.Webhook{URL: request.URL, Events: events, Secret: hex.EncodeToString(raw)}
+	if _, err = h.repo.CreateWebhook(currentUserID(c), &hook); err != nil {
+		h.app.Log.Debugf("CreateWebhook repo.CreateWebhook ошибка добавления в бд: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка создания подписки"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":         hook.Id,
+		"url":        hook.URL,
+		"events":     hook.Events,
+		"secret":     hook.Secret,
+		"created_at": hook.CreatedAt,
+	})
+}
+
+// GetWebhooks список подписок на события задач без секретов.
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	list, err := h.repo.GetWebhooks(currentUserID(c))
+	if err != nil {
+		h.app.Log.Debugf("GetWebhooks repo.GetWebhooks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": list})
+}
+
+// DeleteWebhook удаляем подписку вместе с неотправленными событиями и журналом доставок.
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "идентификатор подписки обязателен"})
+		return
+	}
+
+	err := h.repo.DeleteWebhook(currentUserID(c), id)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		h.app.Log.Debugf("DeleteWebhook repo.DeleteWebhook: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Подписка не найдена"})
+		return
+	}
+	if err != nil {
+		h.app.Log.Debugf("DeleteWebhook repo.DeleteWebhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка удаления подписки"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// GetWebhookDeliveries журнал доставок подписки id, последние первыми, не больше limit.
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "идентификатор подписки обязателен"})
+		return
+	}
+
+	limit := repository.DefaultLimit
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > repository.MaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("параметр 'limit' должен быть числом от 1 до %d", repository.MaxLimit)})
+			return
+		}
+		limit = n
+	}
+
+	list, err := h.repo.GetDeliveries(currentUserID(c), id, limit)
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		h.app.Log.Debugf("GetWebhookDeliveries repo.GetDeliveries: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Подписка не найдена"})
+		return
+	}
+	if err != nil {
+		h.app.Log.Debugf("GetWebhookDeliveries repo.GetDeliveries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка вывода данных"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": list})
+}
+
+// emitTaskEvent ставит событие о задаче в очередь доставки подпискам пользователя.
+// Задача к этому моменту уже изменена, поэтому ошибка только записывается в лог.
+func (h *Handler) emitTaskEvent(userID int64, event string, task *tasks.Task, completion *tasks.Completion) {
+	now := time.Now()
+	payload, err := webhooks.NewPayload(event, task, completion, now)
+	if err != nil {
+		h.app.Log.Errorf("Не удалось подготовить событие %s: %v", event, err)
+		return
+	}
+	if _, err = h.repo.EnqueueEvent(userID, event, payload, now); err != nil {
+		h.app.Log.Errorf("Не удалось поставить событие %s в очередь: %v", event, err)
+	}
+}
@@ -0,0 +1,232 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/tasks"
+	"go_final_project_avp/internal/webhooks"
+)
+
+// Каналы напоминаний, под этими именами в БД хранится отметка об отправке.
+const (
+	ChannelSMTP     = "smtp"
+	ChannelWebhook  = "webhook"
+	ChannelTelegram = "telegram"
+)
+
+// Reminder напоминание о задаче пользователя, до даты которой осталось LeadDays дней.
+type Reminder struct {
+	UserId   int64
+	Login    string
+	Task     tasks.Task
+	LeadDays int
+	Target   string // адрес пользователя в канале: почта, чат Telegram или URL веб-хука
+}
+
+// Targets адреса, на которые пользователь получает напоминания о своих задачах.
+// Пустой адрес выключает канал для пользователя.
+type Targets struct {
+	Email          string `json:"email"`
+	TelegramChatID string `json:"telegram_chat_id"`
+	WebhookURL     string `json:"webhook_url"`
+	UpdatedAt      string `json:"updated_at,omitempty"` // время изменения в UTC, RFC 3339
+}
+
+// telegramChat id чата Telegram или @имя публичного канала.
+var telegramChat = regexp.MustCompile(`^(-?\d{1,20}|@[A-Za-z0-9_]{5,32})$`)
+
+// Validate проверяет почту и чат Telegram. Адрес веб-хука проверяет webhooks.ValidateURL:
+// для этого нужно разрешить имя хоста.
+func (t Targets) Validate() error {
+	if t.Email != "" {
+		addr, err := mail.ParseAddress(t.Email)
+		if err != nil || addr.Address != t.Email {
+			return errors.New("поле 'email' должно быть адресом электронной почты")
+		}
+	}
+	if t.TelegramChatID != "" && !telegramChat.MatchString(t.TelegramChatID) {
+		return errors.New("поле 'telegram_chat_id' должно быть id чата или @именем канала")
+	}
+	return nil
+}
+
+// Subject тема напоминания.
+func (r Reminder) Subject() string {
+	return "Напоминание: " + r.Task.Title
+}
+
+// Text текст напоминания: когда задача и её комментарий.
+func (r Reminder) Text() string {
+	date := r.Task.Date
+	if d, err := time.Parse(tasks.TimeFormat, r.Task.Date); err == nil {
+		date = d.Format(tasks.DisplayDateFormat)
+	}
+
+	var when string
+	switch r.LeadDays {
+	case 0:
+		when = "Сегодня, " + date
+	case 1:
+		when = "Завтра, " + date
+	default:
+		when = fmt.Sprintf("Через %d дн., %s", r.LeadDays, date)
+	}
+
+	text := fmt.Sprintf("%s: %s", when, r.Task.Title)
+	if r.Task.Comment != "" {
+		text += "\n" + r.Task.Comment
+	}
+	return text
+}
+
+// Notifier канал доставки напоминаний.
+type Notifier interface {
+	// Name имя канала, под ним в БД хранится отметка об отправке.
+	Name() string
+	Send(ctx context.Context, r Reminder) error
+}
+
+// NewNotifiers каналы, настроенные в конфигурации: почта при заданном сервере SMTP, Telegram при заданном
+// токене бота. Веб-хук доступен всегда, адреса пользователей в нём проверяются как адреса подписок на события.
+// Получателей задают сами пользователи, каждый получает напоминания только о своих задачах.
+func NewNotifiers(cfg config.Config) []Notifier {
+	var list []Notifier
+	if cfg.SMTPAddr != "" {
+		list = append(list, &SMTP{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	}
+	list = append(list, &Webhook{Client: webhooks.NewClient(cfg.HooksAllowPrivate)})
+	if cfg.TelegramToken != "" {
+		list = append(list, &Telegram{Token: cfg.TelegramToken, APIURL: cfg.TelegramAPI})
+	}
+	return list
+}
+
+// splitList значения списка через запятую без пробелов и пустых.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// ParseLeadDays разбирает TODO_REMIND_DAYS: за сколько дней до даты задачи напоминать, через запятую.
+func ParseLeadDays(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	var days []int
+	for _, v := range splitList(s) {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 365 {
+			return nil, fmt.Errorf("некорректное число дней %q в TODO_REMIND_DAYS, ожидается от 0 до 365", v)
+		}
+		if !seen[n] {
+			seen[n] = true
+			days = append(days, n)
+		}
+	}
+	if len(days) == 0 {
+		return nil, errors.New("в TODO_REMIND_DAYS не указано ни одного числа дней")
+	}
+	sort.Ints(days)
+	return days, nil
+}
+
+// ParseTimeOfDay разбирает время суток ЧЧ:ММ из TODO_REMIND_AT в смещение от полуночи.
+func ParseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("некорректное время %q в TODO_REMIND_AT, ожидается ЧЧ:ММ", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Store хранилище задач для напоминаний.
+type Store interface {
+	// GetPendingReminders задачи на дату date тех пользователей, у кого есть адрес в канале channel,
+	// о которых ещё не напомнили за leadDays дней через этот канал. Адрес возвращается в Reminder.Target.
+	GetPendingReminders(date string, leadDays int, channel string) ([]Reminder, error)
+	// MarkReminderSent отмечает напоминание отправленным, повторная отметка ничего не меняет.
+	MarkReminderSent(r Reminder, channel string, sentAt time.Time) error
+}
+
+// Worker рассылает напоминания о задачах их владельцам по всем каналам.
+type Worker struct {
+	Store     Store
+	Notifiers []Notifier
+	LeadDays  []int         // за сколько дней до даты задачи напоминать, 0 — в тот же день
+	At        time.Duration // с какого времени суток отправлять напоминания, смещение от полуночи
+	App       *slogavp.Application
+}
+
+// RunOnce отправляет напоминания, которые пора отправить в момент now, и возвращает их число.
+// Напоминание, которое не удалось отправить, остаётся неотправленным и уходит при следующем вызове;
+// ошибки каналов не прерывают рассылку, возвращается первая из них.
+func (w *Worker) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	today := tasks.TruncateToDate(now)
+	if now.Sub(today) < w.At {
+		return 0, nil
+	}
+
+	sent := 0
+	var firstErr error
+	for _, lead := range w.LeadDays {
+		date := today.AddDate(0, 0, lead).Format(tasks.TimeFormat)
+		for _, n := range w.Notifiers {
+			pending, err := w.Store.GetPendingReminders(date, lead, n.Name())
+			if err != nil {
+				return sent, err
+			}
+			for _, r := range pending {
+				if err = n.Send(ctx, r); err != nil {
+					w.App.Log.Errorf("Не удалось отправить напоминание о задаче %s через %s: %v", r.Task.Id, n.Name(), err)
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				if err = w.Store.MarkReminderSent(r, n.Name(), now); err != nil {
+					return sent, err
+				}
+				sent++
+			}
+		}
+	}
+
+	return sent, firstErr
+}
+
+// Run вызывает RunOnce сразу и затем раз в interval, пока не отменён ctx.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if sent, err := w.RunOnce(ctx, time.Now()); err != nil {
+			w.App.Log.Errorf("Ошибка рассылки напоминаний: %v", err)
+		} else if sent > 0 {
+			w.App.Log.Infof("Отправлено напоминаний: %d", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP напоминания письмом на почту пользователя. Аутентификация PLAIN включается, если задан Username;
+// net/smtp разрешает её только по TLS (STARTTLS) или на localhost.
+type SMTP struct {
+	Addr     string // host:port сервера
+	Username string
+	Password string
+	From     string
+}
+
+// Name имя канала.
+func (s *SMTP) Name() string {
+	return ChannelSMTP
+}
+
+// Send отправляет напоминание письмом на адрес r.Target.
+func (s *SMTP) Send(ctx context.Context, r Reminder) error {
+	if r.Target == "" {
+		return errors.New("smtp: не указан получатель")
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("smtp: некорректный адрес сервера %q: %w", s.Addr, err)
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	// SendMail не принимает контекст, поэтому ждём его отмены отдельно
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, auth, s.From, []string{r.Target}, s.message(r))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message письмо по RFC 5322, тема кодируется по RFC 2047.
+func (s *SMTP) message(r Reminder) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + s.From + "\r\n")
+	b.WriteString("To: " + r.Target + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", r.Subject()) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(r.Text(), "\n", "\r\n") + "\r\n")
+	return b.Bytes()
+}
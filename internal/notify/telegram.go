@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TelegramAPI адрес Bot API по умолчанию.
+const TelegramAPI = "https://api.telegram.org"
+
+// Telegram напоминания сообщением бота в чат пользователя через метод sendMessage Bot API.
+// Чтобы бот мог написать в чат, пользователь сначала сам пишет боту.
+type Telegram struct {
+	Token  string
+	APIURL string       // пустой — TelegramAPI
+	Client *http.Client // nil — клиент с таймаутом httpTimeout
+}
+
+// Name имя канала.
+func (t *Telegram) Name() string {
+	return ChannelTelegram
+}
+
+// Send отправляет напоминание в чат r.Target.
+func (t *Telegram) Send(ctx context.Context, r Reminder) error {
+	api := t.APIURL
+	if api == "" {
+		api = TelegramAPI
+	}
+	url := strings.TrimSuffix(api, "/") + "/bot" + t.Token + "/sendMessage"
+
+	body, err := postJSON(ctx, t.Client, url, map[string]string{"chat_id": r.Target, "text": r.Text()})
+	var result struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if len(body) > 0 {
+		_ = json.Unmarshal(body, &result)
+	}
+	if err != nil {
+		// Токен есть в адресе запроса, в текст ошибки его не выводим
+		if result.Description != "" {
+			return fmt.Errorf("telegram: %s", result.Description)
+		}
+		return fmt.Errorf("telegram: ошибка запроса sendMessage: %s", strings.ReplaceAll(err.Error(), t.Token, "***"))
+	}
+	if !result.Ok {
+		return fmt.Errorf("telegram: sendMessage не выполнен: %s", result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpTimeout время ожидания ответа HTTP-канала, если клиент не задан.
+const httpTimeout = 10 * time.Second
+
+// Webhook напоминания запросом POST с JSON на адрес пользователя. Ответ не из 2xx считается ошибкой.
+type Webhook struct {
+	Client *http.Client // nil — клиент с таймаутом httpTimeout
+}
+
+// webhookPayload тело запроса веб-хука.
+type webhookPayload struct {
+	User     string `json:"user"`
+	TaskId   string `json:"task_id"`
+	Title    string `json:"title"`
+	Comment  string `json:"comment,omitempty"`
+	Date     string `json:"date"`
+	LeadDays int    `json:"lead_days"`
+	Text     string `json:"text"`
+}
+
+// Name имя канала.
+func (w *Webhook) Name() string {
+	return ChannelWebhook
+}
+
+// Send отправляет напоминание на адрес r.Target.
+func (w *Webhook) Send(ctx context.Context, r Reminder) error {
+	payload := webhookPayload{
+		User:     r.Login,
+		TaskId:   r.Task.Id,
+		Title:    r.Task.Title,
+		Comment:  r.Task.Comment,
+		Date:     r.Task.Date,
+		LeadDays: r.LeadDays,
+		Text:     r.Text(),
+	}
+	if _, err := postJSON(ctx, w.Client, r.Target, payload); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	return nil
+}
+
+// postJSON отправляет body в формате JSON и возвращает тело успешного ответа.
+func postJSON(ctx context.Context, client *http.Client, url string, body any) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return respBody, fmt.Errorf("ответ %s", resp.Status)
+	}
+	return respBody, nil
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"go_final_project_avp/internal/users"
+
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAPIKeyNotFound ключа с таким id или хэшем нет.
+var ErrAPIKeyNotFound = errors.New("API-ключ не найден")
+
+// apiKeyTouchInterval не чаще этого записываем время последнего использования ключа,
+// чтобы каждый запрос скрипта не был записью в БД.
+const apiKeyTouchInterval = time.Minute
+
+const createAPIKey = ` -- name: CreateAPIKey
+	INSERT INTO api_keys
+	    (user_id, name, prefix, key_hash, scopes, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	RETURNING id
+	`
+
+// CreateAPIKey добавляем API-ключ пользователя.
+func (r *Repository) CreateAPIKey(userID int64, key *users.APIKey) (int64, error) {
+	ctx := context.Background()
+
+	key.UserId = userID
+	key.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	var id int64
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(createAPIKey), userID, key.Name, key.Prefix, key.KeyHash,
+		strings.Join(key.Scopes, ","), key.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+	key.Id = strconv.FormatInt(id, 10)
+
+	return id, nil
+}
+
+const getAPIKeys = ` -- name: GetAPIKeys
+	SELECT id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at
+    FROM api_keys
+    WHERE user_id = ?
+    ORDER BY id ASC
+	`
+
+// GetAPIKeys получаем API-ключи пользователя.
+func (r *Repository) GetAPIKeys(userID int64) ([]users.APIKey, error) {
+	ctx := context.Background()
+
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(getAPIKeys), userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	list := []users.APIKey{}
+	for res.Next() {
+		key, err := scanAPIKey(res)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования API-ключа res.Scan: %w", err)
+		}
+		list = append(list, key)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+const getAPIKeyByHash = ` -- name: GetAPIKeyByHash
+	SELECT id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at
+    FROM api_keys
+    WHERE key_hash = ?
+	`
+
+// GetAPIKeyByHash получаем ключ по хэшу.
+func (r *Repository) GetAPIKeyByHash(keyHash string) (users.APIKey, error) {
+	ctx := context.Background()
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, r.db.Rebind(getAPIKeyByHash), keyHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return key, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+
+	return key, nil
+}
+
+// scanAPIKey строка api_keys, области доступа хранятся через запятую.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (users.APIKey, error) {
+	key := users.APIKey{}
+	var scopes string
+	err := row.Scan(&key.Id, &key.UserId, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &key.CreatedAt, &key.LastUsedAt)
+	if err != nil {
+		return key, err
+	}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	return key, nil
+}
+
+const touchAPIKey = ` -- name: TouchAPIKey
+	UPDATE api_keys
+	SET last_used_at = ?
+	WHERE id = ? AND last_used_at < ?
+	`
+
+// TouchAPIKey отмечаем использование ключа, если с прошлой отметки прошло больше apiKeyTouchInterval.
+func (r *Repository) TouchAPIKey(id string, now time.Time) error {
+	ctx := context.Background()
+
+	keyID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAPIKeyNotFound, id)
+	}
+
+	_, err = r.db.ExecContext(ctx, r.db.Rebind(touchAPIKey), now.UTC().Format(time.RFC3339), keyID,
+		now.Add(-apiKeyTouchInterval).UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+const deleteAPIKey = ` -- name: DeleteAPIKey
+	DELETE FROM api_keys
+	       WHERE id = ? AND user_id = ?
+`
+
+// DeleteAPIKey отзываем API-ключ пользователя.
+func (r *Repository) DeleteAPIKey(userID int64, id string) error {
+	ctx := context.Background()
+
+	keyID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAPIKeyNotFound, id)
+	}
+
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(deleteAPIKey), keyID, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %v", ErrAPIKeyNotFound, id)
+	}
+
+	return nil
+}
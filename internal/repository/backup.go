@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ErrBackupUnsupported снимки БД делаются через SQLite backup API, для PostgreSQL есть pg_dump.
+var ErrBackupUnsupported = errors.New("резервное копирование поддерживается только для SQLite")
+
+// backupTimeFormat время снимка в имени файла, в UTC.
+const backupTimeFormat = "20060102-150405"
+
+// backupFile имя файла снимка: <имя БД>-<время>.db.
+var backupFile = regexp.MustCompile(`^(.+)-(\d{8}-\d{6})\.db$`)
+
+// BackupFile снимок БД в директории снимков.
+type BackupFile struct {
+	Name      string `json:"name"`
+	Path      string `json:"-"`
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at"` // время снимка в UTC, RFC 3339
+}
+
+// BackupName имя базы в именах снимков: имя файла БД без расширения, для БД в памяти — scheduler.
+func BackupName(dbFile string) string {
+	if dbFile == "" || dbFile == MemoryDB {
+		return "scheduler"
+	}
+	base := filepath.Base(dbFile)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Backup снимает копию открытой БД SQLite в файл dir/<name>-<время now>.db через SQLite backup API.
+// Копия снимается за один шаг, поэтому согласована: на это время запись в БД ждёт, как при обычной блокировке.
+// Файл появляется под итоговым именем только целиком.
+func (r *Repository) Backup(dir, name string, now time.Time) (BackupFile, error) {
+	if r.db.DriverName() != DriverSQLite {
+		return BackupFile{}, ErrBackupUnsupported
+	}
+	ctx := context.Background()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return BackupFile{}, fmt.Errorf("не удалось создать директорию снимков %s: %w", dir, err)
+	}
+	file := BackupFile{
+		Name:      fmt.Sprintf("%s-%s.db", name, now.UTC().Format(backupTimeFormat)),
+		CreatedAt: now.UTC().Format(time.RFC3339),
+	}
+	file.Path = filepath.Join(dir, file.Name)
+	tmp := file.Path + ".tmp"
+	_ = os.Remove(tmp)
+
+	if err := r.backupTo(ctx, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return BackupFile{}, err
+	}
+	if err := os.Rename(tmp, file.Path); err != nil {
+		_ = os.Remove(tmp)
+		return BackupFile{}, fmt.Errorf("не удалось сохранить снимок %s: %w", file.Path, err)
+	}
+
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return BackupFile{}, fmt.Errorf("не удалось прочитать снимок %s: %w", file.Path, err)
+	}
+	file.Size = info.Size()
+
+	return file, nil
+}
+
+// sqliteBackuper соединение драйвера SQLite, которое умеет снимать копию БД в файл.
+type sqliteBackuper interface {
+	NewBackup(dstUri string) (*sqlite.Backup, error)
+}
+
+// backupTo копирует БД в новый файл path соединением SQLite из пула.
+func (r *Repository) backupTo(ctx context.Context, path string) error {
+	srcConn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось получить соединение с БД: %w", err)
+	}
+	defer func(conn *sql.Conn) {
+		_ = conn.Close()
+	}(srcConn)
+
+	return srcConn.Raw(func(srcDriver any) error {
+		src, ok := srcDriver.(sqliteBackuper)
+		if !ok {
+			return ErrBackupUnsupported
+		}
+
+		backup, err := src.NewBackup(path)
+		if err != nil {
+			return fmt.Errorf("ошибка начала снимка: %w", err)
+		}
+		// Пока БД занята записью, шаг ничего не копирует: повторяем его до sqliteBusyTimeout
+		deadline := time.Now().Add(sqliteBusyTimeout * time.Millisecond)
+		for {
+			more, err := backup.Step(-1)
+			if err != nil && !isBusy(err) {
+				_ = backup.Finish()
+				return fmt.Errorf("ошибка снимка БД: %w", err)
+			}
+			if err == nil && !more {
+				break
+			}
+			if time.Now().After(deadline) {
+				_ = backup.Finish()
+				return errors.New("ошибка снимка БД: база данных занята")
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if err = backup.Finish(); err != nil {
+			return fmt.Errorf("ошибка завершения снимка: %w", err)
+		}
+		return nil
+	})
+}
+
+// isBusy ошибка SQLite о том, что БД заблокирована другим соединением.
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// ListBackups снимки базы name в директории dir, последние первыми. Директории может не быть.
+func ListBackups(dir, name string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []BackupFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения директории снимков: %w", err)
+	}
+
+	files := []BackupFile{}
+	for _, entry := range entries {
+		m := backupFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil || m[1] != name {
+			continue
+		}
+		created, err := time.Parse(backupTimeFormat, m[2])
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения снимка %s: %w", entry.Name(), err)
+		}
+		files = append(files, BackupFile{
+			Name:      entry.Name(),
+			Path:      filepath.Join(dir, entry.Name()),
+			Size:      info.Size(),
+			CreatedAt: created.Format(time.RFC3339),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt > files[j].CreatedAt })
+
+	return files, nil
+}
+
+// PruneBackups удаляет снимки базы name в dir, кроме keep последних, и возвращает удалённые.
+// keep 0 — хранить все.
+func PruneBackups(dir, name string, keep int) ([]BackupFile, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	files, err := ListBackups(dir, name)
+	if err != nil || len(files) <= keep {
+		return nil, err
+	}
+
+	for _, file := range files[keep:] {
+		if err = os.Remove(file.Path); err != nil {
+			return nil, fmt.Errorf("не удалось удалить снимок %s: %w", file.Name, err)
+		}
+	}
+	return files[keep:], nil
+}
+
+// CheckBackup проверяет, что файл path — целая БД SQLite со схемой этого приложения:
+// таблицы на месте, миграции применены подряд с первой и все известны этой версии приложения.
+func CheckBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("снимок не найден: %w", err)
+	}
+	db, err := sqlx.Open(DriverSQLite, "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("не удалось открыть снимок: %w", err)
+	}
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+
+	var integrity string
+	if err = db.Get(&integrity, `PRAGMA integrity_check`); err != nil {
+		return fmt.Errorf("снимок не является БД SQLite: %w", err)
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("снимок повреждён: %s", integrity)
+	}
+
+	for _, table := range []string{"schema_migrations", "users", "scheduler"} {
+		var count int
+		err = db.Get(&count, `SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения схемы снимка: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("в снимке нет таблицы %s", table)
+		}
+	}
+
+	migrations, err := loadMigrations(DriverSQLite)
+	if err != nil {
+		return err
+	}
+	var applied []Migration
+	if err = db.Select(&applied, `SELECT version, name FROM schema_migrations ORDER BY version`); err != nil {
+		return fmt.Errorf("ошибка чтения миграций снимка: %w", err)
+	}
+	if len(applied) == 0 {
+		return errors.New("в снимке нет применённых миграций")
+	}
+	for i, mig := range applied {
+		if i >= len(migrations) || mig.Version != migrations[i].Version {
+			if mig.Version > migrations[len(migrations)-1].Version {
+				return fmt.Errorf("снимок сделан более новой версией приложения: миграция %04d_%s неизвестна", mig.Version, mig.Name)
+			}
+			return fmt.Errorf("в снимке пропущена миграция %04d_%s", migrations[i].Version, migrations[i].Name)
+		}
+		if mig.Name != migrations[i].Name {
+			return fmt.Errorf("миграция %04d снимка называется %s, ожидается %s", mig.Version, mig.Name, migrations[i].Name)
+		}
+	}
+
+	return nil
+}
+
+// RestoreBackup проверяет снимок path и подменяет им файл БД dbFile. Прежний файл БД сохраняется рядом
+// с суффиксом .before-restore-<время now>, его путь возвращается. Сервер на это время должен быть остановлен:
+// открытые соединения продолжат работать с прежним файлом.
+func RestoreBackup(path, dbFile string, now time.Time) (string, error) {
+	if dbFile == "" || dbFile == MemoryDB {
+		return "", errors.New("восстановление требует файла БД в TODO_DBFILE")
+	}
+	if err := CheckBackup(path); err != nil {
+		return "", err
+	}
+	// Незавершённый журнал прежней БД применился бы к восстановленному файлу
+	for _, suffix := range []string{"-journal", "-wal"} {
+		if _, err := os.Stat(dbFile + suffix); err == nil {
+			return "", fmt.Errorf("у БД есть журнал %s: остановите сервер перед восстановлением", dbFile+suffix)
+		}
+	}
+
+	tmp := dbFile + ".restore"
+	if err := copyFile(path, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+
+	previous := ""
+	if _, err := os.Stat(dbFile); err == nil {
+		previous = fmt.Sprintf("%s.before-restore-%s", dbFile, now.UTC().Format(backupTimeFormat))
+		if err = os.Rename(dbFile, previous); err != nil {
+			_ = os.Remove(tmp)
+			return "", fmt.Errorf("не удалось сохранить прежний файл БД: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, dbFile); err != nil {
+		return previous, fmt.Errorf("не удалось подменить файл БД: %w", err)
+	}
+
+	return previous, nil
+}
+
+// copyFile копирует файл src в новый файл dst и сбрасывает его на диск.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть снимок: %w", err)
+	}
+	defer func(in *os.File) {
+		_ = in.Close()
+	}(in)
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("не удалось создать файл %s: %w", dst, err)
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("ошибка копирования снимка: %w", err)
+	}
+	if err = out.Sync(); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("ошибка записи снимка: %w", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go_final_project_avp/internal/tasks"
+)
+
+// MaxBatchOps наибольшее число операций в пакетном запросе.
+const MaxBatchOps = 100
+
+// BatchError операция пакета с номером Index (с нуля) не выполнена, вся транзакция пакета отменена.
+type BatchError struct {
+	Index int
+	Op    string
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("операция %d (%s): %v", e.Index, e.Op, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+const shiftTask = ` -- name: ShiftTask
+	UPDATE scheduler
+    SET date = ?, version = version + 1
+    WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND version = ?
+	`
+
+// Batch выполняет операции пользователя по порядку в одной транзакции, время выполнения задач — now.
+// Операции проверяет вызывающий: у create и update дата и правило повторения должны быть в каноническом виде.
+// Если какая-то операция не выполнена, изменения всех операций отменяются и возвращается *BatchError.
+func (r *Repository) Batch(userID int64, ops []tasks.BatchOp, now time.Time) ([]tasks.BatchResult, error) {
+	ctx := context.Background()
+
+	var results []tasks.BatchResult
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		results = make([]tasks.BatchResult, 0, len(ops))
+		for i, op := range ops {
+			result, err := r.batchOpInTx(ctx, tx, userID, op, now)
+			if err != nil {
+				return &BatchError{Index: i, Op: op.Op, Err: err}
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// batchOpInTx выполняет одну операцию пакета в транзакции tx.
+func (r *Repository) batchOpInTx(ctx context.Context, tx *sql.Tx, userID int64, op tasks.BatchOp,
+	now time.Time) (tasks.BatchResult, error) {
+	result := tasks.BatchResult{Op: op.Op, Id: op.Id}
+
+	switch op.Op {
+	case tasks.OpCreate:
+		var id int64
+		err := tx.QueryRowContext(ctx, r.db.Rebind(createTask), op.Date, op.Title, op.Comment, op.Repeat, userID).Scan(&id)
+		if err != nil {
+			return result, fmt.Errorf("ошибка добавления задачи: %w", err)
+		}
+		result.Id = strconv.FormatInt(id, 10)
+		result.Date, result.Version = op.Date, 1
+		result.Current = &tasks.Task{Id: result.Id, Date: op.Date, Title: op.Title, Comment: op.Comment,
+			Repeat: op.Repeat, Version: 1}
+
+	case tasks.OpUpdate:
+		task := op.Task()
+		if err := r.updateInTx(ctx, tx, userID, &task); err != nil {
+			return result, err
+		}
+		result.Date, result.Version = task.Date, task.Version
+		result.Current = &task
+
+	case tasks.OpDone:
+		completion, task, err := r.doneInTx(ctx, tx, userID, op.Id, op.Version, op.Note, now)
+		if err != nil {
+			return result, err
+		}
+		result.Date, result.Version = task.Date, task.Version
+		result.Completion = &completion
+		// Разовая задача после выполнения в корзине, как и у одиночного done в событие не попадает
+		if task.Date != "" {
+			result.Current = &task
+		}
+
+	case tasks.OpDelete:
+		// Задачу для события читаем до удаления
+		task, err := r.getTaskInTx(ctx, tx, userID, op.Id)
+		if err != nil {
+			task = tasks.Task{Id: op.Id}
+		}
+		result.Current = &task
+
+		err = tx.QueryRowContext(ctx, r.db.Rebind(deleteTask), now.UTC().Format(time.RFC3339), op.Id, userID,
+			op.Version, op.Version).Scan(&result.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return result, r.conflictInTx(ctx, tx, userID, op.Id)
+		}
+		if err != nil {
+			return result, fmt.Errorf("ошибка удаления задачи: %w", err)
+		}
+
+	case tasks.OpShift:
+		task, err := r.getTaskInTx(ctx, tx, userID, op.Id)
+		if err != nil {
+			return result, err
+		}
+		if op.Version != 0 && op.Version != task.Version {
+			return result, &ConflictError{Current: task}
+		}
+		date, err := time.Parse(tasks.TimeFormat, task.Date)
+		if err != nil {
+			return result, fmt.Errorf("некорректная дата задачи: %w", err)
+		}
+		task.Date = date.AddDate(0, 0, op.Days).Format(tasks.TimeFormat)
+
+		res, err := tx.ExecContext(ctx, r.db.Rebind(shiftTask), task.Date, op.Id, userID, task.Version)
+		if err != nil {
+			return result, fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return result, fmt.Errorf("ошибка получения затронутых строк: %w", err)
+		}
+		if rowsAffected == 0 {
+			return result, r.conflictInTx(ctx, tx, userID, op.Id)
+		}
+		task.Version++
+		result.Date, result.Version = task.Date, task.Version
+		result.Current = &task
+
+	default:
+		return result, fmt.Errorf("неизвестная операция %q", op.Op)
+	}
+
+	return result, nil
+}
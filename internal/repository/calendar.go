@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"go_final_project_avp/internal/calendar"
+
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const createFeed = ` -- name: CreateFeed
+	INSERT INTO calendar_feeds
+	    (name, token_hash, created_at, user_id)
+	VALUES (?, ?, ?, ?)
+	RETURNING id
+	`
+
+// CreateFeed добавляем подписку пользователя на календарь.
+func (r *Repository) CreateFeed(userID int64, feed *calendar.Feed) (int64, error) {
+	ctx := context.Background()
+
+	feed.UserId = userID
+	feed.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	var id int64
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(createFeed), feed.Name, feed.TokenHash, feed.CreatedAt, userID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+	feed.Id = strconv.FormatInt(id, 10)
+
+	return id, nil
+}
+
+const getFeeds = ` -- name: GetFeeds
+	SELECT id, name, created_at
+    FROM calendar_feeds
+    WHERE user_id = ?
+    ORDER BY id ASC
+	`
+
+// GetFeeds получаем список подписок пользователя на календарь.
+func (r *Repository) GetFeeds(userID int64) ([]calendar.Feed, error) {
+	ctx := context.Background()
+
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(getFeeds), userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	feeds := []calendar.Feed{}
+	for res.Next() {
+		f := calendar.Feed{UserId: userID}
+		if err = res.Scan(&f.Id, &f.Name, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования подписки res.Scan: %w", err)
+		}
+		feeds = append(feeds, f)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return feeds, nil
+}
+
+const getFeedByToken = ` -- name: GetFeedByToken
+	SELECT id, user_id, name, token_hash, created_at
+    FROM calendar_feeds
+    WHERE token_hash = ?
+	`
+
+// GetFeedByToken получаем подписку по хэшу токена.
+func (r *Repository) GetFeedByToken(tokenHash string) (calendar.Feed, error) {
+	ctx := context.Background()
+	f := calendar.Feed{}
+
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(getFeedByToken), tokenHash).Scan(&f.Id, &f.UserId, &f.Name, &f.TokenHash, &f.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return f, fmt.Errorf("подписка не найдена")
+	}
+	if err != nil {
+		return f, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+
+	return f, nil
+}
+
+const deleteFeed = ` -- name: DeleteFeed
+	DELETE FROM calendar_feeds
+	       WHERE id = ? AND user_id = ?
+`
+
+// DeleteFeed отзываем подписку пользователя на календарь.
+func (r *Repository) DeleteFeed(userID int64, id string) error {
+	ctx := context.Background()
+
+	ids, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("не удается преобразовать id - %v : %v", id, err)
+	}
+
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(deleteFeed), ids, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+	}
+
+	if count == 0 {
+		return fmt.Errorf("ошибка нет такого id: %v", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go_final_project_avp/internal/tasks"
+)
+
+const createCompletion = ` -- name: CreateCompletion
+	INSERT INTO task_completions
+	    (user_id, task_id, title, scheduled_date, completed_at, note, task_version)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	RETURNING id
+	`
+
+// GetCompletions история выполнения задач пользователя в порядке выполнения.
+// Пустой taskID — все задачи; from и to ограничивают время выполнения, to не включается,
+// нулевое время не ограничивает интервал.
+func (r *Repository) GetCompletions(userID int64, taskID string, from, to time.Time) ([]tasks.Completion, error) {
+	ctx := context.Background()
+
+	query := "SELECT id, task_id, title, scheduled_date, completed_at, note FROM task_completions WHERE user_id = ?"
+	args := []interface{}{userID}
+	if taskID != "" {
+		query += " AND task_id = ?"
+		args = append(args, taskID)
+	}
+	// completed_at в UTC и RFC 3339, поэтому строки сравниваются как время
+	if !from.IsZero() {
+		query += " AND completed_at >= ?"
+		args = append(args, from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query += " AND completed_at < ?"
+		args = append(args, to.UTC().Format(time.RFC3339))
+	}
+	query += " ORDER BY completed_at ASC, id ASC"
+
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	list := []tasks.Completion{}
+	for res.Next() {
+		var c tasks.Completion
+		if err = res.Scan(&c.Id, &c.TaskId, &c.Title, &c.ScheduledDate, &c.CompletedAt, &c.Note); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования записи истории res.Scan: %w", err)
+		}
+		list = append(list, c)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
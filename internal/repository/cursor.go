@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project_avp/internal/tasks"
+)
+
+// MaxLimit наибольший размер страницы списка задач.
+const MaxLimit = 500
+
+// Cursor позиция в списке задач, упорядоченном по (date, id),
+// или в результатах полнотекстового поиска, упорядоченных по (Rank, id).
+// Нулевой Cursor означает начало списка.
+type Cursor struct {
+	Date string
+	Rank float64
+	Id   int64
+}
+
+// CursorAfter курсор, указывающий на задачу task: следующая страница начнётся после неё.
+func CursorAfter(task tasks.Task) (Cursor, error) {
+	id, err := strconv.ParseInt(task.Id, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("неверный id задачи %q: %w", task.Id, err)
+	}
+	if task.Rank != 0 {
+		return Cursor{Rank: task.Rank, Id: id}, nil
+	}
+	return Cursor{Date: task.Date, Id: id}, nil
+}
+
+// IsZero курсор указывает на начало списка.
+func (c Cursor) IsZero() bool {
+	return c == Cursor{}
+}
+
+// String непрозрачное представление курсора для передачи клиенту:
+// "дата:id" или "r" и релевантность для поиска, например "r-1.25:id".
+func (c Cursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	key := c.Date
+	if c.Rank != 0 {
+		key = "r" + strconv.FormatFloat(c.Rank, 'g', -1, 64)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(key + ":" + strconv.FormatInt(c.Id, 10)))
+}
+
+// ParseCursor разбирает курсор, выданный Cursor.String, пустая строка — начало списка.
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("неверный курсор: %w", err)
+	}
+	key, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return Cursor{}, fmt.Errorf("неверный курсор: нет разделителя")
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 {
+		return Cursor{}, fmt.Errorf("неверный курсор: неверный id %q", id)
+	}
+
+	if rank, ok := strings.CutPrefix(key, "r"); ok {
+		r, err := strconv.ParseFloat(rank, 64)
+		if err != nil || r == 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+			return Cursor{}, fmt.Errorf("неверный курсор: неверная релевантность %q", rank)
+		}
+		return Cursor{Rank: r, Id: n}, nil
+	}
+	if _, err = time.Parse(tasks.TimeFormat, key); err != nil {
+		return Cursor{}, fmt.Errorf("неверный курсор: %w", err)
+	}
+
+	return Cursor{Date: key, Id: n}, nil
+}
@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"database/sql"
+	"embed"
+
+	"context"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Миграции лежат в отдельной директории для каждого драйвера БД.
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationsFS embed.FS
+
+// migrationFile имя файла миграции: 0001_name.up.sql или 0001_name.down.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration версия схемы БД со скриптами применения и отката.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState состояние миграции в БД.
+type MigrationState struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+     version INTEGER PRIMARY KEY,
+     name TEXT NOT NULL,
+     applied_at TEXT NOT NULL
+);`
+
+// loadMigrations читает встроенные в бинарник миграции драйвера driver, упорядоченные по версии.
+func loadMigrations(driver string) ([]Migration, error) {
+	dir := "migrations/sqlite"
+	if driver == DriverPostgres {
+		dir = "migrations/postgres"
+	}
+
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("неверное имя файла миграции: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+
+		body, err := migrationsFS.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения миграции %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("разные имена у миграции версии %d: %s и %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("у миграции %04d_%s нет скрипта up или down", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// appliedMigrations версии уже применённых миграций и время их применения.
+func (r *Repository) appliedMigrations(ctx context.Context) (map[int]string, error) {
+	if _, err := r.db.ExecContext(ctx, createSchemaMigrations); err != nil {
+		return nil, fmt.Errorf("ошибка создания таблицы schema_migrations: %w", err)
+	}
+
+	if err := r.adoptLegacySchema(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt string
+		)
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования миграции rows.Scan: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// adoptLegacySchema отмечает применёнными миграции, которые уже выполнил
+// прежний код без версий: он создавал таблицу users и колонку user_id без записи в schema_migrations.
+// Такие базы бывают только у SQLite.
+func (r *Repository) adoptLegacySchema(ctx context.Context) error {
+	if r.db.DriverName() != DriverSQLite {
+		return nil
+	}
+
+	var recorded, users int
+	if err := r.db.GetContext(ctx, &recorded, `SELECT count(*) FROM schema_migrations`); err != nil {
+		return err
+	}
+	if recorded > 0 {
+		return nil
+	}
+	err := r.db.GetContext(ctx, &users, `SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'users'`)
+	if err != nil || users == 0 {
+		return err
+	}
+
+	migrations, err := loadMigrations(r.db.DriverName())
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, mig := range migrations {
+		if mig.Version > 3 {
+			break
+		}
+		_, err = r.db.ExecContext(ctx, r.db.Rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+			mig.Version, mig.Name, now)
+		if err != nil {
+			return err
+		}
+	}
+	r.app.Log.Infof("Схема БД без версий принята как версия 3")
+
+	return nil
+}
+
+// MigrationStatus список всех миграций с отметкой о применении.
+func (r *Repository) MigrationStatus() ([]MigrationState, error) {
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(r.db.DriverName())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, mig := range migrations {
+		appliedAt, ok := applied[mig.Version]
+		states = append(states, MigrationState{Version: mig.Version, Name: mig.Name, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return states, nil
+}
+
+// MigrateUp применяет неприменённые миграции до версии target включительно, 0 — до последней.
+// Каждая миграция выполняется в своей транзакции вместе с записью в schema_migrations.
+func (r *Repository) MigrateUp(target int) error {
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(r.db.DriverName())
+	if err != nil {
+		return err
+	}
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		err = r.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, r.db.Rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+				mig.Version, mig.Name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка применения миграции %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		r.app.Log.Infof("Применена миграция %04d_%s", mig.Version, mig.Name)
+	}
+
+	return nil
+}
+
+// MigrateDown откатывает steps последних применённых миграций.
+func (r *Repository) MigrateDown(steps int) error {
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(r.db.DriverName())
+	if err != nil {
+		return err
+	}
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		mig := migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+
+		err = r.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), mig.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка отката миграции %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		r.app.Log.Infof("Откачена миграция %04d_%s", mig.Version, mig.Name)
+		steps--
+	}
+
+	return nil
+}
+
+// inTx выполняет fn в транзакции, откатывая её при ошибке.
+func (r *Repository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS index_date;
+DROP TABLE IF EXISTS scheduler;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+     id SERIAL PRIMARY KEY,
+     name TEXT NOT NULL,
+     token_hash TEXT NOT NULL UNIQUE, -- sha256 токена подписки
+     created_at TEXT NOT NULL
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS index_user_date;
+ALTER TABLE calendar_feeds DROP COLUMN user_id;
+ALTER TABLE scheduler DROP COLUMN user_id;
+DROP TABLE users;
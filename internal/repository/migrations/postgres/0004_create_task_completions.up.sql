@@ -0,0 +1,14 @@
+-- История выполнения задач. task_id без внешнего ключа: разовая задача после выполнения удаляется,
+-- а запись о выполнении остаётся вместе с заголовком задачи
+CREATE TABLE task_completions (
+     id SERIAL PRIMARY KEY,
+     user_id INTEGER NOT NULL REFERENCES users(id),
+     task_id INTEGER NOT NULL,
+     title TEXT NOT NULL,
+     scheduled_date TEXT CHECK(LENGTH(scheduled_date) <= 8) NOT NULL, -- дата задачи на момент выполнения
+     completed_at TEXT NOT NULL, -- время выполнения в UTC, RFC 3339
+     note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX index_completions_task ON task_completions (user_id, task_id, completed_at);
+CREATE INDEX index_completions_date ON task_completions (user_id, completed_at);
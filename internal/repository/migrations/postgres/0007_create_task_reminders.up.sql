@@ -0,0 +1,13 @@
+-- Отправленные напоминания: по одной записи на задачу, дату, за сколько дней и канал,
+-- чтобы после перезапуска сервера напоминание не ушло повторно
+CREATE TABLE task_reminders (
+     id SERIAL PRIMARY KEY,
+     user_id INTEGER NOT NULL REFERENCES users(id),
+     task_id INTEGER NOT NULL,
+     due_date TEXT CHECK(LENGTH(due_date) <= 8) NOT NULL, -- дата задачи, о которой напомнили
+     lead_days INTEGER NOT NULL, -- за сколько дней до даты, 0 — в тот же день
+     channel TEXT NOT NULL,
+     sent_at TEXT NOT NULL -- время отправки в UTC, RFC 3339
+);
+
+CREATE UNIQUE INDEX index_reminders_unique ON task_reminders (task_id, due_date, lead_days, channel);
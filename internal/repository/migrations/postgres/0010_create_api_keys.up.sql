@@ -0,0 +1,13 @@
+-- Именованные API-ключи для скриптов: сам ключ не хранится, только его sha256
+CREATE TABLE api_keys (
+     id SERIAL PRIMARY KEY,
+     user_id INTEGER NOT NULL REFERENCES users(id),
+     name TEXT NOT NULL,
+     prefix TEXT NOT NULL, -- начало ключа, чтобы узнать его в списке
+     key_hash TEXT NOT NULL UNIQUE,
+     scopes TEXT NOT NULL, -- области доступа через запятую
+     created_at TEXT NOT NULL,
+     last_used_at TEXT NOT NULL DEFAULT '' -- пустая строка — ключ ещё не использовался
+);
+
+CREATE INDEX index_api_keys_user ON api_keys (user_id);
@@ -0,0 +1,4 @@
+-- Роль пользователя: viewer читает задачи, editor меняет их, admin ещё управляет пользователями,
+-- веб-хуками и снимками БД. Зарегистрированные раньше пользователи сохраняют право менять свои задачи
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'editor';
+UPDATE users SET role = 'admin' WHERE login = 'admin';
@@ -0,0 +1,19 @@
+-- Второй фактор входа: секрет TOTP пользователя и одноразовые коды восстановления
+CREATE TABLE user_totp (
+     user_id INTEGER PRIMARY KEY REFERENCES users(id),
+     secret TEXT NOT NULL, -- base32
+     enabled_at TEXT NOT NULL DEFAULT '', -- пустая строка — подключение не завершено
+     last_step BIGINT NOT NULL DEFAULT 0, -- последний принятый шаг, код не принимается повторно
+     failed_attempts INTEGER NOT NULL DEFAULT 0,
+     locked_until TEXT NOT NULL DEFAULT '',
+     created_at TEXT NOT NULL
+);
+
+CREATE TABLE recovery_codes (
+     id SERIAL PRIMARY KEY,
+     user_id INTEGER NOT NULL REFERENCES users(id),
+     code_hash TEXT NOT NULL, -- sha256 кода
+     used_at TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX index_recovery_codes_user ON recovery_codes (user_id);
@@ -0,0 +1,3 @@
+-- Версия задачи сразу после выполнения: RestoreTask отменяет выполнение, только пока задачу после него не меняли.
+-- У записей, сделанных раньше, версия 0, их выполнение не отменяется
+ALTER TABLE task_completions ADD COLUMN task_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Полнотекстовый индекс задач есть только в SQLite (scheduler_fts), PostgreSQL ищет по подстроке через ILIKE.
+-- Миграция пустая, чтобы версии схемы у обоих драйверов совпадали
+SELECT 1;
@@ -0,0 +1,8 @@
+-- Куда пользователь получает напоминания о своих задачах, пустая строка — канал выключен
+CREATE TABLE notify_targets (
+     user_id INTEGER PRIMARY KEY REFERENCES users(id),
+     email TEXT NOT NULL DEFAULT '',
+     telegram_chat_id TEXT NOT NULL DEFAULT '',
+     webhook_url TEXT NOT NULL DEFAULT '',
+     updated_at TEXT NOT NULL
+);
@@ -0,0 +1,10 @@
+-- Таблица задач; IF NOT EXISTS позволяет принять базы, созданные до появления миграций
+CREATE TABLE IF NOT EXISTS scheduler (
+     id INTEGER PRIMARY KEY AUTOINCREMENT,
+     date TEXT CHECK(LENGTH(date) <= 8) NOT NULL,
+     title TEXT NOT NULL ,
+     comment TEXT,
+     repeat TEXT CHECK(LENGTH(repeat) <= 128)  -- строка до 128 символов
+);
+
+CREATE INDEX IF NOT EXISTS index_date ON scheduler (date);
@@ -0,0 +1,28 @@
+-- SQLite не удаляет колонки со ссылками на другие таблицы, поэтому таблицы пересоздаются
+DROP INDEX IF EXISTS index_user_date;
+
+CREATE TABLE scheduler_old (
+     id INTEGER PRIMARY KEY AUTOINCREMENT,
+     date TEXT CHECK(LENGTH(date) <= 8) NOT NULL,
+     title TEXT NOT NULL ,
+     comment TEXT,
+     repeat TEXT CHECK(LENGTH(repeat) <= 128)  -- строка до 128 символов
+);
+INSERT INTO scheduler_old (id, date, title, comment, repeat)
+SELECT id, date, title, comment, repeat FROM scheduler;
+DROP TABLE scheduler;
+ALTER TABLE scheduler_old RENAME TO scheduler;
+CREATE INDEX index_date ON scheduler (date);
+
+CREATE TABLE calendar_feeds_old (
+     id INTEGER PRIMARY KEY AUTOINCREMENT,
+     name TEXT NOT NULL,
+     token_hash TEXT NOT NULL UNIQUE, -- sha256 токена подписки
+     created_at TEXT NOT NULL
+);
+INSERT INTO calendar_feeds_old (id, name, token_hash, created_at)
+SELECT id, name, token_hash, created_at FROM calendar_feeds;
+DROP TABLE calendar_feeds;
+ALTER TABLE calendar_feeds_old RENAME TO calendar_feeds;
+
+DROP TABLE users;
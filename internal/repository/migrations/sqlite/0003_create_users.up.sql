@@ -0,0 +1,17 @@
+CREATE TABLE users (
+     id INTEGER PRIMARY KEY AUTOINCREMENT,
+     login TEXT NOT NULL UNIQUE,
+     password_hash TEXT NOT NULL DEFAULT '', -- пустой хэш — пароль из TODO_PASSWORD
+     created_at TEXT NOT NULL
+);
+
+-- Администратор по умолчанию, ему переходят задачи, созданные до появления пользователей
+INSERT INTO users (login, password_hash, created_at)
+VALUES ('admin', '', strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+
+ALTER TABLE scheduler ADD COLUMN user_id INTEGER REFERENCES users(id);
+UPDATE scheduler SET user_id = (SELECT id FROM users WHERE login = 'admin');
+CREATE INDEX index_user_date ON scheduler (user_id, date);
+
+ALTER TABLE calendar_feeds ADD COLUMN user_id INTEGER REFERENCES users(id);
+UPDATE calendar_feeds SET user_id = (SELECT id FROM users WHERE login = 'admin');
@@ -0,0 +1,4 @@
+-- Задачи из корзины удаляются окончательно
+DROP INDEX IF EXISTS index_user_deleted;
+DELETE FROM scheduler WHERE deleted_at IS NOT NULL;
+ALTER TABLE scheduler DROP COLUMN deleted_at;
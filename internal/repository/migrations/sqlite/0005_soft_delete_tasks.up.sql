@@ -0,0 +1,3 @@
+-- Удалённые задачи остаются в корзине до очистки; NULL — задача не удалена
+ALTER TABLE scheduler ADD COLUMN deleted_at TEXT; -- время удаления в UTC, RFC 3339
+CREATE INDEX index_user_deleted ON scheduler (user_id, deleted_at);
@@ -0,0 +1,29 @@
+-- Подписки пользователей на события задач. Секрет хранится открыто: им подписывается каждый запрос
+CREATE TABLE webhooks (
+     id INTEGER PRIMARY KEY AUTOINCREMENT,
+     user_id INTEGER NOT NULL REFERENCES users(id),
+     url TEXT NOT NULL,
+     secret TEXT NOT NULL,
+     events TEXT NOT NULL DEFAULT '', -- события через запятую, пустая строка — все события
+     created_at TEXT NOT NULL
+);
+
+CREATE INDEX index_webhooks_user ON webhooks (user_id);
+
+-- Очередь доставки событий и её журнал: запись остаётся после доставки или последней неудачной попытки
+CREATE TABLE webhook_deliveries (
+     id INTEGER PRIMARY KEY AUTOINCREMENT,
+     webhook_id INTEGER NOT NULL REFERENCES webhooks(id),
+     event TEXT NOT NULL,
+     payload TEXT NOT NULL, -- тело запроса, JSON
+     status TEXT NOT NULL, -- pending, delivered или failed
+     attempts INTEGER NOT NULL DEFAULT 0,
+     next_attempt_at TEXT NOT NULL DEFAULT '', -- время следующей попытки в UTC, RFC 3339, у pending
+     response_status INTEGER NOT NULL DEFAULT 0, -- код ответа последней попытки, 0 — ответа не было
+     last_error TEXT NOT NULL DEFAULT '',
+     created_at TEXT NOT NULL,
+     delivered_at TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX index_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX index_deliveries_webhook ON webhook_deliveries (webhook_id, id);
@@ -0,0 +1,16 @@
+-- Сессии входа: токен обновления хранится хэшем и меняется при каждом обновлении,
+-- предыдущий хэш остаётся, чтобы распознать повторное использование украденного токена
+CREATE TABLE sessions (
+     id INTEGER PRIMARY KEY AUTOINCREMENT,
+     user_id INTEGER NOT NULL REFERENCES users(id),
+     token_hash TEXT NOT NULL UNIQUE, -- sha256 текущего токена обновления
+     previous_hash TEXT NOT NULL DEFAULT '', -- sha256 токена до последнего обновления
+     credential TEXT NOT NULL, -- метка учётных данных на момент входа
+     created_at TEXT NOT NULL,
+     refreshed_at TEXT NOT NULL,
+     expires_at TEXT NOT NULL, -- все времена в UTC, RFC 3339
+     revoked_at TEXT NOT NULL DEFAULT '' -- пустая строка — сессия действует
+);
+
+CREATE INDEX index_sessions_user ON sessions (user_id);
+CREATE INDEX index_sessions_previous ON sessions (previous_hash);
@@ -0,0 +1 @@
+ALTER TABLE task_completions DROP COLUMN task_version;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS scheduler_fts_insert;
+DROP TRIGGER IF EXISTS scheduler_fts_delete;
+DROP TRIGGER IF EXISTS scheduler_fts_update;
+DROP TABLE IF EXISTS scheduler_fts;
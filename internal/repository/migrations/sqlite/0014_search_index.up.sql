@@ -0,0 +1,22 @@
+-- Полнотекстовый индекс задач для поиска: таблица FTS5 с внешним содержимым и триггеры синхронизации со scheduler.
+-- Базы, где индекс уже создавал прежний код вне миграций, его сохраняют, rebuild заполняет индекс заново
+CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(
+     title, comment,
+     content='scheduler', content_rowid='id',
+     tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_insert AFTER INSERT ON scheduler BEGIN
+     INSERT INTO scheduler_fts (rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;
+
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_delete AFTER DELETE ON scheduler BEGIN
+     INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+END;
+
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_update AFTER UPDATE OF title, comment ON scheduler BEGIN
+     INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+     INSERT INTO scheduler_fts (rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;
+
+INSERT INTO scheduler_fts (scheduler_fts) VALUES ('rebuild');
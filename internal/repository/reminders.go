@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go_final_project_avp/internal/notify"
+)
+
+// getPendingReminders задачи, о которых пора напомнить; %[1]s — колонка notify_targets с адресом в канале.
+const getPendingReminders = ` -- name: GetPendingReminders
+	SELECT s.id, s.date, s.title, s.comment, s.repeat, s.version, s.user_id, u.login, t.%[1]s
+    FROM scheduler s
+    JOIN users u ON u.id = s.user_id
+    JOIN notify_targets t ON t.user_id = s.user_id AND t.%[1]s <> ''
+    LEFT JOIN task_reminders r
+        ON r.task_id = s.id AND r.due_date = s.date AND r.lead_days = ? AND r.channel = ?
+    WHERE s.date = ? AND s.deleted_at IS NULL AND r.id IS NULL
+    ORDER BY s.user_id ASC, s.id ASC
+	`
+
+// targetColumns колонка notify_targets с адресом пользователя для каждого канала напоминаний.
+var targetColumns = map[string]string{
+	notify.ChannelSMTP:     "email",
+	notify.ChannelWebhook:  "webhook_url",
+	notify.ChannelTelegram: "telegram_chat_id",
+}
+
+// GetPendingReminders задачи на дату date, о которых ещё не напомнили за leadDays дней через канал channel.
+// Напоминание уходит владельцу задачи на его адрес в канале, задачи пользователей без адреса пропускаются.
+func (r *Repository) GetPendingReminders(date string, leadDays int, channel string) ([]notify.Reminder, error) {
+	ctx := context.Background()
+
+	column, ok := targetColumns[channel]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный канал напоминаний %q", channel)
+	}
+
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(fmt.Sprintf(getPendingReminders, column)), leadDays, channel, date)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	var list []notify.Reminder
+	for res.Next() {
+		rem := notify.Reminder{LeadDays: leadDays}
+		t := &rem.Task
+		if err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Version, &rem.UserId, &rem.Login, &rem.Target); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования напоминания res.Scan: %w", err)
+		}
+		list = append(list, rem)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+const markReminderSent = ` -- name: MarkReminderSent
+	INSERT INTO task_reminders
+	    (user_id, task_id, due_date, lead_days, channel, sent_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT DO NOTHING
+	`
+
+// MarkReminderSent отмечает напоминание о задаче отправленным через канал channel.
+// Повторная отметка того же напоминания ничего не меняет.
+func (r *Repository) MarkReminderSent(rem notify.Reminder, channel string, sentAt time.Time) error {
+	ctx := context.Background()
+
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(markReminderSent), rem.UserId, rem.Task.Id, rem.Task.Date,
+		rem.LeadDays, channel, sentAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("ошибка записи отметки о напоминании: %w", err)
+	}
+
+	return nil
+}
+
+const getNotifyTargets = ` -- name: GetNotifyTargets
+	SELECT email, telegram_chat_id, webhook_url, updated_at
+    FROM notify_targets
+    WHERE user_id = ?
+	`
+
+// GetNotifyTargets адреса пользователя для напоминаний, без сохранённых адресов — пустые.
+func (r *Repository) GetNotifyTargets(userID int64) (notify.Targets, error) {
+	ctx := context.Background()
+
+	var targets notify.Targets
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(getNotifyTargets), userID).
+		Scan(&targets.Email, &targets.TelegramChatID, &targets.WebhookURL, &targets.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notify.Targets{}, nil
+	}
+	if err != nil {
+		return notify.Targets{}, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+
+	return targets, nil
+}
+
+const setNotifyTargets = ` -- name: SetNotifyTargets
+	INSERT INTO notify_targets
+	    (user_id, email, telegram_chat_id, webhook_url, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE
+	SET email = excluded.email, telegram_chat_id = excluded.telegram_chat_id,
+	    webhook_url = excluded.webhook_url, updated_at = excluded.updated_at
+	`
+
+// SetNotifyTargets сохраняет адреса пользователя для напоминаний, прежние адреса заменяются целиком.
+func (r *Repository) SetNotifyTargets(userID int64, targets *notify.Targets, now time.Time) error {
+	ctx := context.Background()
+
+	targets.UpdatedAt = now.UTC().Format(time.RFC3339)
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(setNotifyTargets), userID, targets.Email, targets.TelegramChatID,
+		targets.WebhookURL, targets.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+var _ notify.Store = (*Repository)(nil)
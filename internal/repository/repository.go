@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	slogavp "github.com/Anatoly8853/slog-avp/v2"
 	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/search"
 	"go_final_project_avp/internal/tasks"
 
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,15 +16,45 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
+// sqliteBusyTimeout сколько миллисекунд запрос к файлу SQLite ждёт освобождения блокировки.
+const sqliteBusyTimeout = 5000
+
+// Поддерживаемые драйверы БД, по умолчанию SQLite. В сборку драйвера SQLite входит FTS5, на нём строится поиск задач.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// driverSQLiteLegacy прежнее имя драйвера SQLite в TODO_DB_DRIVER, принимается наравне с DriverSQLite.
+const driverSQLiteLegacy = "sqlite3"
+
+// IsSQLite значение TODO_DB_DRIVER выбирает SQLite, пустое значение — тоже.
+func IsSQLite(driver string) bool {
+	return driver == "" || driver == DriverSQLite || driver == driverSQLiteLegacy
+}
+
+// sqlx не знает имя драйвера sqlite и без регистрации не переводит параметры запросов.
+func init() {
+	sqlx.BindDriver(DriverSQLite, sqlx.QUESTION)
+}
+
+// MemoryDB значение TODO_DBFILE для БД SQLite в памяти.
+const MemoryDB = ":memory:"
+
 type Repository struct {
 	db  *sqlx.DB
 	app *slogavp.Application
 }
 
-const limit = 50
+// DefaultLimit количество задач в списке по умолчанию, NoLimit — без ограничения.
+const (
+	DefaultLimit = 50
+	NoLimit      = -1
+)
 
 func NewRepository(db *sqlx.DB, app *slogavp.Application) *Repository {
 	if db == nil {
@@ -31,8 +63,46 @@ func NewRepository(db *sqlx.DB, app *slogavp.Application) *Repository {
 	return &Repository{db: db, app: app}
 }
 
-// NewOpenDB подключение к БД.
+// NewOpenDB подключение к БД, выбранной параметром TODO_DB_DRIVER.
 func NewOpenDB(cfg config.Config) (db *sqlx.DB, err error) {
+	switch {
+	case IsSQLite(cfg.DBDriver):
+		if cfg.DBFile == MemoryDB {
+			return NewMemoryDB()
+		}
+		return openSQLite(cfg)
+	case cfg.DBDriver == DriverPostgres:
+		if cfg.DBDSN == "" {
+			return nil, fmt.Errorf("для драйвера %s требуется строка подключения TODO_DB_DSN", DriverPostgres)
+		}
+		db, err = sqlx.Connect(DriverPostgres, cfg.DBDSN)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка подключения к PostgreSQL: %w", err)
+		}
+		return db, nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый драйвер БД %q, ожидается %s или %s", cfg.DBDriver, DriverSQLite, DriverPostgres)
+	}
+}
+
+// NewMemoryDB БД SQLite в памяти процесса, исчезает при закрытии.
+// Подходит для тестов: у каждого вызова своя пустая БД, миграции применяет RunMigrations.
+func NewMemoryDB() (*sqlx.DB, error) {
+	db, err := sqlx.Connect(DriverSQLite, MemoryDB)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать базу данных в памяти: %w", err)
+	}
+	// У каждого соединения с :memory: своя БД, поэтому соединение одно и оно не закрывается
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	return db, nil
+}
+
+// openSQLite подключение к файлу БД SQLite, файл и директория создаются при отсутствии.
+func openSQLite(cfg config.Config) (db *sqlx.DB, err error) {
 	dbDir := filepath.Dir(cfg.DBFile)
 
 	// Проверяем, существует ли директория для базы данных
@@ -58,8 +128,9 @@ func NewOpenDB(cfg config.Config) (db *sqlx.DB, err error) {
 		}(file) // Закрываем файл после создания
 	}
 
-	// Подключаемся к базе данных SQLite
-	db, err = sqlx.Connect("sqlite3", cfg.DBFile)
+	// Подключаемся к базе данных SQLite. Транзакции сразу берут блокировку на запись,
+	// а конкурирующие запросы ждут её до sqliteBusyTimeout вместо ошибки "database is locked"
+	db, err = sqlx.Connect(DriverSQLite, fmt.Sprintf("%s?_txlock=immediate&_pragma=busy_timeout(%d)", cfg.DBFile, sqliteBusyTimeout))
 	if err != nil {
 		return nil, fmt.Errorf("не удалось подключиться к базе данных: %w", err)
 	}
@@ -67,7 +138,7 @@ func NewOpenDB(cfg config.Config) (db *sqlx.DB, err error) {
 	return db, nil
 }
 
-// RunMigrations миграция таблиц в БД если отсутствуют.
+// RunMigrations применяет к БД все неприменённые версионные миграции.
 func (r *Repository) RunMigrations(cfg config.Config) error {
 	dbfile := "internal/app/scheduler.db"
 
@@ -75,47 +146,52 @@ func (r *Repository) RunMigrations(cfg config.Config) error {
 		dbfile = cfg.DBFile
 	}
 
-	if _, err := os.Stat(dbfile); os.IsNotExist(err) {
-		r.app.Log.Infof("Файл базы данных не найден, создаём новый: %s", cfg.DBFile)
-	}
-
-	ctx := context.Background()
-	createTableScheduler := `CREATE TABLE IF NOT EXISTS scheduler (
-     id INTEGER PRIMARY KEY AUTOINCREMENT,    
-     date TEXT CHECK(LENGTH(date) <= 8) NOT NULL,
-     title TEXT NOT NULL ,
-     comment TEXT,
-     repeat TEXT CHECK(LENGTH(repeat) <= 128)  -- строка до 128 символов
-);`
-	_, err := r.db.ExecContext(ctx, createTableScheduler)
-	if err != nil {
-		return err
+	if r.db.DriverName() == DriverSQLite && dbfile != MemoryDB {
+		if _, err := os.Stat(dbfile); os.IsNotExist(err) {
+			r.app.Log.Infof("Файл базы данных не найден, создаём новый: %s", cfg.DBFile)
+		}
 	}
 
-	createIndexDate := "CREATE INDEX IF NOT EXISTS index_date ON scheduler (date);"
+	return r.MigrateUp(0)
+}
 
-	//index creation
-	_, err = r.db.ExecContext(ctx, createIndexDate)
-	if err != nil {
-		return err
+// limitArg значение для LIMIT, отрицательный limit означает без ограничения:
+// SQLite понимает LIMIT -1, а PostgreSQL — LIMIT NULL.
+func (r *Repository) limitArg(limit int) any {
+	if limit < 0 && r.db.DriverName() == DriverPostgres {
+		return nil
 	}
+	return limit
+}
 
-	return nil
+// likeOp оператор поиска по подстроке без учёта регистра:
+// LIKE в SQLite и так не учитывает регистр латиницы, в PostgreSQL для этого нужен ILIKE.
+func (r *Repository) likeOp() string {
+	if r.db.DriverName() == DriverPostgres {
+		return "ILIKE"
+	}
+	return "LIKE"
 }
 
 const getTasks = ` -- name: GetTasks
-	SELECT id, date, title, comment, repeat
+	SELECT id, date, title, comment, repeat, version
     FROM scheduler
-    ORDER BY date ASC
-    LIMIT $1
+    WHERE user_id = ? AND deleted_at IS NULL AND (date, id) > (?, ?)
+    ORDER BY date ASC, id ASC
+    LIMIT ?
 	`
 
-// GetTasks получаем список ближайших задач.
-func (r *Repository) GetTasks() ([]tasks.Task, error) {
+// GetTasks получаем список ближайших задач пользователя после курсора after, не больше limit.
+// Задачи упорядочены по (date, id), поэтому страницы не пересекаются и не теряют задач с одной датой.
+func (r *Repository) GetTasks(userID int64, after Cursor, limit int) ([]tasks.Task, error) {
 	ctx := context.Background()
 
+	if after.Rank != 0 {
+		return nil, ErrCursor
+	}
+
 	// Выполняем запрос к БД
-	res, err := r.db.QueryContext(ctx, getTasks, limit)
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(getTasks), userID, after.Date, after.Id, r.limitArg(limit))
 	if err != nil {
 		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
 	}
@@ -130,7 +206,7 @@ func (r *Repository) GetTasks() ([]tasks.Task, error) {
 	for res.Next() {
 		var t tasks.Task
 		// Сканируем результат в структуру
-		if err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat); err != nil {
+		if err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Version); err != nil {
 			return nil, fmt.Errorf("ошибка сканирования задачи res.Scan: %w", err)
 		}
 		tasksList = append(tasksList, t)
@@ -144,14 +220,50 @@ func (r *Repository) GetTasks() ([]tasks.Task, error) {
 	return tasksList, nil
 }
 
+// countTasks запрос числа задач пользователя, %s — условие интервала from..to.
+const countTasks = ` -- name: CountTasks
+	SELECT COUNT(*) AS total,
+	       COALESCE(SUM(CASE WHEN %s THEN 1 ELSE 0 END), 0) AS in_range,
+	       COALESCE(SUM(CASE WHEN date < ? THEN 1 ELSE 0 END), 0) AS overdue,
+	       COALESCE(SUM(CASE WHEN COALESCE(repeat, '') <> '' THEN 1 ELSE 0 END), 0) AS repeating
+	FROM scheduler
+	WHERE user_id = ? AND deleted_at IS NULL
+	`
+
+// CountTasks число задач пользователя: всего, в интервале from..to, просроченных на дату today,
+// повторяющихся и разовых. Пустые from и to не ограничивают интервал.
+func (r *Repository) CountTasks(userID int64, today, from, to string) (tasks.Counts, error) {
+	ctx := context.Background()
+
+	rangeCond := "1=1"
+	var args []interface{}
+	if from != "" {
+		rangeCond += " AND date >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		rangeCond += " AND date <= ?"
+		args = append(args, to)
+	}
+	args = append(args, today, userID)
+
+	var counts tasks.Counts
+	if err := r.db.GetContext(ctx, &counts, r.db.Rebind(fmt.Sprintf(countTasks, rangeCond)), args...); err != nil {
+		return tasks.Counts{}, fmt.Errorf("ошибка подсчёта задач: %w", err)
+	}
+	counts.Once = counts.Total - counts.Repeating
+
+	return counts, nil
+}
+
 const getTasksId = ` -- name: GetTasksId
-	SELECT id, date, title, comment, repeat
+	SELECT id, date, title, comment, repeat, version
     FROM scheduler
-    WHERE id = $1
+    WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 	`
 
-// GetTasksId получаем задачу по id.
-func (r *Repository) GetTasksId(id string) (tasks.Task, error) {
+// GetTasksId получаем задачу пользователя по id.
+func (r *Repository) GetTasksId(userID int64, id string) (tasks.Task, error) {
 	ctx := context.Background()
 	t := tasks.Task{}
 
@@ -160,9 +272,9 @@ func (r *Repository) GetTasksId(id string) (tasks.Task, error) {
 		return t, fmt.Errorf("не удается преобразовать id - %v : %v", id, err)
 	}
 
-	res := r.db.QueryRowContext(ctx, getTasksId, ids)
+	res := r.db.QueryRowContext(ctx, r.db.Rebind(getTasksId), ids, userID)
 
-	err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat)
+	err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Version)
 
 	if t.Id == "" {
 		return t, fmt.Errorf("задача не найдена c id - %v", id)
@@ -173,59 +285,67 @@ func (r *Repository) GetTasksId(id string) (tasks.Task, error) {
 
 const createTask = ` -- name: CreateTask
 	INSERT INTO scheduler 
-	    (date, title, comment, repeat)
-	VALUES ($1, $2, $3, $4)
+	    (date, title, comment, repeat, user_id)
+	VALUES (?, ?, ?, ?, ?)
+	RETURNING id
 	`
 
-// CreateTask добавляем задачи в бд.
-func (r *Repository) CreateTask(task *tasks.Task) (int64, error) {
+// CreateTask добавляем задачи пользователя в бд.
+func (r *Repository) CreateTask(userID int64, task *tasks.Task) (int64, error) {
 	ctx := context.Background()
-	res, err := r.db.ExecContext(ctx, createTask, task.Date, task.Title, task.Comment, task.Repeat)
-	if err != nil {
-		return 0, fmt.Errorf("ошибка выполнения запроса  ExecContext: %s", err)
-	}
-
-	id, err := res.LastInsertId()
+	var id int64
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(createTask), task.Date, task.Title, task.Comment, task.Repeat, userID).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("ошибка нет id res.LastInsertId(): %s", err)
+		return 0, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %s", err)
 	}
 
 	return id, nil
 }
 
-// GetSearch выбрать задачи через строку поиска.
-func (r *Repository) GetSearch(search string) ([]tasks.Task, error) {
+// GetSearch выбрать задачи пользователя по условиям поиска после курсора after, не больше limit.
+// Если в поиске есть слова, в SQLite задачи ранжируются по релевантности, иначе идут по дате.
+func (r *Repository) GetSearch(userID int64, q search.Query, after Cursor, limit int) ([]tasks.Task, error) {
 	ctx := context.Background()
 
+	compiled := r.compileQuery(q, r.hasFTS())
+	if compiled.match != "" {
+		return r.searchFTS(ctx, userID, compiled, after, limit)
+	}
+
 	// Формируем запрос
 	//WHERE 1=1 является трюком для упрощения добавления дополнительных условий в запрос
 	//Здесь, если условия добавляются динамически, они всегда будут присоединены
 	//через AND, что упрощает процесс формирования запросов.
-	query := "SELECT id, date, title, comment, repeat FROM scheduler WHERE 1=1"
+	query := "SELECT id, date, title, comment, repeat, version FROM scheduler WHERE 1=1"
 	var args []interface{}
 
-	// Если указан search, проверяем его
-	if search != "" {
-		// Попытка парсинга как даты
-		if date, err := time.Parse(tasks.DisplayDateFormat, search); err == nil {
-			// Если search является датой
-			query += " AND date = ?"
-			args = append(args, date.Format(tasks.TimeFormat))
-		} else {
-			// Если search — это строка, ищем по заголовку и комментарию
-			query += " AND (title LIKE ? OR comment LIKE ?)"
-			searchTerm := "%" + search + "%"
-			args = append(args, searchTerm, searchTerm)
-		}
+	// Только задачи пользователя, без корзины
+	query += " AND user_id = ? AND deleted_at IS NULL"
+	args = append(args, userID)
+
+	// Условия поиска
+	for _, cond := range compiled.where {
+		query += " AND " + cond
+	}
+	args = append(args, compiled.args...)
+
+	// В поиске только слова с минусом: исключаем найденные индексом задачи
+	if compiled.exclude != "" {
+		query += " AND id NOT IN (SELECT rowid FROM scheduler_fts WHERE scheduler_fts MATCH ?)"
+		args = append(args, compiled.exclude)
 	}
 
-	// Добавляем сортировку по дате
-	query += " ORDER BY date ASC LIMIT ?"
+	if after.Rank != 0 {
+		return nil, ErrCursor
+	}
+	// Продолжаем после курсора, сортировка по дате и id
+	query += " AND (date, id) > (?, ?) ORDER BY date ASC, id ASC LIMIT ?"
+	args = append(args, after.Date, after.Id)
 
-	args = append(args, limit)
+	args = append(args, r.limitArg(limit))
 
 	// Выполнение запроса
-	res, err := r.db.QueryContext(ctx, query, args...)
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(query), args...)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
 	}
@@ -240,7 +360,7 @@ func (r *Repository) GetSearch(search string) ([]tasks.Task, error) {
 
 	for res.Next() {
 		var t tasks.Task
-		if err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat); err != nil {
+		if err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Version); err != nil {
 			return nil, fmt.Errorf("ошибка сканирования задачи res.Scan: %w", err)
 		}
 		task = append(task, t)
@@ -258,73 +378,189 @@ func (r *Repository) GetSearch(search string) ([]tasks.Task, error) {
 	return task, nil
 }
 
+// ErrTaskNotFound у пользователя нет такой задачи, или она в корзине.
+var ErrTaskNotFound = errors.New("задача не найдена")
+
+// ErrVersionConflict задачу изменил другой запрос после того, как клиент её прочитал.
+var ErrVersionConflict = errors.New("задача изменена другим запросом, обновите её и повторите")
+
+// ConflictError конфликт версий: версия из запроса устарела, Current — задача в БД сейчас.
+type ConflictError struct {
+	Current tasks.Task
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: задача %s, версия %d", ErrVersionConflict, e.Current.Id, e.Current.Version)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
 const updateTask = ` -- name: UpdateTask
 	UPDATE scheduler 
-    SET date = $1, 
-        title = $2, 
-        comment = $3, 
-        repeat = $4 
-    WHERE id = $5
+    SET date = ?, 
+        title = ?, 
+        comment = ?, 
+        repeat = ?,
+        version = version + 1
+    WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+    RETURNING version
 	`
 
-// UpdateTask обновляет данные в БД, если задача с таким ID существует.
-func (r *Repository) UpdateTask(task *tasks.Task) error {
+// UpdateTask обновляет данные в БД, если задача пользователя с таким ID существует.
+// Если task.Version не 0 и не совпадает с версией в БД, возвращается *ConflictError с текущей задачей.
+// После обновления task.Version — новая версия.
+func (r *Repository) UpdateTask(userID int64, task *tasks.Task) error {
 	ctx := context.Background()
 
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		return r.updateInTx(ctx, tx, userID, task)
+	})
+}
+
+// updateInTx изменение задачи пользователя в транзакции tx, см. UpdateTask.
+func (r *Repository) updateInTx(ctx context.Context, tx *sql.Tx, userID int64, task *tasks.Task) error {
 	// Выполняем запрос на обновление
-	result, err := r.db.ExecContext(ctx, updateTask, task.Date, task.Title, task.Comment, task.Repeat, task.Id)
+	err := tx.QueryRowContext(ctx, r.db.Rebind(updateTask), task.Date, task.Title, task.Comment, task.Repeat,
+		task.Id, userID, task.Version, task.Version).Scan(&task.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Задачи нет или версия устарела
+		return r.conflictInTx(ctx, tx, userID, task.Id)
+	}
 	if err != nil {
-		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		return fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
 	}
 
-	// Проверяем количество затронутых строк
-	rowsAffected, err := result.RowsAffected()
+	return nil
+}
+
+// getTaskInTx задача пользователя не из корзины, прочитанная в транзакции tx.
+// Если задачи нет, ошибка оборачивает ErrTaskNotFound.
+func (r *Repository) getTaskInTx(ctx context.Context, tx *sql.Tx, userID int64, id string) (tasks.Task, error) {
+	var task tasks.Task
+	err := tx.QueryRowContext(ctx, r.db.Rebind(getTasksId), id, userID).
+		Scan(&task.Id, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return task, fmt.Errorf("%w: id %s", ErrTaskNotFound, id)
+	}
 	if err != nil {
-		return fmt.Errorf("ошибка получения затронутых строк: %w", err)
+		return task, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
 	}
+	return task, nil
+}
 
-	// Если ни одна строка не была обновлена, возвращаем ошибку
-	if rowsAffected == 0 {
-		return fmt.Errorf("задача с id %d не найдена", task.Id)
+// conflictInTx ошибка для изменения, не затронувшего задачу: *ConflictError с текущей задачей,
+// если она есть, иначе ошибка "не найдена".
+func (r *Repository) conflictInTx(ctx context.Context, tx *sql.Tx, userID int64, id string) error {
+	current, err := r.getTaskInTx(ctx, tx, userID, id)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return &ConflictError{Current: current}
 }
 
-const doneTask = ` -- name: DoneTask
+const (
+	doneTask = ` -- name: DoneTask
 	UPDATE scheduler 
-    SET date = $1
-    WHERE id = $2
+    SET date = ?, version = version + 1
+    WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND version = ?
 	`
 
-// DoneTask отметка о выполнении.
-func (r *Repository) DoneTask(taskDate string, id string) error {
+	doneOnceTask = ` -- name: DoneOnceTask
+	UPDATE scheduler
+    SET deleted_at = ?, version = version + 1
+    WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND version = ?
+	`
+)
+
+// DoneTask отметка о выполнении задачи пользователя в момент now. В одной транзакции читает задачу,
+// вычисляет следующую дату по правилу повторения, переносит на неё задачу и записывает выполнение
+// с заметкой note в историю. Разовая задача переносится в корзину с временем удаления, равным времени
+// выполнения, — по нему RestoreTask отменяет выполнение.
+// Если version не 0 и не совпадает с версией задачи, возвращается *ConflictError с текущей задачей.
+func (r *Repository) DoneTask(userID int64, id string, version int64, note string, now time.Time) (tasks.Completion, error) {
 	ctx := context.Background()
-	result, err := r.db.ExecContext(ctx, doneTask, taskDate, id)
+
+	var completion tasks.Completion
+	err := r.inTx(ctx, func(tx *sql.Tx) (err error) {
+		completion, _, err = r.doneInTx(ctx, tx, userID, id, version, note, now)
+		return err
+	})
+	if err != nil {
+		return tasks.Completion{}, err
+	}
+
+	return completion, nil
+}
+
+// doneInTx выполнение задачи в транзакции tx, см. DoneTask.
+// Кроме записи в историю возвращает задачу после выполнения, у разовой задачи пустая дата.
+func (r *Repository) doneInTx(ctx context.Context, tx *sql.Tx, userID int64, id string, version int64, note string,
+	now time.Time) (tasks.Completion, tasks.Task, error) {
+	task, err := r.getTaskInTx(ctx, tx, userID, id)
+	if err != nil {
+		return tasks.Completion{}, task, err
+	}
+	if version != 0 && version != task.Version {
+		return tasks.Completion{}, task, &ConflictError{Current: task}
+	}
+
+	nextDate, err := tasks.DoneDate(task, now)
+	if err != nil {
+		return tasks.Completion{}, task, fmt.Errorf("ошибка вычисления следующей даты задачи %s: %w", id, err)
+	}
+
+	completion := tasks.Completion{
+		TaskId:        task.Id,
+		Title:         task.Title,
+		ScheduledDate: task.Date,
+		CompletedAt:   now.UTC().Format(time.RFC3339),
+		Note:          note,
+	}
+
+	// Условие на прочитанную версию не даст применить выполнение дважды,
+	// если задачу успел изменить параллельный запрос
+	var result sql.Result
+	if nextDate == "" {
+		result, err = tx.ExecContext(ctx, r.db.Rebind(doneOnceTask), completion.CompletedAt, id, userID, task.Version)
+	} else {
+		result, err = tx.ExecContext(ctx, r.db.Rebind(doneTask), nextDate, id, userID, task.Version)
+	}
 	if err != nil {
-		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		return tasks.Completion{}, task, fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
 	}
 	// Проверяем количество затронутых строк
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("ошибка получения затронутых строк: %w", err)
+		return tasks.Completion{}, task, fmt.Errorf("ошибка получения затронутых строк: %w", err)
 	}
-
-	// Если ни одна строка не была обновлена, возвращаем ошибку
 	if rowsAffected == 0 {
-		return fmt.Errorf("задача с id %d не найдена", id)
+		return tasks.Completion{}, task, r.conflictInTx(ctx, tx, userID, id)
 	}
+	task.Date = nextDate
+	task.Version++
 
-	return nil
+	var completionID int64
+	err = tx.QueryRowContext(ctx, r.db.Rebind(createCompletion), userID, completion.TaskId, completion.Title,
+		completion.ScheduledDate, completion.CompletedAt, completion.Note, task.Version).Scan(&completionID)
+	if err != nil {
+		return tasks.Completion{}, task, fmt.Errorf("ошибка записи в историю выполнения: %w", err)
+	}
+	completion.Id = strconv.FormatInt(completionID, 10)
+
+	return completion, task, nil
 }
 
 const deleteTask = ` -- name: DeleteTask
-	DELETE FROM scheduler 
-	       WHERE id = $1
+	UPDATE scheduler
+	SET deleted_at = ?, version = version + 1
+	WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+	RETURNING version
 `
 
-// DeleteTask удаляем задачи из БД.
-func (r *Repository) DeleteTask(id string) error {
+// DeleteTask переносим задачу пользователя в корзину, окончательно её удаляет PurgeTrash.
+func (r *Repository) DeleteTask(userID int64, id string) error {
 	ctx := context.Background()
 
 	ids, err := strconv.Atoi(id)
@@ -332,18 +568,14 @@ func (r *Repository) DeleteTask(id string) error {
 		return fmt.Errorf("не удается преобразовать id - %v : %v", id, err)
 	}
 
-	res, err := r.db.ExecContext(ctx, deleteTask, ids)
-	if err != nil {
-		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	var version int64
+	err = r.db.QueryRowContext(ctx, r.db.Rebind(deleteTask), time.Now().UTC().Format(time.RFC3339), ids, userID, 0, 0).
+		Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("ошибка нет такого id: %v", id)
 	}
-
-	count, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("ошибка res.RowsAffected(): %s", err)
-	}
-
-	if count == 0 {
-		return fmt.Errorf("ошибка нет такого id: %v", id)
+		return fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
 	}
 
 	return nil
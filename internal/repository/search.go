@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"go_final_project_avp/internal/search"
+	"go_final_project_avp/internal/tasks"
+)
+
+// ErrCursor курсор не подходит к запросу, например курсор поиска передан в обычный список.
+var ErrCursor = errors.New("курсор не подходит к запросу")
+
+// compiledQuery строка поиска, переведённая в параметризованный SQL.
+type compiledQuery struct {
+	where   []string // условия на колонки scheduler, объединяются через AND
+	args    []any    // параметры условий where
+	match   string   // выражение MATCH FTS5 для ранжированного поиска, пустое без слов
+	exclude string   // выражение MATCH исключаемых задач, если в поиске только слова с минусом
+}
+
+// compileQuery переводит условия поиска в SQL. Слова и условия title:, comment: при fts
+// попадают в выражение MATCH полнотекстового индекса, иначе ищутся по подстроке.
+// Значения передаются только параметрами, в текст запроса попадают лишь известные колонки и операторы.
+func (r *Repository) compileQuery(q search.Query, fts bool) compiledQuery {
+	var (
+		c                  compiledQuery
+		positive, negative []string
+	)
+
+	for _, term := range q.Terms {
+		var (
+			cond string
+			args []any
+		)
+
+		switch term.Field {
+		case search.FieldText, search.FieldTitle, search.FieldComment:
+			if fts {
+				if term.Negate {
+					negative = append(negative, ftsTerm(term))
+				} else {
+					positive = append(positive, ftsTerm(term))
+				}
+				continue
+			}
+			cond, args = r.likeCondition(term)
+		case search.FieldDate:
+			cond, args = "date "+dateOperators[term.Op]+" ?", []any{term.Value}
+		case search.FieldRepeat:
+			// Одна буква — вид правила: "w" и "w 1,3"
+			if len(term.Value) == 1 {
+				cond, args = "(repeat = ? OR repeat LIKE ?)", []any{term.Value, term.Value + " %"}
+			} else {
+				cond, args = "repeat = ?", []any{term.Value}
+			}
+		case search.FieldHas:
+			cond = "COALESCE(repeat, '') <> ''"
+			if term.Value == string(search.FieldComment) {
+				cond = "COALESCE(comment, '') <> ''"
+			}
+		default:
+			continue
+		}
+
+		if term.Negate {
+			cond = "NOT (" + cond + ")"
+		}
+		c.where = append(c.where, cond)
+		c.args = append(c.args, args...)
+	}
+
+	if len(positive) > 0 {
+		c.match = "(" + strings.Join(positive, " AND ") + ")"
+		for _, expr := range negative {
+			c.match += " NOT " + expr
+		}
+	} else if len(negative) > 0 {
+		c.exclude = strings.Join(negative, " OR ")
+	}
+
+	return c
+}
+
+// dateOperators операторы SQL для сравнения даты, ключи — все операторы search.Op.
+var dateOperators = map[search.Op]string{
+	search.OpEq: "=",
+	search.OpLt: "<",
+	search.OpLe: "<=",
+	search.OpGt: ">",
+	search.OpGe: ">=",
+}
+
+// ftsTerm условие для MATCH FTS5. Значение берётся в кавычки,
+// поэтому символы синтаксиса FTS5 в поиске не ломают запрос.
+func ftsTerm(term search.Term) string {
+	expr := `"` + strings.ReplaceAll(term.Value, `"`, `""`) + `"`
+	if !term.Phrase {
+		expr += "*"
+	}
+	switch term.Field {
+	case search.FieldTitle:
+		expr = "title : " + expr
+	case search.FieldComment:
+		expr = "comment : " + expr
+	}
+	return expr
+}
+
+// likeCondition условие поиска по подстроке для БД без полнотекстового индекса, без учёта границ слов.
+func (r *Repository) likeCondition(term search.Term) (string, []any) {
+	pattern := "%" + term.Value + "%"
+	switch term.Field {
+	case search.FieldTitle:
+		return fmt.Sprintf("title %s ?", r.likeOp()), []any{pattern}
+	case search.FieldComment:
+		return fmt.Sprintf("COALESCE(comment, '') %s ?", r.likeOp()), []any{pattern}
+	default:
+		return fmt.Sprintf("(title %[1]s ? OR COALESCE(comment, '') %[1]s ?)", r.likeOp()), []any{pattern, pattern}
+	}
+}
+
+// hasFTS у БД есть полнотекстовый индекс scheduler_fts. Его создаёт миграция 0014_search_index для SQLite,
+// в PostgreSQL поиск идёт по подстроке.
+func (r *Repository) hasFTS() bool {
+	return r.db.DriverName() == DriverSQLite
+}
+
+// searchFTS запрос полнотекстового поиска, %s — дополнительные условия поиска, каждое с AND на конце.
+const searchFTS = ` -- name: SearchFTS
+	SELECT id, date, title, comment, repeat, version, score FROM (
+	    SELECT s.id, s.date, s.title, s.comment, s.repeat, s.version,
+	           bm25(scheduler_fts, 10.0, 1.0) AS score
+	    FROM scheduler_fts
+	    JOIN scheduler s ON s.id = scheduler_fts.rowid
+	    WHERE scheduler_fts MATCH ? AND s.user_id = ? AND s.deleted_at IS NULL
+	)
+	WHERE %s(score, id) > (?, ?)
+	ORDER BY score ASC, id ASC
+	LIMIT ?
+	`
+
+// searchFTS полнотекстовый поиск задач пользователя, лучшие совпадения первыми.
+// Заголовок весит больше комментария, страницы листаются по (rank, id).
+func (r *Repository) searchFTS(ctx context.Context, userID int64, c compiledQuery, after Cursor, limit int) ([]tasks.Task, error) {
+	if after.Date != "" {
+		return nil, ErrCursor
+	}
+	// Чем меньше bm25, тем лучше совпадение; без курсора начинаем с наименьшего значения
+	rank := after.Rank
+	if after.IsZero() {
+		rank = -math.MaxFloat64
+	}
+
+	var conds string
+	for _, cond := range c.where {
+		conds += cond + " AND "
+	}
+	args := append([]any{c.match, userID}, c.args...)
+	args = append(args, rank, after.Id, r.limitArg(limit))
+
+	res, err := r.db.QueryContext(ctx, fmt.Sprintf(searchFTS, conds), args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	list := []tasks.Task{}
+	for res.Next() {
+		var t tasks.Task
+		if err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Version, &t.Rank); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования задачи res.Scan: %w", err)
+		}
+		list = append(list, t)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
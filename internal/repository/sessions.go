@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"database/sql"
+	"go_final_project_avp/internal/users"
+
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionNotFound сессии с таким id или токеном нет.
+var ErrSessionNotFound = errors.New("сессия не найдена")
+
+// ErrSessionRotated токен обновления уже заменён другим запросом.
+var ErrSessionRotated = errors.New("токен обновления уже использован")
+
+const createSession = ` -- name: CreateSession
+	INSERT INTO sessions
+	    (user_id, token_hash, credential, created_at, refreshed_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	RETURNING id
+	`
+
+// CreateSession сохраняем новую сессию входа, действующую до expires.
+func (r *Repository) CreateSession(session *users.Session, now, expires time.Time) (int64, error) {
+	ctx := context.Background()
+
+	session.CreatedAt = now.UTC().Format(time.RFC3339)
+	session.RefreshedAt = session.CreatedAt
+	session.ExpiresAt = expires.UTC().Format(time.RFC3339)
+	var id int64
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(createSession), session.UserId, session.TokenHash, session.Credential,
+		session.CreatedAt, session.RefreshedAt, session.ExpiresAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+	session.Id = id
+
+	return id, nil
+}
+
+const getSession = ` -- name: GetSession
+	SELECT id, user_id, token_hash, previous_hash, credential, created_at, refreshed_at, expires_at, revoked_at
+    FROM sessions
+    WHERE id = ?
+	`
+
+// GetSession получаем сессию по id.
+func (r *Repository) GetSession(id int64) (users.Session, error) {
+	return r.getSession(getSession, id)
+}
+
+const getSessionByToken = ` -- name: GetSessionByToken
+	SELECT id, user_id, token_hash, previous_hash, credential, created_at, refreshed_at, expires_at, revoked_at
+    FROM sessions
+    WHERE token_hash = ? OR previous_hash = ?
+    LIMIT 1
+	`
+
+// GetSessionByToken получаем сессию по хэшу текущего или предыдущего токена обновления.
+func (r *Repository) GetSessionByToken(tokenHash string) (users.Session, error) {
+	return r.getSession(getSessionByToken, tokenHash, tokenHash)
+}
+
+// getSession выборка одной сессии.
+func (r *Repository) getSession(query string, args ...any) (users.Session, error) {
+	ctx := context.Background()
+	s := users.Session{}
+
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(query), args...).Scan(&s.Id, &s.UserId, &s.TokenHash, &s.PreviousHash,
+		&s.Credential, &s.CreatedAt, &s.RefreshedAt, &s.ExpiresAt, &s.RevokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrSessionNotFound
+	}
+	if err != nil {
+		return s, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+
+	return s, nil
+}
+
+const getSessions = ` -- name: GetSessions
+	SELECT id, user_id, token_hash, previous_hash, credential, created_at, refreshed_at, expires_at, revoked_at
+    FROM sessions
+    WHERE user_id = ? AND revoked_at = '' AND expires_at > ?
+    ORDER BY id ASC
+	`
+
+// GetSessions действующие сессии пользователя.
+func (r *Repository) GetSessions(userID int64, now time.Time) ([]users.Session, error) {
+	ctx := context.Background()
+
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(getSessions), userID, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	list := []users.Session{}
+	for res.Next() {
+		s := users.Session{}
+		err = res.Scan(&s.Id, &s.UserId, &s.TokenHash, &s.PreviousHash, &s.Credential, &s.CreatedAt, &s.RefreshedAt, &s.ExpiresAt, &s.RevokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования сессии res.Scan: %w", err)
+		}
+		list = append(list, s)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+const rotateSession = ` -- name: RotateSession
+	UPDATE sessions
+	SET token_hash = ?, previous_hash = token_hash, refreshed_at = ?, expires_at = ?
+	WHERE id = ? AND token_hash = ? AND revoked_at = ''
+	`
+
+// RotateSession заменяем токен обновления oldHash на newHash и продлеваем сессию до expires.
+// Если токен уже заменён параллельным запросом или сессия отозвана, возвращает ErrSessionRotated.
+func (r *Repository) RotateSession(id int64, oldHash, newHash string, now, expires time.Time) error {
+	ctx := context.Background()
+
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(rotateSession), newHash, now.UTC().Format(time.RFC3339),
+		expires.UTC().Format(time.RFC3339), id, oldHash)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+	}
+	if count == 0 {
+		return ErrSessionRotated
+	}
+
+	return nil
+}
+
+const revokeSession = ` -- name: RevokeSession
+	UPDATE sessions
+	SET revoked_at = ?
+	WHERE id = ? AND user_id = ? AND revoked_at = ''
+	`
+
+// RevokeSession отзываем сессию пользователя. Повторный отзыв не считается ошибкой.
+func (r *Repository) RevokeSession(userID, id int64, now time.Time) error {
+	ctx := context.Background()
+
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(revokeSession), now.UTC().Format(time.RFC3339), id, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+const revokeUserSessions = ` -- name: RevokeUserSessions
+	UPDATE sessions
+	SET revoked_at = ?
+	WHERE user_id = ? AND revoked_at = ''
+	`
+
+// RevokeUserSessions отзываем все сессии пользователя, возвращает их число.
+func (r *Repository) RevokeUserSessions(userID int64, now time.Time) (int64, error) {
+	ctx := context.Background()
+
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(revokeUserSessions), now.UTC().Format(time.RFC3339), userID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+	}
+
+	return count, nil
+}
+
+const purgeSessions = ` -- name: PurgeSessions
+	DELETE FROM sessions WHERE expires_at < ?
+	`
+
+// PurgeSessions удаляет сессии всех пользователей, истёкшие раньше before.
+// Отозванные сессии хранятся до истечения, чтобы повторное использование их токенов распознавалось.
+func (r *Repository) PurgeSessions(before time.Time) (int64, error) {
+	ctx := context.Background()
+
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(purgeSessions), before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка очистки сессий: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"time"
+
+	"go_final_project_avp/internal/calendar"
+	"go_final_project_avp/internal/notify"
+	"go_final_project_avp/internal/search"
+	"go_final_project_avp/internal/tasks"
+	"go_final_project_avp/internal/users"
+	"go_final_project_avp/internal/webhooks"
+)
+
+// TaskStore хранилище задач пользователя.
+type TaskStore interface {
+	GetTasks(userID int64, after Cursor, limit int) ([]tasks.Task, error)
+	GetTasksId(userID int64, id string) (tasks.Task, error)
+	CreateTask(userID int64, task *tasks.Task) (int64, error)
+	GetSearch(userID int64, q search.Query, after Cursor, limit int) ([]tasks.Task, error)
+	CountTasks(userID int64, today, from, to string) (tasks.Counts, error)
+	UpdateTask(userID int64, task *tasks.Task) error
+	DoneTask(userID int64, id string, version int64, note string, now time.Time) (tasks.Completion, error)
+	GetCompletions(userID int64, taskID string, from, to time.Time) ([]tasks.Completion, error)
+	DeleteTask(userID int64, id string) error
+	GetTrash(userID int64) ([]tasks.Task, error)
+	RestoreTask(userID int64, id string, version int64) error
+	PurgeTrash(before time.Time) (int64, error)
+	Batch(userID int64, ops []tasks.BatchOp, now time.Time) ([]tasks.BatchResult, error)
+	ExportTasks(userID int64, fn func(tasks.Task) error) error
+	ImportTasks(userID int64, list []tasks.Task, upsert, dryRun bool) ([]tasks.ImportResult, error)
+}
+
+// UserStore хранилище учётных записей.
+type UserStore interface {
+	CreateUser(user *users.User) (int64, error)
+	GetUserByLogin(login string) (users.User, error)
+	GetUserById(id int64) (users.User, error)
+	GetUsers() ([]users.User, error)
+	SetUserRole(id int64, role string, now time.Time) error
+}
+
+// SessionStore сессии входа и их токены обновления.
+type SessionStore interface {
+	CreateSession(session *users.Session, now, expires time.Time) (int64, error)
+	GetSession(id int64) (users.Session, error)
+	GetSessionByToken(tokenHash string) (users.Session, error)
+	GetSessions(userID int64, now time.Time) ([]users.Session, error)
+	RotateSession(id int64, oldHash, newHash string, now, expires time.Time) error
+	RevokeSession(userID, id int64, now time.Time) error
+	RevokeUserSessions(userID int64, now time.Time) (int64, error)
+}
+
+// APIKeyStore API-ключи пользователей.
+type APIKeyStore interface {
+	CreateAPIKey(userID int64, key *users.APIKey) (int64, error)
+	GetAPIKeys(userID int64) ([]users.APIKey, error)
+	GetAPIKeyByHash(keyHash string) (users.APIKey, error)
+	TouchAPIKey(id string, now time.Time) error
+	DeleteAPIKey(userID int64, id string) error
+}
+
+// TOTPStore второй фактор входа и коды восстановления.
+type TOTPStore interface {
+	GetTOTP(userID int64) (users.TOTP, error)
+	SaveTOTPSecret(userID int64, secret string, now time.Time) error
+	EnableTOTP(userID, step int64, codeHashes []string, now time.Time) error
+	ReplaceRecoveryCodes(userID int64, codeHashes []string) error
+	AcceptTOTPStep(userID, step int64) error
+	UseRecoveryCode(userID int64, codeHash string, now time.Time) error
+	CountRecoveryCodes(userID int64) (int, error)
+	RecordTOTPFailure(userID int64, maxFailures int, lockout time.Duration, now time.Time) (bool, error)
+	DeleteTOTP(userID int64) error
+}
+
+// FeedStore хранилище подписок на календарь.
+type FeedStore interface {
+	CreateFeed(userID int64, feed *calendar.Feed) (int64, error)
+	GetFeeds(userID int64) ([]calendar.Feed, error)
+	GetFeedByToken(tokenHash string) (calendar.Feed, error)
+	DeleteFeed(userID int64, id string) error
+}
+
+// WebhookStore подписки на события задач и очередь их доставки.
+type WebhookStore interface {
+	CreateWebhook(userID int64, hook *webhooks.Webhook) (int64, error)
+	GetWebhooks(userID int64) ([]webhooks.Webhook, error)
+	DeleteWebhook(userID int64, id string) error
+	GetDeliveries(userID int64, webhookID string, limit int) ([]webhooks.Delivery, error)
+	EnqueueEvent(userID int64, event string, payload []byte, now time.Time) (int64, error)
+}
+
+// NotifyStore адреса пользователей для напоминаний о задачах.
+type NotifyStore interface {
+	GetNotifyTargets(userID int64) (notify.Targets, error)
+	SetNotifyTargets(userID int64, targets *notify.Targets, now time.Time) error
+}
+
+// BackupStore снимки БД.
+type BackupStore interface {
+	Backup(dir, name string, now time.Time) (BackupFile, error)
+}
+
+// Store всё хранилище приложения, его использует handler.
+// Repository реализует его для SQLite и PostgreSQL.
+type Store interface {
+	TaskStore
+	UserStore
+	SessionStore
+	APIKeyStore
+	TOTPStore
+	FeedStore
+	WebhookStore
+	NotifyStore
+	BackupStore
+}
+
+var _ Store = (*Repository)(nil)
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"database/sql"
+	"go_final_project_avp/internal/users"
+
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTOTPNotFound пользователь не начинал подключать второй фактор.
+var ErrTOTPNotFound = errors.New("второй фактор не подключён")
+
+// ErrTOTPReplay код этого шага уже принят.
+var ErrTOTPReplay = errors.New("код уже использован")
+
+// ErrRecoveryCodeInvalid такого неиспользованного кода восстановления нет.
+var ErrRecoveryCodeInvalid = errors.New("неверный код восстановления")
+
+const getTOTP = ` -- name: GetTOTP
+	SELECT user_id, secret, enabled_at, last_step, failed_attempts, locked_until, created_at
+    FROM user_totp
+    WHERE user_id = ?
+	`
+
+// GetTOTP получаем настройки второго фактора пользователя.
+func (r *Repository) GetTOTP(userID int64) (users.TOTP, error) {
+	ctx := context.Background()
+	t := users.TOTP{}
+
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(getTOTP), userID).Scan(&t.UserId, &t.Secret, &t.EnabledAt, &t.LastStep,
+		&t.FailedAttempts, &t.LockedUntil, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, ErrTOTPNotFound
+	}
+	if err != nil {
+		return t, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+
+	return t, nil
+}
+
+const saveTOTPSecret = ` -- name: SaveTOTPSecret
+	INSERT INTO user_totp
+	    (user_id, secret, created_at)
+	VALUES (?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE
+	SET secret = excluded.secret, enabled_at = '', last_step = 0, failed_attempts = 0, locked_until = '',
+	    created_at = excluded.created_at
+	`
+
+// SaveTOTPSecret начинаем подключение второго фактора с новым секретом, прежний неподтверждённый секрет заменяется.
+func (r *Repository) SaveTOTPSecret(userID int64, secret string, now time.Time) error {
+	ctx := context.Background()
+
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(saveTOTPSecret), userID, secret, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+// EnableTOTP завершаем подключение второго фактора кодом шага step и сохраняем хэши новых кодов восстановления.
+func (r *Repository) EnableTOTP(userID, step int64, codeHashes []string, now time.Time) error {
+	ctx := context.Background()
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, r.db.Rebind(
+			`UPDATE user_totp SET enabled_at = ?, last_step = ?, failed_attempts = 0, locked_until = '' WHERE user_id = ? AND enabled_at = ''`),
+			now.UTC().Format(time.RFC3339), step, userID)
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+		}
+		if count == 0 {
+			return ErrTOTPNotFound
+		}
+
+		return r.replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+}
+
+// ReplaceRecoveryCodes заменяем коды восстановления пользователя новыми.
+func (r *Repository) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	ctx := context.Background()
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		return r.replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+}
+
+// replaceRecoveryCodes удаляет прежние коды восстановления и добавляет новые в транзакции tx.
+func (r *Repository) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM recovery_codes WHERE user_id = ?`), userID); err != nil {
+		return fmt.Errorf("ошибка удаления кодов восстановления: %w", err)
+	}
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx, r.db.Rebind(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`), userID, hash)
+		if err != nil {
+			return fmt.Errorf("ошибка добавления кода восстановления: %w", err)
+		}
+	}
+	return nil
+}
+
+const acceptTOTPStep = ` -- name: AcceptTOTPStep
+	UPDATE user_totp
+	SET last_step = ?, failed_attempts = 0
+	WHERE user_id = ? AND last_step < ?
+	`
+
+// AcceptTOTPStep запоминаем принятый шаг. Если этот или более поздний шаг уже принят
+// параллельным запросом, возвращает ErrTOTPReplay.
+func (r *Repository) AcceptTOTPStep(userID, step int64) error {
+	ctx := context.Background()
+
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(acceptTOTPStep), step, userID, step)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+	}
+	if count == 0 {
+		return ErrTOTPReplay
+	}
+
+	return nil
+}
+
+// UseRecoveryCode погашаем код восстановления пользователя по хэшу, второй раз он не подойдёт.
+func (r *Repository) UseRecoveryCode(userID int64, codeHash string, now time.Time) error {
+	ctx := context.Background()
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, r.db.Rebind(
+			`UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at = ''`),
+			now.UTC().Format(time.RFC3339), userID, codeHash)
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+		}
+		if count == 0 {
+			return ErrRecoveryCodeInvalid
+		}
+
+		_, err = tx.ExecContext(ctx, r.db.Rebind(`UPDATE user_totp SET failed_attempts = 0 WHERE user_id = ?`), userID)
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		}
+		return nil
+	})
+}
+
+// CountRecoveryCodes сколько неиспользованных кодов восстановления осталось у пользователя.
+func (r *Repository) CountRecoveryCodes(userID int64) (int, error) {
+	ctx := context.Background()
+
+	var count int
+	err := r.db.GetContext(ctx, &count, r.db.Rebind(`SELECT count(*) FROM recovery_codes WHERE user_id = ? AND used_at = ''`), userID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса GetContext: %w", err)
+	}
+
+	return count, nil
+}
+
+// RecordTOTPFailure учитываем неверный код. После maxFailures ошибок подряд проверка кодов
+// блокируется до now+lockout, счётчик начинается заново. Возвращает true, если проверка заблокирована.
+func (r *Repository) RecordTOTPFailure(userID int64, maxFailures int, lockout time.Duration, now time.Time) (bool, error) {
+	ctx := context.Background()
+
+	locked := false
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var failures int
+		err := tx.QueryRowContext(ctx, r.db.Rebind(`SELECT failed_attempts FROM user_totp WHERE user_id = ?`), userID).Scan(&failures)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTOTPNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+		}
+
+		failures++
+		if failures < maxFailures {
+			_, err = tx.ExecContext(ctx, r.db.Rebind(`UPDATE user_totp SET failed_attempts = ? WHERE user_id = ?`), failures, userID)
+		} else {
+			locked = true
+			_, err = tx.ExecContext(ctx, r.db.Rebind(`UPDATE user_totp SET failed_attempts = 0, locked_until = ? WHERE user_id = ?`),
+				now.Add(lockout).UTC().Format(time.RFC3339), userID)
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		}
+		return nil
+	})
+
+	return locked, err
+}
+
+// DeleteTOTP отключаем второй фактор пользователя вместе с кодами восстановления.
+func (r *Repository) DeleteTOTP(userID int64) error {
+	ctx := context.Background()
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM recovery_codes WHERE user_id = ?`), userID); err != nil {
+			return fmt.Errorf("ошибка удаления кодов восстановления: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM user_totp WHERE user_id = ?`), userID); err != nil {
+			return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		}
+		return nil
+	})
+}
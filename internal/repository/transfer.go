@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go_final_project_avp/internal/tasks"
+)
+
+const exportTasks = ` -- name: ExportTasks
+	SELECT id, date, title, comment, repeat, version
+    FROM scheduler
+    WHERE user_id = ? AND deleted_at IS NULL
+    ORDER BY date ASC, id ASC
+	`
+
+// ExportTasks передаёт fn задачи пользователя без корзины по одной, в порядке даты и id,
+// не загружая весь список в память. Ошибка fn прерывает выгрузку и возвращается как есть.
+func (r *Repository) ExportTasks(userID int64, fn func(tasks.Task) error) error {
+	ctx := context.Background()
+
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(exportTasks), userID)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	for res.Next() {
+		var t tasks.Task
+		if err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Version); err != nil {
+			return fmt.Errorf("ошибка сканирования задачи res.Scan: %w", err)
+		}
+		if err = fn(t); err != nil {
+			return err
+		}
+	}
+
+	if err = res.Err(); err != nil {
+		return fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return nil
+}
+
+// errDryRun откатывает транзакцию пробного импорта.
+var errDryRun = errors.New("пробный импорт")
+
+// ImportTasks добавляет задачи пользователю в одной транзакции, задачи должны быть проверены вызывающим.
+// При upsert задача с id, который есть у пользователя, изменяется, остальные добавляются новыми;
+// без upsert все задачи добавляются новыми. При dryRun изменения откатываются, результаты показывают,
+// что было бы сделано.
+func (r *Repository) ImportTasks(userID int64, list []tasks.Task, upsert, dryRun bool) ([]tasks.ImportResult, error) {
+	ctx := context.Background()
+
+	var results []tasks.ImportResult
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		results = make([]tasks.ImportResult, 0, len(list))
+		for i, t := range list {
+			result := tasks.ImportResult{Row: i + 1, Id: t.Id, Action: tasks.ImportUpdated}
+
+			updated := false
+			if upsert && t.Id != "" {
+				// Версия 0 — изменение без проверки версии
+				t.Version = 0
+				err := r.updateInTx(ctx, tx, userID, &t)
+				if err != nil && !errors.Is(err, ErrTaskNotFound) {
+					return fmt.Errorf("задача %d: %w", result.Row, err)
+				}
+				updated = err == nil
+			}
+
+			if !updated {
+				var id int64
+				err := tx.QueryRowContext(ctx, r.db.Rebind(createTask), t.Date, t.Title, t.Comment, t.Repeat, userID).Scan(&id)
+				if err != nil {
+					return fmt.Errorf("задача %d: ошибка добавления задачи: %w", result.Row, err)
+				}
+				result.Id, result.Action = strconv.FormatInt(id, 10), tasks.ImportCreated
+				t.Id, t.Version = result.Id, 1
+				if dryRun {
+					result.Id = ""
+				}
+			}
+			result.Task = &t
+			results = append(results, result)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	return results, nil
+}
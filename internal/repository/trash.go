@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go_final_project_avp/internal/tasks"
+)
+
+// ErrNotInTrash задачу нечем восстановить: она не в корзине, а последнее выполнение уже отменено
+// или задачу меняли после него.
+var ErrNotInTrash = errors.New("задача не в корзине, а её последнее выполнение отменить нельзя")
+
+const getTrash = ` -- name: GetTrash
+	SELECT id, date, title, comment, repeat, version, deleted_at
+    FROM scheduler
+    WHERE user_id = ? AND deleted_at IS NOT NULL
+    ORDER BY deleted_at DESC, id DESC
+	`
+
+// GetTrash задачи пользователя в корзине, последние удалённые первыми.
+func (r *Repository) GetTrash(userID int64) ([]tasks.Task, error) {
+	ctx := context.Background()
+
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(getTrash), userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	list := []tasks.Task{}
+	for res.Next() {
+		var t tasks.Task
+		if err = res.Scan(&t.Id, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Version, &t.DeletedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования задачи res.Scan: %w", err)
+		}
+		list = append(list, t)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+const (
+	getTaskState = ` -- name: GetTaskState
+	SELECT COALESCE(repeat, ''), version, deleted_at FROM scheduler WHERE id = ? AND user_id = ?
+	`
+
+	getLastCompletion = ` -- name: GetLastCompletion
+	SELECT id, scheduled_date, completed_at, task_version
+	FROM task_completions
+	WHERE user_id = ? AND task_id = ?
+	ORDER BY completed_at DESC, id DESC
+	LIMIT 1
+	`
+
+	restoreTask = ` -- name: RestoreTask
+	UPDATE scheduler SET deleted_at = NULL, version = version + 1
+	WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL AND version = ?
+	`
+
+	getTaskWithTrash = ` -- name: GetTaskWithTrash
+	SELECT id, date, title, comment, repeat, version, COALESCE(deleted_at, '')
+	FROM scheduler
+	WHERE id = ? AND user_id = ?
+	`
+
+	undoDoneTask = ` -- name: UndoDoneTask
+	UPDATE scheduler SET date = ?, version = version + 1
+	WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND version = ?
+	`
+
+	deleteCompletion = ` -- name: DeleteCompletion
+	DELETE FROM task_completions WHERE id = ?
+	`
+)
+
+// RestoreTask отменяет удаление или выполнение задачи пользователя.
+// Задача из корзины возвращается в список; если в корзину её перенёс DoneTask, запись о выполнении удаляется.
+// У задачи не из корзины отменяется последнее выполнение: возвращается дата, на которую она была назначена.
+// Выполнение отменяется, только пока версия задачи равна записанной при выполнении: после изменения задачи
+// или отмены этого выполнения возвращается ErrNotInTrash, более ранние выполнения не отменяются.
+// Если expected не 0 и не совпадает с версией задачи, возвращается *ConflictError с текущей задачей.
+func (r *Repository) RestoreTask(userID int64, id string, expected int64) error {
+	ctx := context.Background()
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		var (
+			repeat    string
+			version   int64
+			deletedAt sql.NullString
+		)
+		err := tx.QueryRowContext(ctx, r.db.Rebind(getTaskState), id, userID).Scan(&repeat, &version, &deletedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("задача не найдена c id - %s: %w", id, sql.ErrNoRows)
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+		}
+		if expected != 0 && expected != version {
+			return r.restoreConflictInTx(ctx, tx, userID, id)
+		}
+
+		var (
+			completionID, taskVersion  int64
+			scheduledDate, completedAt string
+			hasCompletion              = true
+		)
+		err = tx.QueryRowContext(ctx, r.db.Rebind(getLastCompletion), userID, id).
+			Scan(&completionID, &scheduledDate, &completedAt, &taskVersion)
+		if errors.Is(err, sql.ErrNoRows) {
+			hasCompletion = false
+		} else if err != nil {
+			return fmt.Errorf("ошибка чтения истории выполнения: %w", err)
+		}
+
+		// Задача в том же состоянии, в каком её оставило последнее выполнение
+		undoable := hasCompletion && taskVersion == version
+
+		switch {
+		case deletedAt.Valid:
+			res, err := tx.ExecContext(ctx, r.db.Rebind(restoreTask), id, userID, version)
+			if err != nil {
+				return fmt.Errorf("ошибка восстановления задачи: %w", err)
+			}
+			if count, err := res.RowsAffected(); err != nil || count == 0 {
+				return r.restoreConflictInTx(ctx, tx, userID, id)
+			}
+			// Разовую задачу в корзину перенесло выполнение, отменяем и его
+			if !undoable || repeat != "" || completedAt != deletedAt.String {
+				return nil
+			}
+		case undoable:
+			res, err := tx.ExecContext(ctx, r.db.Rebind(undoDoneTask), scheduledDate, id, userID, version)
+			if err != nil {
+				return fmt.Errorf("ошибка возврата даты задачи: %w", err)
+			}
+			if count, err := res.RowsAffected(); err != nil || count == 0 {
+				return r.restoreConflictInTx(ctx, tx, userID, id)
+			}
+		default:
+			return ErrNotInTrash
+		}
+
+		if _, err = tx.ExecContext(ctx, r.db.Rebind(deleteCompletion), completionID); err != nil {
+			return fmt.Errorf("ошибка удаления записи о выполнении: %w", err)
+		}
+		return nil
+	})
+}
+
+// restoreConflictInTx *ConflictError с текущей задачей, в том числе из корзины.
+func (r *Repository) restoreConflictInTx(ctx context.Context, tx *sql.Tx, userID int64, id string) error {
+	var current tasks.Task
+	err := tx.QueryRowContext(ctx, r.db.Rebind(getTaskWithTrash), id, userID).
+		Scan(&current.Id, &current.Date, &current.Title, &current.Comment, &current.Repeat, &current.Version, &current.DeletedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+	return &ConflictError{Current: current}
+}
+
+const purgeTrash = ` -- name: PurgeTrash
+	DELETE FROM scheduler WHERE deleted_at IS NOT NULL AND deleted_at < ?
+	`
+
+// PurgeTrash окончательно удаляет задачи всех пользователей, перенесённые в корзину раньше before.
+// История выполнения при этом остаётся.
+func (r *Repository) PurgeTrash(before time.Time) (int64, error) {
+	ctx := context.Background()
+
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(purgeTrash), before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка очистки корзины: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+	}
+
+	return count, nil
+}
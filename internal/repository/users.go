@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"database/sql"
+	"go_final_project_avp/internal/users"
+
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUserExists логин уже занят.
+var ErrUserExists = errors.New("пользователь с таким логином уже существует")
+
+// ErrUserNotFound пользователя с таким id или логином нет.
+var ErrUserNotFound = errors.New("пользователь не найден")
+
+const createUser = ` -- name: CreateUser
+	INSERT INTO users
+	    (login, password_hash, role, created_at)
+	VALUES (?, ?, ?, ?)
+	RETURNING id
+	`
+
+// CreateUser регистрируем пользователя.
+func (r *Repository) CreateUser(user *users.User) (int64, error) {
+	ctx := context.Background()
+
+	var exists int
+	if err := r.db.GetContext(ctx, &exists, r.db.Rebind(`SELECT count(*) FROM users WHERE login = ?`), user.Login); err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса GetContext: %w", err)
+	}
+	if exists > 0 {
+		return 0, ErrUserExists
+	}
+
+	if user.Role == "" {
+		user.Role = users.DefaultRole
+	}
+	user.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	var id int64
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(createUser), user.Login, user.PasswordHash, user.Role, user.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+	user.Id = id
+
+	return id, nil
+}
+
+const getUserByLogin = ` -- name: GetUserByLogin
+	SELECT id, login, password_hash, role, created_at
+    FROM users
+    WHERE login = ?
+	`
+
+// GetUserByLogin получаем пользователя по логину.
+func (r *Repository) GetUserByLogin(login string) (users.User, error) {
+	return r.getUser(getUserByLogin, login)
+}
+
+const getUserById = ` -- name: GetUserById
+	SELECT id, login, password_hash, role, created_at
+    FROM users
+    WHERE id = ?
+	`
+
+// GetUserById получаем пользователя по id.
+func (r *Repository) GetUserById(id int64) (users.User, error) {
+	return r.getUser(getUserById, id)
+}
+
+// getUser выборка одного пользователя.
+func (r *Repository) getUser(query string, arg any) (users.User, error) {
+	ctx := context.Background()
+	u := users.User{}
+
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(query), arg).Scan(&u.Id, &u.Login, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, fmt.Errorf("%w: %v", ErrUserNotFound, arg)
+	}
+	if err != nil {
+		return u, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+
+	return u, nil
+}
+
+const getUsers = ` -- name: GetUsers
+	SELECT id, login, role, created_at
+    FROM users
+    ORDER BY id ASC
+	`
+
+// GetUsers получаем всех пользователей без хэшей паролей.
+func (r *Repository) GetUsers() ([]users.User, error) {
+	ctx := context.Background()
+
+	res, err := r.db.QueryContext(ctx, getUsers)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	list := []users.User{}
+	for res.Next() {
+		u := users.User{}
+		if err = res.Scan(&u.Id, &u.Login, &u.Role, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования пользователя res.Scan: %w", err)
+		}
+		list = append(list, u)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+// SetUserRole меняем роль пользователя и завершаем его сессии: роль записана в выданных токенах,
+// поэтому с новой ролью нужно войти заново. Без роли admin управлять подписками на события нельзя,
+// поэтому подписки пользователя удаляются вместе с их очередью и журналом.
+func (r *Repository) SetUserRole(id int64, role string, now time.Time) error {
+	ctx := context.Background()
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, r.db.Rebind(`UPDATE users SET role = ? WHERE id = ?`), role, id)
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %v", ErrUserNotFound, id)
+		}
+
+		_, err = tx.ExecContext(ctx, r.db.Rebind(revokeUserSessions), now.UTC().Format(time.RFC3339), id)
+		if err != nil {
+			return fmt.Errorf("ошибка завершения сессий пользователя: %w", err)
+		}
+
+		if role != users.RoleAdmin {
+			_, err = tx.ExecContext(ctx, r.db.Rebind(
+				`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE user_id = ?)`), id)
+			if err != nil {
+				return fmt.Errorf("ошибка удаления доставок подписок пользователя: %w", err)
+			}
+			if _, err = tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM webhooks WHERE user_id = ?`), id); err != nil {
+				return fmt.Errorf("ошибка удаления подписок пользователя: %w", err)
+			}
+		}
+		return nil
+	})
+}
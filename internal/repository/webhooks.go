@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project_avp/internal/webhooks"
+)
+
+// ErrWebhookNotFound у пользователя нет подписки с таким id.
+var ErrWebhookNotFound = errors.New("подписка не найдена")
+
+const createWebhook = ` -- name: CreateWebhook
+	INSERT INTO webhooks
+	    (user_id, url, secret, events, created_at)
+	VALUES (?, ?, ?, ?, ?)
+	RETURNING id
+	`
+
+// CreateWebhook добавляем подписку пользователя на события задач.
+func (r *Repository) CreateWebhook(userID int64, hook *webhooks.Webhook) (int64, error) {
+	ctx := context.Background()
+
+	hook.UserId = userID
+	hook.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	var id int64
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(createWebhook), userID, hook.URL, hook.Secret,
+		strings.Join(hook.Events, ","), hook.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+	hook.Id = strconv.FormatInt(id, 10)
+
+	return id, nil
+}
+
+const getWebhooks = ` -- name: GetWebhooks
+	SELECT id, url, events, created_at
+    FROM webhooks
+    WHERE user_id = ?
+    ORDER BY id ASC
+	`
+
+// GetWebhooks получаем подписки пользователя без секретов.
+func (r *Repository) GetWebhooks(userID int64) ([]webhooks.Webhook, error) {
+	ctx := context.Background()
+
+	res, err := r.db.QueryContext(ctx, r.db.Rebind(getWebhooks), userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	list := []webhooks.Webhook{}
+	for res.Next() {
+		hook := webhooks.Webhook{UserId: userID}
+		var events string
+		if err = res.Scan(&hook.Id, &hook.URL, &events, &hook.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования подписки res.Scan: %w", err)
+		}
+		hook.Events = splitEvents(events)
+		list = append(list, hook)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+// splitEvents события подписки из БД, пустая строка — все события.
+func splitEvents(s string) []string {
+	if s == "" {
+		return append([]string(nil), webhooks.Events...)
+	}
+	return strings.Split(s, ",")
+}
+
+// DeleteWebhook удаляем подписку пользователя вместе с её очередью и журналом доставок.
+func (r *Repository) DeleteWebhook(userID int64, id string) error {
+	ctx := context.Background()
+
+	hookID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookNotFound, id)
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, r.db.Rebind(
+			`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE id = ? AND user_id = ?)`),
+			hookID, userID)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления доставок подписки: %w", err)
+		}
+
+		res, err := tx.ExecContext(ctx, r.db.Rebind(`DELETE FROM webhooks WHERE id = ? AND user_id = ?`), hookID, userID)
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %v", ErrWebhookNotFound, id)
+		}
+		return nil
+	})
+}
+
+const enqueueEvent = ` -- name: EnqueueEvent
+	INSERT INTO webhook_deliveries
+	    (webhook_id, event, payload, status, attempts, next_attempt_at, created_at)
+	SELECT id, ?, ?, ?, 0, ?, ?
+    FROM webhooks
+    WHERE user_id = ? AND (events = '' OR ',' || events || ',' LIKE ?)
+	`
+
+// EnqueueEvent ставит событие event с телом payload в очередь доставки каждой подписке пользователя
+// на это событие и возвращает число поставленных доставок.
+func (r *Repository) EnqueueEvent(userID int64, event string, payload []byte, now time.Time) (int64, error) {
+	ctx := context.Background()
+
+	at := now.UTC().Format(time.RFC3339)
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(enqueueEvent), event, string(payload), webhooks.StatusPending,
+		at, at, userID, "%,"+event+",%")
+	if err != nil {
+		return 0, fmt.Errorf("ошибка выполнения запроса ExecContext: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка res.RowsAffected(): %w", err)
+	}
+
+	return count, nil
+}
+
+const getDeliveries = ` -- name: GetDeliveries
+	SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+	       d.response_status, d.last_error, d.created_at, d.delivered_at
+    FROM webhook_deliveries d
+    JOIN webhooks w ON w.id = d.webhook_id
+    WHERE w.user_id = ? AND w.id = ?
+    ORDER BY d.id DESC
+    LIMIT ?
+	`
+
+// GetDeliveries журнал доставок подписки пользователя, последние первыми, не больше limit.
+func (r *Repository) GetDeliveries(userID int64, webhookID string, limit int) ([]webhooks.Delivery, error) {
+	ctx := context.Background()
+
+	hookID, err := strconv.ParseInt(webhookID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookNotFound, webhookID)
+	}
+	var exists int
+	err = r.db.QueryRowContext(ctx, r.db.Rebind(`SELECT 1 FROM webhooks WHERE id = ? AND user_id = ?`), hookID, userID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookNotFound, webhookID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryRowContext: %w", err)
+	}
+
+	return r.queryDeliveries(ctx, false, r.db.Rebind(getDeliveries), userID, hookID, limit)
+}
+
+const getDueDeliveries = ` -- name: GetDueDeliveries
+	SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+	       d.response_status, d.last_error, d.created_at, d.delivered_at, w.url, w.secret
+    FROM webhook_deliveries d
+    JOIN webhooks w ON w.id = d.webhook_id
+    WHERE d.status = ? AND d.next_attempt_at <= ?
+    ORDER BY d.next_attempt_at ASC, d.id ASC
+    LIMIT ?
+	`
+
+// GetDueDeliveries доставки всех пользователей, время попытки которых наступило к now, с адресом и секретом подписки.
+func (r *Repository) GetDueDeliveries(now time.Time, limit int) ([]webhooks.Delivery, error) {
+	ctx := context.Background()
+
+	return r.queryDeliveries(ctx, true, r.db.Rebind(getDueDeliveries), webhooks.StatusPending, now.UTC().Format(time.RFC3339), limit)
+}
+
+// queryDeliveries выполняет запрос доставок, withTarget — в запросе есть адрес и секрет подписки.
+func (r *Repository) queryDeliveries(ctx context.Context, withTarget bool, query string, args ...any) ([]webhooks.Delivery, error) {
+	res, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса QueryContext: %w", err)
+	}
+	defer func(res *sql.Rows) {
+		_ = res.Close()
+	}(res)
+
+	list := []webhooks.Delivery{}
+	for res.Next() {
+		var (
+			d       webhooks.Delivery
+			payload string
+		)
+		dest := []any{&d.Id, &d.WebhookId, &d.Event, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.ResponseStatus, &d.Error, &d.CreatedAt, &d.DeliveredAt}
+		if withTarget {
+			dest = append(dest, &d.URL, &d.Secret)
+		}
+		if err = res.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования доставки res.Scan: %w", err)
+		}
+		d.Payload = json.RawMessage(payload)
+		list = append(list, d)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после обработки результата res.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+const updateDelivery = ` -- name: UpdateDelivery
+	UPDATE webhook_deliveries
+	SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?, last_error = ?, delivered_at = ?
+	WHERE id = ?
+	`
+
+// UpdateDelivery записываем результат попытки доставки.
+func (r *Repository) UpdateDelivery(d webhooks.Delivery) error {
+	ctx := context.Background()
+
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(updateDelivery), d.Status, d.Attempts, d.NextAttemptAt,
+		d.ResponseStatus, d.Error, d.DeliveredAt, d.Id)
+	if err != nil {
+		return fmt.Errorf("ошибка записи результата доставки: %w", err)
+	}
+
+	return nil
+}
+
+var _ webhooks.Store = (*Repository)(nil)
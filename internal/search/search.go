@@ -0,0 +1,210 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"go_final_project_avp/internal/tasks"
+)
+
+// Field поле, по которому фильтрует условие поиска.
+type Field string
+
+const (
+	FieldText    Field = ""        // слово без поля: заголовок или комментарий
+	FieldTitle   Field = "title"   // title:слово
+	FieldComment Field = "comment" // comment:слово
+	FieldDate    Field = "date"    // date:>=20261001
+	FieldRepeat  Field = "repeat"  // repeat:w или repeat:"d 7"
+	FieldHas     Field = "has"     // has:repeat или has:comment
+)
+
+// Op оператор сравнения, допустим только для date.
+type Op string
+
+const (
+	OpEq Op = "="
+	OpLt Op = "<"
+	OpLe Op = "<="
+	OpGt Op = ">"
+	OpGe Op = ">="
+)
+
+// Term одно условие поиска. Value уже приведено к виду для сравнения:
+// дата в формате tasks.TimeFormat, правило повторения — каноническое или одна буква вида правила.
+type Term struct {
+	Field  Field
+	Op     Op
+	Value  string
+	Phrase bool // "фраза в кавычках" ищется целиком, без префикса
+	Negate bool // -условие исключает задачи
+}
+
+// Query разобранная строка поиска, условия объединяются через И.
+type Query struct {
+	Terms []Term
+}
+
+// IsEmpty в запросе нет условий.
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0
+}
+
+// Error ошибка разбора строки поиска с позицией неверного условия.
+type Error struct {
+	Query  string // строка поиска целиком
+	Pos    int    // позиция условия в символах, начиная с 1
+	Token  string // неверное условие
+	Reason string // причина ошибки
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("поиск %q: условие %q (позиция %d): %s", e.Query, e.Token, e.Pos, e.Reason)
+}
+
+// repeatKinds виды правил повторения для repeat:вид.
+var repeatKinds = map[string]bool{"d": true, "w": true, "m": true, "y": true}
+
+// hasValues значения для has:.
+var hasValues = map[string]bool{string(FieldRepeat): true, string(FieldComment): true}
+
+// Parse разбирает строку поиска. Условия разделяются пробелами:
+//
+//	слово               — начало слова в заголовке или комментарии
+//	"фраза"             — фраза целиком
+//	title:слово         — только в заголовке, comment:слово — только в комментарии
+//	date:>=20261001     — сравнение даты, операторы = < <= > >=, дата 20060102 или 02.01.2006
+//	repeat:w            — вид правила повторения d, w, m, y или правило целиком repeat:"d 7"
+//	has:repeat          — у задачи есть правило повторения, has:comment — комментарий
+//	-условие            — исключить задачи, подходящие под условие
+//
+// Отдельная дата 02.01.2006 без поля равна date:02.01.2006.
+func Parse(s string) (Query, error) {
+	q := Query{}
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		term := Term{Op: OpEq}
+		if runes[i] == '-' {
+			term.Negate = true
+			i++
+		}
+
+		// Имя поля до двоеточия, неизвестные имена остаются частью слова, например 18:00
+		fieldEnd := i
+		for fieldEnd < len(runes) && unicode.IsLetter(runes[fieldEnd]) {
+			fieldEnd++
+		}
+		if fieldEnd < len(runes) && runes[fieldEnd] == ':' {
+			switch name := Field(strings.ToLower(string(runes[i:fieldEnd]))); name {
+			case FieldTitle, FieldComment, FieldDate, FieldRepeat, FieldHas:
+				term.Field = name
+				i = fieldEnd + 1
+			}
+		}
+
+		if term.Field == FieldDate {
+			for _, op := range []Op{OpGe, OpLe, OpGt, OpLt, OpEq} {
+				if strings.HasPrefix(string(runes[i:]), string(op)) {
+					term.Op = op
+					i += len([]rune(op))
+					break
+				}
+			}
+		}
+
+		var value string
+		value, term.Phrase, i = readValue(runes, i)
+		token := string(runes[start:i])
+		fail := func(reason string) error {
+			return &Error{Query: s, Pos: start + 1, Token: token, Reason: reason}
+		}
+
+		if value == "" {
+			// Одиночный минус или пустые кавычки ничего не ищут
+			if term.Field == FieldText {
+				continue
+			}
+			return Query{}, fail("пустое значение")
+		}
+
+		switch term.Field {
+		case FieldText:
+			// Отдельная дата ищет задачи на этот день
+			if date, err := time.Parse(tasks.DisplayDateFormat, value); err == nil && !term.Phrase {
+				term.Field = FieldDate
+				value = date.Format(tasks.TimeFormat)
+			}
+		case FieldDate:
+			date, err := parseDate(value)
+			if err != nil {
+				return Query{}, fail(fmt.Sprintf("дата должна быть в формате %s или %s", tasks.TimeFormat, tasks.DisplayDateFormat))
+			}
+			value = date
+		case FieldRepeat:
+			kind := strings.ToLower(value)
+			if repeatKinds[kind] {
+				value = kind
+				break
+			}
+			rule, err := tasks.Parse(value)
+			if err != nil {
+				return Query{}, fail(fmt.Sprintf("ожидается вид правила d, w, m, y или правило целиком: %v", err))
+			}
+			value = rule.String()
+		case FieldHas:
+			value = strings.ToLower(value)
+			if !hasValues[value] {
+				return Query{}, fail("ожидается has:repeat или has:comment")
+			}
+		}
+
+		term.Value = value
+		q.Terms = append(q.Terms, term)
+	}
+
+	return q, nil
+}
+
+// readValue читает значение условия с позиции i: "фразу в кавычках" или слово до пробела.
+// Незакрытая кавычка продолжает фразу до конца строки.
+func readValue(runes []rune, i int) (value string, phrase bool, next int) {
+	if i < len(runes) && runes[i] == '"' {
+		i++
+		start := i
+		for i < len(runes) && runes[i] != '"' {
+			i++
+		}
+		value = strings.TrimSpace(string(runes[start:i]))
+		if i < len(runes) {
+			i++
+		}
+		return value, true, i
+	}
+
+	start := i
+	for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '"' {
+		i++
+	}
+	return string(runes[start:i]), false, i
+}
+
+// parseDate дата в формате tasks.TimeFormat или tasks.DisplayDateFormat.
+func parseDate(value string) (string, error) {
+	if date, err := time.Parse(tasks.TimeFormat, value); err == nil {
+		return date.Format(tasks.TimeFormat), nil
+	}
+	date, err := time.Parse(tasks.DisplayDateFormat, value)
+	if err != nil {
+		return "", err
+	}
+	return date.Format(tasks.TimeFormat), nil
+}
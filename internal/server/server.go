@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"go_final_project_avp/internal/handler"
+	"go_final_project_avp/internal/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebDir директория со статикой и шаблонами относительно корня проекта.
+const WebDir = "internal/web"
+
+// NewRouter маршруты приложения, webDir — директория со статикой и шаблонами.
+func NewRouter(newHandler *handler.Handler, webDir string) *gin.Engine {
+	r := gin.Default()
+	r.Static("/css", filepath.Join(webDir, "css"))
+	r.Static("/js", filepath.Join(webDir, "js"))
+	r.StaticFile("/favicon.ico", filepath.Join(webDir, "favicon.ico"))
+	r.LoadHTMLGlob(filepath.Join(webDir, "*.html"))
+	// Маршрут для логина страницы
+	r.GET("/login.html", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "login.html", nil)
+	})
+	// Маршрут для аутентификации
+	r.POST("/api/signin", newHandler.SignIn)
+	r.POST("/api/signin/2fa", newHandler.SignInSecondFactor)
+	r.POST("/api/register", newHandler.Register)
+	r.POST("/api/refresh", newHandler.Refresh)
+
+	r.GET("/", handler.Index)
+	r.GET("/index.html", handler.Index)
+	r.GET("api/nextdate", newHandler.GetNextDate)
+	// Календарь по токену подписки, без куки
+	r.GET("/api/calendar.ics", newHandler.GetCalendar)
+	// Задачи доступны с токеном сессии и по API-ключу с нужной областью доступа.
+	// Читать задачи может любая роль, менять — editor и admin
+	taskRoutes := r.Group("/api")
+	taskRoutes.Use(newHandler.AuthMiddleware(), newHandler.TaskScope(), newHandler.TaskRole())
+	{
+		taskRoutes.GET("/tasks", newHandler.GetTasks)
+		taskRoutes.POST("/tasks/batch", newHandler.Batch)
+		taskRoutes.GET("/task", newHandler.GetTasksId)
+		taskRoutes.PUT("/task", newHandler.UpdateTask)
+		taskRoutes.POST("/task", newHandler.CreateTask)
+		taskRoutes.DELETE("/task", newHandler.DeleteTask)
+		taskRoutes.POST("/task/done", newHandler.DoneTask)
+		taskRoutes.GET("/task/history", newHandler.GetTaskHistory)
+		taskRoutes.POST("/task/restore", newHandler.RestoreTask)
+		taskRoutes.GET("/trash", newHandler.GetTrash)
+		taskRoutes.GET("/occurrences", newHandler.GetOccurrences)
+		taskRoutes.GET("/export", newHandler.ExportTasks)
+		taskRoutes.POST("/import", newHandler.ImportTasks)
+	}
+
+	// Остальные защищённые маршруты только с токеном сессии
+	authRoutes := r.Group("/api")
+	authRoutes.Use(newHandler.AuthMiddleware(), newHandler.SessionOnly(), newHandler.RequireRole(users.RoleViewer))
+	{
+		authRoutes.GET("/sessions", newHandler.GetSessions)
+		authRoutes.POST("/logout", newHandler.Logout)
+		authRoutes.POST("/logout/all", newHandler.LogoutAll)
+		authRoutes.GET("/2fa", newHandler.GetTOTP)
+		authRoutes.POST("/2fa/setup", newHandler.SetupTOTP)
+		authRoutes.POST("/2fa/enable", newHandler.EnableTOTP)
+		authRoutes.POST("/2fa/disable", newHandler.DisableTOTP)
+		authRoutes.POST("/2fa/recovery", newHandler.RegenerateRecoveryCodes)
+		authRoutes.GET("/keys", newHandler.GetAPIKeys)
+		authRoutes.POST("/keys", newHandler.CreateAPIKey)
+		authRoutes.DELETE("/keys", newHandler.DeleteAPIKey)
+		authRoutes.GET("/calendar/feeds", newHandler.GetFeeds)
+		authRoutes.POST("/calendar/feeds", newHandler.CreateFeed)
+		authRoutes.DELETE("/calendar/feeds", newHandler.DeleteFeed)
+		authRoutes.GET("/notify", newHandler.GetNotifyTargets)
+		authRoutes.PUT("/notify", newHandler.SetNotifyTargets)
+
+		// Веб-хуки, пользователи и обслуживание сервера, только для администратора
+		hookRoutes := authRoutes.Group("/webhooks")
+		hookRoutes.Use(newHandler.AdminMiddleware())
+		hookRoutes.GET("", newHandler.GetWebhooks)
+		hookRoutes.POST("", newHandler.CreateWebhook)
+		hookRoutes.DELETE("", newHandler.DeleteWebhook)
+		hookRoutes.GET("/deliveries", newHandler.GetWebhookDeliveries)
+
+		adminRoutes := authRoutes.Group("/admin")
+		adminRoutes.Use(newHandler.AdminMiddleware())
+		adminRoutes.GET("/users", newHandler.GetUsers)
+		adminRoutes.PUT("/users", newHandler.SetUserRole)
+		adminRoutes.POST("/backup", newHandler.CreateBackup)
+		adminRoutes.GET("/backups", newHandler.GetBackups)
+	}
+
+	return r
+}
@@ -0,0 +1,41 @@
+package tasks
+
+// Операции пакетного запроса.
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDone   = "done"
+	OpDelete = "delete"
+	OpShift  = "shift"
+)
+
+// BatchOp одна операция пакетного запроса, поля задачи нужны только create и update.
+type BatchOp struct {
+	Op      string `json:"op"`
+	Id      string `json:"id,omitempty"`
+	Date    string `json:"date,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	Repeat  string `json:"repeat,omitempty"`
+	Version int64  `json:"version,string,omitempty"` // 0 — без проверки версии
+	Note    string `json:"note,omitempty"`           // заметка к выполнению для done
+	Days    int    `json:"days,omitempty"`           // на сколько дней сдвинуть задачу для shift, может быть отрицательным
+}
+
+// Task задача из полей операции.
+func (op BatchOp) Task() Task {
+	return Task{Id: op.Id, Date: op.Date, Title: op.Title, Comment: op.Comment, Repeat: op.Repeat, Version: op.Version}
+}
+
+// BatchResult результат операции пакетного запроса.
+type BatchResult struct {
+	Op      string `json:"op"`
+	Id      string `json:"id,omitempty"`             // у create — id новой задачи
+	Date    string `json:"date,omitempty"`           // дата задачи после операции, пустая — задача в корзине
+	Version int64  `json:"version,string,omitempty"` // версия задачи после операции
+	Error   string `json:"error,omitempty"`          // причина, по которой пакет отменён
+	Task    *Task  `json:"task,omitempty"`           // текущая задача при конфликте версий
+
+	Current    *Task       `json:"-"` // задача после операции, у delete — до удаления; нужна для событий
+	Completion *Completion `json:"-"` // выполнение, записанное операцией done
+}
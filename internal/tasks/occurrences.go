@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// MaxOccurrences максимальное количество дат, которое возвращает Occurrences.
+const MaxOccurrences = 1000
+
+// Occurrences разворачивает правило повторения задачи с датой start и возвращает
+// все даты её выполнения в интервале [from, to] включительно. Первая дата — сама start,
+// каждая следующая строго позже предыдущей, так же как дату сдвигает отметка о выполнении.
+// Для задачи без повторения rule равно nil.
+func Occurrences(start time.Time, rule Rule, from, to time.Time) ([]time.Time, error) {
+	start, from, to = TruncateToDate(start), TruncateToDate(from), TruncateToDate(to)
+	if to.Before(from) {
+		return nil, errors.New("конец интервала раньше его начала")
+	}
+
+	var result []time.Time
+
+	// Задача без повторения выполняется один раз
+	if rule == nil {
+		if !start.Before(from) && !start.After(to) {
+			result = append(result, start)
+		}
+		return result, nil
+	}
+
+	cur := start
+	if cur.Before(from) {
+		cur = seekBefore(cur, from, rule)
+	}
+
+	for !cur.After(to) && len(result) < MaxOccurrences {
+		if !cur.Before(from) {
+			result = append(result, cur)
+		}
+		cur = rule.step(cur)
+	}
+
+	return result, nil
+}
+
+// seekBefore перематывает цепочку дат от start к последней позиции строго до from,
+// чтобы не перебирать все повторения с давно прошедшей даты.
+func seekBefore(start, from time.Time, rule Rule) time.Time {
+	switch r := rule.(type) {
+	case DayRule:
+		diff := int(math.Round(from.Sub(start).Hours() / 24))
+		return start.AddDate(0, 0, (diff-1)/r.Days*r.Days)
+
+	case WeekRule, MonthRule:
+		// Даты по дням недели и месяца не зависят от start, достаточно начать с дня до from
+		return from.AddDate(0, 0, -1)
+
+	default:
+		cur := start
+		for next := rule.step(cur); next.Before(from); next = rule.step(cur) {
+			cur = next
+		}
+		return cur
+	}
+}
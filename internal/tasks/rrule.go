@@ -0,0 +1,222 @@
+package tasks
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rruleMonthDayReason почему день месяца нельзя перевести в RRULE и обратно.
+const rruleMonthDayReason = "в RRULE переводятся только дни месяца от 1 до 31 и последний день -1, " +
+	"предпоследний день месяца -2 не имеет точного эквивалента, замените его на конкретные дни"
+
+// rruleMonthDay день месяца, который переводится в BYMONTHDAY без изменения смысла.
+func rruleMonthDay(day int) bool {
+	return day >= 1 && day <= 31 || day == -1
+}
+
+// rruleWeekdays коды дней недели RFC 5545, индекс + 1 — день недели в правиле "w".
+var rruleWeekdays = []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// FormatRRule переводит правило повторения задачи с датой start в строку "RRULE:" по RFC 5545.
+// Для правила без эквивалента в RFC 5545 возвращает RuleError с объяснением.
+func FormatRRule(rule Rule, start time.Time) (string, error) {
+	var parts []string
+
+	switch r := rule.(type) {
+	case nil:
+		return "", nil
+
+	case DayRule:
+		parts = append(parts, "FREQ=DAILY")
+		if r.Days > 1 {
+			parts = append(parts, "INTERVAL="+strconv.Itoa(r.Days))
+		}
+
+	case YearRule:
+		// Планировщик переносит 29 февраля на 1 марта, а по RFC 5545 невисокосные годы пропускаются
+		if start.Month() == time.February && start.Day() == 29 {
+			return "", &RuleError{Rule: r.String(), Reason: "ежегодное повторение с 29 февраля не имеет эквивалента в RRULE: " +
+				"планировщик переносит дату на 1 марта, а RRULE пропускает невисокосные годы"}
+		}
+		parts = append(parts, "FREQ=YEARLY")
+
+	case WeekRule:
+		days := make([]string, len(r.Weekdays))
+		for i, wd := range r.Weekdays {
+			days[i] = rruleWeekdays[wd-1]
+		}
+		parts = append(parts, "FREQ=WEEKLY", "BYDAY="+strings.Join(days, ","))
+
+	case MonthRule:
+		for _, day := range r.Days {
+			if !rruleMonthDay(day) {
+				return "", &RuleError{Rule: r.String(), Token: strconv.Itoa(day), Reason: rruleMonthDayReason}
+			}
+		}
+		parts = append(parts, "FREQ=MONTHLY")
+		if len(r.Months) > 0 {
+			parts = append(parts, "BYMONTH="+joinInts(r.Months))
+		}
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.Days))
+
+	default:
+		return "", &RuleError{Rule: rule.String(), Reason: "правило не имеет эквивалента в RRULE"}
+	}
+
+	return "RRULE:" + strings.Join(parts, ";"), nil
+}
+
+// ParseRRule переводит строку RRULE по RFC 5545 в правило повторения задачи с датой start.
+// Части RRULE, которые планировщик не умеет выполнять, отклоняются с RuleError.
+func ParseRRule(rrule string, start time.Time) (Rule, error) {
+	body := strings.TrimSpace(rrule)
+	if len(body) >= 6 && strings.EqualFold(body[:6], "RRULE:") {
+		body = body[6:]
+	}
+	if body == "" {
+		return nil, &RuleError{Rule: rrule, Reason: "пустое правило RRULE"}
+	}
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(body, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || key == "" || value == "" {
+			return nil, &RuleError{Rule: rrule, Token: part, Reason: "ожидается пара ИМЯ=ЗНАЧЕНИЕ"}
+		}
+		key = strings.ToUpper(key)
+		if _, dup := params[key]; dup {
+			return nil, &RuleError{Rule: rrule, Token: part, Reason: "параметр указан повторно"}
+		}
+		params[key] = strings.ToUpper(value)
+	}
+
+	for key := range params {
+		switch key {
+		case "FREQ", "INTERVAL", "BYDAY", "BYMONTH", "BYMONTHDAY", "WKST":
+		case "COUNT", "UNTIL":
+			return nil, &RuleError{Rule: rrule, Token: key, Reason: "правила планировщика бесконечны, ограничение количества повторений не поддерживается"}
+		default:
+			return nil, &RuleError{Rule: rrule, Token: key, Reason: "параметр не имеет эквивалента в правилах планировщика"}
+		}
+	}
+
+	interval := 1
+	if value, ok := params["INTERVAL"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, &RuleError{Rule: rrule, Token: "INTERVAL=" + value, Reason: "ожидается положительное целое число"}
+		}
+		interval = n
+	}
+
+	freq := params["FREQ"]
+	if freq == "" {
+		return nil, &RuleError{Rule: rrule, Reason: "не указан обязательный параметр FREQ"}
+	}
+	if freq != "DAILY" && interval != 1 {
+		return nil, &RuleError{Rule: rrule, Token: "INTERVAL=" + params["INTERVAL"],
+			Reason: "интервал поддерживается только для FREQ=DAILY"}
+	}
+
+	// onlyParams проверяет, что для частоты не указаны посторонние параметры
+	onlyParams := func(allowed ...string) error {
+		for key := range params {
+			if key != "FREQ" && key != "INTERVAL" && key != "WKST" && !slices.Contains(allowed, key) {
+				return &RuleError{Rule: rrule, Token: key, Reason: "параметр не поддерживается для FREQ=" + freq}
+			}
+		}
+		return nil
+	}
+
+	switch freq {
+	case "DAILY":
+		if err := onlyParams(); err != nil {
+			return nil, err
+		}
+		if interval > 400 {
+			return nil, &RuleError{Rule: rrule, Token: "INTERVAL=" + params["INTERVAL"], Reason: "интервал дней должен быть от 1 до 400"}
+		}
+		return DayRule{Days: interval}, nil
+
+	case "WEEKLY":
+		if err := onlyParams("BYDAY"); err != nil {
+			return nil, err
+		}
+		value, ok := params["BYDAY"]
+		if !ok {
+			// По RFC 5545 без BYDAY повторение идёт в день недели даты начала
+			return WeekRule{Weekdays: []int{isoWeekday(start)}}, nil
+		}
+		var weekdays []int
+		for _, day := range strings.Split(value, ",") {
+			idx := slices.Index(rruleWeekdays, day)
+			if idx < 0 {
+				return nil, &RuleError{Rule: rrule, Token: day, Reason: "ожидается день недели MO, TU, WE, TH, FR, SA или SU без номера"}
+			}
+			weekdays = append(weekdays, idx+1)
+		}
+		return WeekRule{Weekdays: weekdays}, nil
+
+	case "MONTHLY", "YEARLY":
+		if err := onlyParams("BYMONTH", "BYMONTHDAY"); err != nil {
+			return nil, err
+		}
+		_, hasMonth := params["BYMONTH"]
+		_, hasDay := params["BYMONTHDAY"]
+		if freq == "YEARLY" && !hasMonth && !hasDay {
+			if start.Month() == time.February && start.Day() == 29 {
+				return nil, &RuleError{Rule: rrule, Reason: "ежегодное повторение с 29 февраля не имеет эквивалента в правилах планировщика: " +
+					"RRULE пропускает невисокосные годы, а планировщик переносит дату на 1 марта"}
+			}
+			return YearRule{}, nil
+		}
+
+		rule := MonthRule{Days: []int{start.Day()}}
+		if hasDay {
+			days, err := parseRRuleList(rrule, "BYMONTHDAY", params["BYMONTHDAY"], rruleMonthDay, rruleMonthDayReason)
+			if err != nil {
+				return nil, err
+			}
+			rule.Days = days
+		}
+		if hasMonth {
+			months, err := parseRRuleList(rrule, "BYMONTH", params["BYMONTH"], func(n int) bool {
+				return n >= 1 && n <= 12
+			}, "месяц должен быть от 1 до 12")
+			if err != nil {
+				return nil, err
+			}
+			rule.Months = months
+		}
+		if !monthRuleReachable(rule.Days, rule.Months) {
+			return nil, &RuleError{Rule: rrule, Reason: "ни один из дней не встречается в указанных месяцах"}
+		}
+		return rule, nil
+
+	default:
+		return nil, &RuleError{Rule: rrule, Token: "FREQ=" + freq, Reason: "поддерживаются только DAILY, WEEKLY, MONTHLY и YEARLY"}
+	}
+}
+
+// parseRRuleList парсинг списка чисел параметра RRULE с проверкой каждого значения.
+func parseRRuleList(rrule, key, list string, valid func(int) bool, reason string) ([]int, error) {
+	var result []int
+	for _, part := range strings.Split(list, ",") {
+		num, err := strconv.Atoi(part)
+		if err != nil || !valid(num) {
+			return nil, &RuleError{Rule: rrule, Token: key + "=" + part, Reason: reason}
+		}
+		result = append(result, num)
+	}
+	return result, nil
+}
+
+// isoWeekday день недели от 1 (понедельник) до 7 (воскресенье).
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
@@ -0,0 +1,240 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule разобранное правило повторения задачи.
+type Rule interface {
+	// Next возвращает следующую дату выполнения задачи с датой start относительно даты after.
+	Next(after, start time.Time) time.Time
+	// String возвращает правило в текстовом формате, который понимает Parse.
+	String() string
+	// step возвращает ближайшую дату повторения строго после prev.
+	step(prev time.Time) time.Time
+}
+
+// RuleError ошибка разбора правила повторения с указанием неверного элемента.
+type RuleError struct {
+	Rule   string // правило целиком
+	Token  string // неверный элемент правила, пустой если ошибка относится ко всему правилу
+	Reason string // причина ошибки
+}
+
+func (e *RuleError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("правило повторения %q: %s", e.Rule, e.Reason)
+	}
+	return fmt.Sprintf("правило повторения %q: неверное значение %q: %s", e.Rule, e.Token, e.Reason)
+}
+
+// DayRule правило "d N" — повторение через N дней.
+type DayRule struct {
+	Days int
+}
+
+// YearRule правило "y" — ежегодное повторение.
+type YearRule struct{}
+
+// WeekRule правило "w 1,3" — повторение по дням недели, 1 понедельник ... 7 воскресенье.
+type WeekRule struct {
+	Weekdays []int
+}
+
+// MonthRule правило "m 1,-1 2,3" — повторение по дням месяца, -1 последний и -2 предпоследний день.
+// Если Months пуст, подходит любой месяц.
+type MonthRule struct {
+	Days   []int
+	Months []int
+}
+
+// Parse разбирает правило повторения. Для пустой строки возвращает nil — задача без повторения.
+func Parse(repeat string) (Rule, error) {
+	if repeat == "" {
+		return nil, nil
+	}
+
+	fields := strings.Fields(repeat)
+	if len(fields) == 0 {
+		return nil, &RuleError{Rule: repeat, Reason: "пустое правило"}
+	}
+
+	switch fields[0] {
+	case "d":
+		if len(fields) < 2 {
+			return nil, &RuleError{Rule: repeat, Reason: "не указан интервал в днях"}
+		}
+		if len(fields) > 2 {
+			return nil, &RuleError{Rule: repeat, Token: fields[2], Reason: "лишний параметр"}
+		}
+		days, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, &RuleError{Rule: repeat, Token: fields[1], Reason: "ожидается целое число дней"}
+		}
+		if days < 1 || days > 400 {
+			return nil, &RuleError{Rule: repeat, Token: fields[1], Reason: "интервал дней должен быть от 1 до 400"}
+		}
+		return DayRule{Days: days}, nil
+
+	case "y":
+		if len(fields) > 1 {
+			return nil, &RuleError{Rule: repeat, Token: fields[1], Reason: "правило y не принимает параметров"}
+		}
+		return YearRule{}, nil
+
+	case "w":
+		if len(fields) < 2 {
+			return nil, &RuleError{Rule: repeat, Reason: "не указаны дни недели"}
+		}
+		if len(fields) > 2 {
+			return nil, &RuleError{Rule: repeat, Token: fields[2], Reason: "лишний параметр"}
+		}
+		weekdays, err := parseList(repeat, fields[1], func(n int) bool { return n >= 1 && n <= 7 },
+			"день недели должен быть от 1 до 7")
+		if err != nil {
+			return nil, err
+		}
+		return WeekRule{Weekdays: weekdays}, nil
+
+	case "m":
+		if len(fields) < 2 {
+			return nil, &RuleError{Rule: repeat, Reason: "не указаны дни месяца"}
+		}
+		if len(fields) > 3 {
+			return nil, &RuleError{Rule: repeat, Token: fields[3], Reason: "лишний параметр"}
+		}
+		days, err := parseList(repeat, fields[1], func(n int) bool { return n >= 1 && n <= 31 || n == -1 || n == -2 },
+			"день месяца должен быть от 1 до 31, -1 или -2")
+		if err != nil {
+			return nil, err
+		}
+		var months []int
+		if len(fields) > 2 {
+			months, err = parseList(repeat, fields[2], func(n int) bool { return n >= 1 && n <= 12 },
+				"месяц должен быть от 1 до 12")
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !monthRuleReachable(days, months) {
+			return nil, &RuleError{Rule: repeat, Reason: "ни один из дней не встречается в указанных месяцах"}
+		}
+		return MonthRule{Days: days, Months: months}, nil
+
+	default:
+		return nil, &RuleError{Rule: repeat, Token: fields[0], Reason: "неизвестный тип правила, ожидается d, y, w или m"}
+	}
+}
+
+// parseList парсинг списка чисел через запятую с проверкой каждого значения.
+func parseList(repeat string, list string, valid func(int) bool, reason string) ([]int, error) {
+	var result []int
+	for _, part := range strings.Split(list, ",") {
+		num, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, &RuleError{Rule: repeat, Token: part, Reason: "ожидается целое число"}
+		}
+		if !valid(num) {
+			return nil, &RuleError{Rule: repeat, Token: part, Reason: reason}
+		}
+		result = append(result, num)
+	}
+	return result, nil
+}
+
+// joinInts склеивает числа через запятую.
+func joinInts(nums []int) string {
+	parts := make([]string, len(nums))
+	for i, n := range nums {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+// monthRuleReachable проверяет, что хотя бы один из дней существует хотя бы в одном из месяцев.
+func monthRuleReachable(days []int, months []int) bool {
+	if len(months) == 0 {
+		months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	}
+	for _, month := range months {
+		// Берём високосный год, чтобы учесть 29 февраля
+		lastDay := getLastDayOfMonth(2024, month)
+		for _, day := range days {
+			if day < 0 || day <= lastDay {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r DayRule) Next(after, start time.Time) time.Time {
+	return nextDayDate(start, r.Days, after)
+}
+
+func (r DayRule) String() string {
+	return "d " + strconv.Itoa(r.Days)
+}
+
+func (r DayRule) step(prev time.Time) time.Time {
+	return prev.AddDate(0, 0, r.Days)
+}
+
+func (r YearRule) Next(after, start time.Time) time.Time {
+	next := start.AddDate(1, 0, 0)
+	if start.Month() == 2 && start.Day() == 29 && !isLeapYear(next.Year()) {
+		next = time.Date(next.Year(), time.Month(3), 1, 0, 0, 0, 0, time.UTC)
+	}
+	for next.Before(after) {
+		next = next.AddDate(1, 0, 0)
+	}
+	return next
+}
+
+func (r YearRule) String() string {
+	return "y"
+}
+
+func (r YearRule) step(prev time.Time) time.Time {
+	// 29 февраля в невисокосный год переходит на 1 марта
+	return prev.AddDate(1, 0, 0)
+}
+
+func (r WeekRule) Next(after, start time.Time) time.Time {
+	next := nextWeekdayDate(start, r.Weekdays)
+	for next.Before(after) {
+		next = nextWeekdayDate(next.AddDate(0, 0, 7), r.Weekdays)
+	}
+	return next
+}
+
+func (r WeekRule) String() string {
+	return "w " + joinInts(r.Weekdays)
+}
+
+func (r WeekRule) step(prev time.Time) time.Time {
+	return nextWeekdayDate(prev.AddDate(0, 0, 1), r.Weekdays)
+}
+
+func (r MonthRule) Next(after, start time.Time) time.Time {
+	next := nextMonthDate(start, r.Days, r.Months, after)
+	for !next.After(after) {
+		next = nextMonthDate(next.AddDate(0, 1, 0), r.Days, r.Months, after)
+	}
+	return next
+}
+
+func (r MonthRule) String() string {
+	if len(r.Months) == 0 {
+		return "m " + joinInts(r.Days)
+	}
+	return "m " + joinInts(r.Days) + " " + joinInts(r.Months)
+}
+
+func (r MonthRule) step(prev time.Time) time.Time {
+	next := prev.AddDate(0, 0, 1)
+	return nextMonthDate(next, r.Days, r.Months, next)
+}
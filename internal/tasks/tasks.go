@@ -1,11 +1,8 @@
 package tasks
 
 import (
-	"errors"
 	"fmt"
-	"sort"
-	"strconv"
-	"strings"
+	"slices"
 	"time"
 )
 
@@ -14,11 +11,33 @@ const DisplayDateFormat = "02.01.2006"
 
 type Task struct {
 	//ID      int64  `db:"id,omitempty"`
-	Id      string `json:"id,omitempty"`
-	Date    string `db:"date,omitempty" json:"date,omitempty"`
-	Title   string `db:"title,omitempty" json:"title" binding:"required"`
-	Comment string `db:"comment,omitempty" json:"comment,omitempty"`
-	Repeat  string `db:"repeat,omitempty" json:"repeat,omitempty"`
+	Id        string  `json:"id,omitempty"`
+	Date      string  `db:"date,omitempty" json:"date,omitempty"`
+	Title     string  `db:"title,omitempty" json:"title" binding:"required"`
+	Comment   string  `db:"comment,omitempty" json:"comment,omitempty"`
+	Repeat    string  `db:"repeat,omitempty" json:"repeat,omitempty"`
+	Rank      float64 `db:"-" json:"-"`                                       // релевантность при полнотекстовом поиске, 0 — без ранжирования
+	DeletedAt string  `db:"deleted_at,omitempty" json:"deleted_at,omitempty"` // время переноса в корзину в UTC, RFC 3339, только у задач из корзины
+	Version   int64   `db:"version" json:"version,string,omitempty"`          // растёт при каждом изменении, 0 в запросе — без проверки версии
+}
+
+// Counts число задач пользователя по фильтрам списка, для значков в интерфейсе.
+type Counts struct {
+	Total     int `db:"total" json:"total"`
+	Range     int `db:"in_range" json:"range"` // в интервале from..to, без интервала равно Total
+	Overdue   int `db:"overdue" json:"overdue"`
+	Repeating int `db:"repeating" json:"repeating"`
+	Once      int `db:"-" json:"once"` // без правила повторения
+}
+
+// Completion запись о выполнении задачи в истории.
+type Completion struct {
+	Id            string `json:"id"`
+	TaskId        string `json:"task_id"`
+	Title         string `json:"title"`          // заголовок на момент выполнения, разовая задача после него удаляется
+	ScheduledDate string `json:"scheduled_date"` // дата задачи на момент выполнения
+	CompletedAt   string `json:"completed_at"`   // время выполнения в UTC, RFC 3339
+	Note          string `json:"note,omitempty"`
 }
 
 // parseDate парсинг даты в формате 20060102.
@@ -52,21 +71,26 @@ func ValidateAndSetDate(task *Task, now time.Time) error {
 		return fmt.Errorf("некорректная дата: %v", err)
 	}
 
+	// Разбираем правило повторения один раз и сохраняем его в каноническом виде
+	rule, err := Parse(task.Repeat)
+	if err != nil {
+		return err
+	}
+	if rule != nil {
+		task.Repeat = rule.String()
+	}
+
 	// Сбрасываем время для now и date, оставляя только даты
 	nowDate := TruncateToDate(now)
 	dateOnly := TruncateToDate(date)
 
 	// Если дата меньше текущего дня и нет правила повторения, устанавливаем сегодняшнюю дату
-	if dateOnly.Before(nowDate) && task.Repeat == "" {
+	if dateOnly.Before(nowDate) && rule == nil {
 		task.Date = nowDate.Format(TimeFormat)
 
-	} else if dateOnly.Before(nowDate) && task.Repeat != "" {
+	} else if dateOnly.Before(nowDate) {
 		// Если дата меньше текущего дня и правило повторения указано, вычисляем следующую дату
-		nextDate, err := NextDate(nowDate, task.Date, task.Repeat)
-		if err != nil {
-			return fmt.Errorf("ошибка вычисления следующей даты: %v", err)
-		}
-		task.Date = nextDate
+		task.Date = rule.Next(nowDate, dateOnly).Format(TimeFormat)
 
 	} else if dateOnly.Equal(nowDate) {
 		// Если дата совпадает с сегодняшним днём, правило повторения не обрабатываем
@@ -94,11 +118,10 @@ func nextMonthDate(start time.Time, days []int, months []int, now time.Time) tim
 
 	// Если указаны месяцы, выбираем ближайший подходящий месяц
 	if len(months) > 0 {
-		sort.Ints(months)
 		found := false
 
 		// Ищем ближайший подходящий месяц в текущем году
-		for _, m := range months {
+		for _, m := range slices.Sorted(slices.Values(months)) {
 			if m >= month {
 				month = m
 				found = true
@@ -108,7 +131,7 @@ func nextMonthDate(start time.Time, days []int, months []int, now time.Time) tim
 
 		// Если месяц не найден в текущем году, сдвигаемся на следующий год
 		if !found {
-			month = months[0]
+			month = slices.Min(months)
 			year++
 		}
 	}
@@ -160,77 +183,41 @@ func NextDate(now time.Time, dateStr string, repeat string) (string, error) {
 		return "", fmt.Errorf("некорректная дата: %v", err)
 	}
 
-	if repeat == "" {
-		return date.Format(TimeFormat), nil
+	rule, err := Parse(repeat)
+	if err != nil {
+		return "", err
 	}
 
-	switch {
-	case strings.HasPrefix(repeat, "d "):
-		daysStr := strings.TrimPrefix(repeat, "d ")
-		days, err := strconv.Atoi(daysStr)
-		if err != nil || days < 1 || days > 400 {
-			return "", errors.New("интервал дней должен быть от 1 до 400")
-		}
-		next := nextDayDate(date, days, now)
-		return next.Format(TimeFormat), nil
-
-	case repeat == "y":
-		next := date.AddDate(1, 0, 0)
-		if date.Month() == 2 && date.Day() == 29 && !isLeapYear(next.Year()) {
-			next = time.Date(next.Year(), time.Month(3), 1, 0, 0, 0, 0, time.UTC)
-		}
-		for next.Before(now) {
-			next = next.AddDate(1, 0, 0)
-		}
-		return next.Format(TimeFormat), nil
+	if rule == nil {
+		return date.Format(TimeFormat), nil
+	}
 
-	case strings.HasPrefix(repeat, "w "):
-		weekdays, err := parseDaysOrMonths(strings.Split(strings.TrimPrefix(repeat, "w "), ","))
-		if err != nil {
-			return "", err
-		}
-		if invalidWeekdays(weekdays) {
-			return "", errors.New("недопустимый день недели")
-		}
-		nextDate := nextWeekdayDate(date, weekdays)
-		for nextDate.Before(now) {
-			nextDate = nextWeekdayDate(nextDate.AddDate(0, 0, 7), weekdays)
-		}
-		return nextDate.Format(TimeFormat), nil
+	return rule.Next(now, date).Format(TimeFormat), nil
+}
 
-	case strings.HasPrefix(repeat, "m "):
-		parts := strings.Split(strings.TrimPrefix(repeat, "m "), " ")
-		days, err := parseDaysOrMonths(strings.Split(parts[0], ","))
-		if err != nil {
-			return "", err
-		}
-		if invalidMonthDays(days) {
-			return "", errors.New("недопустимый день месяца")
-		}
-		var months []int
-		if len(parts) > 1 {
-			months, err = parseDaysOrMonths(strings.Split(parts[1], ","))
-			if err != nil {
-				return "", err
-			}
-			if invalidMonths(months) {
-				return "", errors.New("недопустимый месяц")
-			}
-		}
-		nextDate := nextMonthDate(date, days, months, now)
-		for !nextDate.After(now) {
-			nextDate = nextMonthDate(nextDate.AddDate(0, 1, 0), days, months, now)
-		}
-		return nextDate.Format(TimeFormat), nil
+// DoneDate дата, на которую переносится задача, выполненная в момент now.
+// Для разовой задачи возвращается пустая строка: после выполнения её нет в списке.
+func DoneDate(task Task, now time.Time) (string, error) {
+	rule, err := Parse(task.Repeat)
+	if err != nil {
+		return "", err
+	}
+	if rule == nil {
+		return "", nil
+	}
 
-	default:
-		return "", errors.New("неподдерживаемый формат правила повторения")
+	date, err := time.Parse(TimeFormat, task.Date)
+	if err != nil {
+		return "", fmt.Errorf("некорректная дата задачи: %w", err)
 	}
+
+	// Сбрасываем время и вычисляем следующую дату
+	return rule.Next(TruncateToDate(now), date).Format(TimeFormat), nil
 }
 
 // nextWeekdayDate следующая ближайшая дата по дням недели.
 func nextWeekdayDate(start time.Time, weekdays []int) time.Time {
-	sort.Ints(weekdays)
+	weekdays = slices.Sorted(slices.Values(weekdays))
 	weekday := int(start.Weekday())
 	if weekday == 0 {
 		weekday = 7
@@ -246,50 +233,6 @@ func nextWeekdayDate(start time.Time, weekdays []int) time.Time {
 	return start.AddDate(0, 0, 7-weekday+weekdays[0])
 }
 
-// parseDaysOrMonths парсинг дней/месяцев из строки.
-func parseDaysOrMonths(parts []string) ([]int, error) {
-	var result []int
-	for _, part := range parts {
-		num, err := strconv.Atoi(part)
-		if err != nil || num < -2 || num > 31 {
-			return nil, fmt.Errorf("недопустимое значение: %s", part)
-		}
-		result = append(result, num)
-	}
-
-	return result, nil
-}
-
-// invalidWeekdays проверка на недопустимые дни недели.
-func invalidWeekdays(weekdays []int) bool {
-	for _, wd := range weekdays {
-		if wd < 1 || wd > 7 {
-			return true
-		}
-	}
-	return false
-}
-
-// invalidMonthDays проверка на недопустимые дни месяца.
-func invalidMonthDays(days []int) bool {
-	for _, day := range days {
-		if day < -2 || day > 31 {
-			return true
-		}
-	}
-	return false
-}
-
-// invalidMonths проверка на недопустимые месяцы.
-func invalidMonths(months []int) bool {
-	for _, month := range months {
-		if month < 1 || month > 12 {
-			return true
-		}
-	}
-	return false
-}
-
 // isLeapYear проверка на високосный год.
 func isLeapYear(year int) bool {
 	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
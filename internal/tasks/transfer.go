@@ -0,0 +1,16 @@
+package tasks
+
+// Что импорт сделал с задачей.
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+)
+
+// ImportResult результат импорта одной задачи из файла.
+type ImportResult struct {
+	Row    int    `json:"row"`              // номер задачи в файле с 1, строка заголовка CSV не считается
+	Id     string `json:"id,omitempty"`     // id задачи в БД, при пробном импорте у новых задач пустой
+	Action string `json:"action,omitempty"` // ImportCreated или ImportUpdated
+	Error  string `json:"error,omitempty"`  // причина, по которой задача не прошла проверку
+	Task   *Task  `json:"-"`                // задача после импорта, нужна для событий
+}
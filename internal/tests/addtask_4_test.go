@@ -14,7 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func requestJSON(apipath string, values map[string]any, method string) ([]byte, error) {
+func (ts *testServer) requestJSON(apipath string, values map[string]any, method string) ([]byte, error) {
 	var (
 		data []byte
 		err  error
@@ -28,14 +28,14 @@ func requestJSON(apipath string, values map[string]any, method string) ([]byte,
 	}
 	var resp *http.Response
 
-	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	req, err := http.NewRequest(method, ts.getURL(apipath), bytes.NewBuffer(data))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{}
-	if len(Token) > 0 {
+	if len(ts.token) > 0 {
 		jar, err := cookiejar.New(nil)
 		if err != nil {
 			return nil, err
@@ -43,7 +43,7 @@ func requestJSON(apipath string, values map[string]any, method string) ([]byte,
 		jar.SetCookies(req.URL, []*http.Cookie{
 			{
 				Name:  "token",
-				Value: Token,
+				Value: ts.token,
 			},
 		})
 		client.Jar = jar
@@ -60,13 +60,13 @@ func requestJSON(apipath string, values map[string]any, method string) ([]byte,
 	return io.ReadAll(resp.Body)
 }
 
-func postJSON(apipath string, values map[string]any, method string) (map[string]any, error) {
+func (ts *testServer) postJSON(apipath string, values map[string]any, method string) (map[string]any, error) {
 	var (
 		m   map[string]any
 		err error
 	)
 
-	body, err := requestJSON(apipath, values, method)
+	body, err := ts.requestJSON(apipath, values, method)
 	if err != nil {
 		return nil, err
 	}
@@ -82,8 +82,9 @@ type task struct {
 }
 
 func TestAddTask(t *testing.T) {
-	db := openDB(t)
-	defer db.Close()
+	t.Parallel()
+	ts := newTestServer(t)
+	db := ts.db
 
 	tbl := []task{
 		{"20240129", "", "", ""},
@@ -93,7 +94,7 @@ func TestAddTask(t *testing.T) {
 		{"20240212", "Заголовок", "", "ooops"},
 	}
 	for _, v := range tbl {
-		m, err := postJSON("api/task", map[string]any{
+		m, err := ts.postJSON("api/task", map[string]any{
 			"date":    v.date,
 			"title":   v.title,
 			"comment": v.comment,
@@ -114,7 +115,7 @@ func TestAddTask(t *testing.T) {
 			if today {
 				v.date = now.Format(`20060102`)
 			}
-			m, err := postJSON("api/task", map[string]any{
+			m, err := ts.postJSON("api/task", map[string]any{
 				"date":    v.date,
 				"title":   v.title,
 				"comment": v.comment,
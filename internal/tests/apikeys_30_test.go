@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withAuthorization запрос к API с заголовком Authorization вместо куки.
+func (ts *testServer) withAuthorization(t *testing.T, method, apipath string, values map[string]any, authorization string) (int, map[string]any) {
+	var body []byte
+	if len(values) > 0 {
+		var err error
+		body, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, ts.getURL(apipath), bytes.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+
+	var ret map[string]any
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&ret))
+	return resp.StatusCode, ret
+}
+
+// createAPIKey создаёт ключ с областями scopes и возвращает его id и сам ключ.
+func (ts *testServer) createAPIKey(t *testing.T, name string, scopes ...string) (string, string) {
+	code, ret := ts.statusOf(t, "api/keys", map[string]any{"name": name, "scopes": scopes}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, ret)
+	id, _ := ret["id"].(string)
+	key, _ := ret["key"].(string)
+	return id, key
+}
+
+func TestBearerToken(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+	ts.addTask(t, task{date: time.Now().Format(`20060102`), title: "Задача"})
+
+	for _, scheme := range []string{"Bearer ", "bearer "} {
+		code, ret := ts.withAuthorization(t, http.MethodGet, "api/tasks", nil, scheme+ts.token)
+		assert.Equal(t, http.StatusOK, code, ret)
+		assert.Len(t, ret["tasks"], 1)
+	}
+	for _, header := range []string{"Basic " + ts.token, "Bearer", ts.token} {
+		code, ret := ts.withAuthorization(t, http.MethodGet, "api/tasks", nil, header)
+		assert.Equal(t, http.StatusUnauthorized, code, header)
+		assert.Equal(t, "Неверный заголовок Authorization, ожидается Bearer", ret["error"])
+	}
+	code, ret := ts.withAuthorization(t, http.MethodGet, "api/tasks", nil, "Bearer не.jwt.токен")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Неверный токен", ret["error"])
+
+	// С токеном в заголовке доступно и то, что недоступно по API-ключу
+	code, _ = ts.withAuthorization(t, http.MethodGet, "api/keys", nil, "Bearer "+ts.token)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = ts.withAuthorization(t, http.MethodPost, "api/logout", nil, "Bearer "+ts.token)
+	assert.Equal(t, http.StatusOK, code)
+	code, ret = ts.withAuthorization(t, http.MethodGet, "api/tasks", nil, "Bearer "+ts.token)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Сессия завершена, войдите заново", ret["error"])
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+	today := time.Now().Format(`20060102`)
+	ts.addTask(t, task{date: today, title: "Задача администратора"})
+
+	for _, body := range []map[string]any{
+		{"scopes": []string{"tasks:read"}},
+		{"name": "cron"},
+		{"name": "cron", "scopes": []string{"tasks:delete"}},
+	} {
+		code, ret := ts.statusOf(t, "api/keys", body, http.MethodPost)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.NotEmpty(t, ret["error"])
+	}
+
+	readID, readKey := ts.createAPIKey(t, "отчёт", "tasks:read", "tasks:read")
+	writeID, writeKey := ts.createAPIKey(t, "cron", "tasks:write", "tasks:read")
+	assert.True(t, strings.HasPrefix(readKey, "todo_"), readKey)
+	assert.Len(t, readKey, len("todo_")+64)
+	assert.NotEqual(t, readKey, writeKey)
+
+	code, ret := ts.statusOf(t, "api/keys", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	if keys, _ := ret["keys"].([]any); assert.Len(t, keys, 2) {
+		first := keys[0].(map[string]any)
+		assert.Equal(t, "отчёт", first["name"])
+		assert.Equal(t, []any{"tasks:read"}, first["scopes"])
+		assert.Equal(t, readKey[:13], first["prefix"])
+		assert.NotContains(t, first, "key", "Ключ выдаётся только при создании")
+		assert.NotContains(t, first, "last_used_at")
+		assert.Equal(t, []any{"tasks:read", "tasks:write"}, keys[1].(map[string]any)["scopes"])
+	}
+
+	// Ключ только для чтения читает задачи своего пользователя, но не меняет их
+	code, ret = ts.withAuthorization(t, http.MethodGet, "api/tasks", nil, "Bearer "+readKey)
+	assert.Equal(t, http.StatusOK, code, ret)
+	assert.Len(t, ret["tasks"], 1)
+	code, ret = ts.withAuthorization(t, http.MethodPost, "api/task", map[string]any{"date": today, "title": "Из скрипта"}, "Bearer "+readKey)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, "У API-ключа нет доступа tasks:write", ret["error"])
+
+	code, ret = ts.withAuthorization(t, http.MethodPost, "api/task", map[string]any{"date": today, "title": "Из скрипта"}, "Bearer "+writeKey)
+	assert.Equal(t, http.StatusOK, code, ret)
+	assert.Len(t, ts.getTasks(t, ""), 2)
+
+	// Управление ключами, подписками и сервером по ключу недоступно
+	for _, path := range []string{"api/keys", "api/webhooks", "api/calendar/feeds", "api/sessions", "api/admin/backups"} {
+		code, ret = ts.withAuthorization(t, http.MethodGet, path, nil, "Bearer "+writeKey)
+		assert.Equal(t, http.StatusForbidden, code, path)
+		assert.Equal(t, "Недоступно по API-ключу, войдите с паролем", ret["error"], path)
+	}
+
+	// Время последнего использования
+	code, ret = ts.statusOf(t, "api/keys", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	for _, k := range ret["keys"].([]any) {
+		used, err := time.Parse(time.RFC3339, k.(map[string]any)["last_used_at"].(string))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), used, time.Minute)
+	}
+
+	// Чужой ключ не отозвать, отозванный ключ сразу перестаёт действовать
+	bob := ts.registerUser(t, "bob", "bob-password")
+	ret, err := ts.postJSONAs(bob, "api/keys?id="+readID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ключ не найден", ret["error"])
+	code, _ = ts.statusOf(t, "api/keys?id="+readID, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, code)
+	code, ret = ts.withAuthorization(t, http.MethodGet, "api/tasks", nil, "Bearer "+readKey)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Неверный API-ключ", ret["error"])
+	code, _ = ts.withAuthorization(t, http.MethodGet, "api/tasks", nil, "Bearer "+writeKey)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = ts.statusOf(t, "api/keys?id="+writeID+"0", nil, http.MethodDelete)
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -1,32 +1,23 @@
 package tests
 
 import (
-	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func getURL(path string) string {
-	port := Port
-	envPort := os.Getenv("TODO_PORT")
-	if len(envPort) > 0 {
-		if eport, err := strconv.ParseInt(envPort, 10, 32); err == nil {
-			port = int(eport)
-		}
-	}
+func (ts *testServer) getURL(path string) string {
 	path = strings.TrimPrefix(strings.ReplaceAll(path, `\`, `/`), `../web/`)
-	return fmt.Sprintf("http://localhost:%d/%s", port, path)
+	return ts.URL + "/" + path
 }
 
-func getBody(path string) ([]byte, error) {
-	resp, err := http.Get(getURL(path))
+func (ts *testServer) getBody(path string) ([]byte, error) {
+	resp, err := http.Get(ts.getURL(path))
 	if err != nil {
 		return nil, err
 	}
@@ -56,12 +47,15 @@ func walkDir(path string, f func(fname string) error) error {
 }
 
 func TestApp(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
 	cmp := func(fname string) error {
 		fbody, err := os.ReadFile(fname)
 		if err != nil {
 			return err
 		}
-		body, err := getBody(fname)
+		body, err := ts.getBody(fname)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/tasks"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// countBackupTasks число задач в файле БД.
+func countBackupTasks(t *testing.T, path string) int {
+	db, err := sqlx.Open(repository.DriverSQLite, path)
+	if !assert.NoError(t, err) {
+		return -1
+	}
+	defer db.Close()
+
+	var n int
+	assert.NoError(t, db.Get(&n, `SELECT count(*) FROM scheduler`))
+	return n
+}
+
+func TestBackupRestore(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "scheduler.db")
+	backups := filepath.Join(dir, "backups")
+
+	repo, ok := openStore(t, config.Config{DBFile: dbFile})
+	if !ok {
+		return
+	}
+	admin, err := repo.GetUserByLogin("admin")
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = repo.CreateTask(admin.Id, &tasks.Task{Date: "20240101", Title: fmt.Sprint("Задача ", i)})
+		assert.NoError(t, err)
+	}
+
+	// Снимок снимается, пока другие соединения пишут в БД
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			_, err := repo.CreateTask(admin.Id, &tasks.Task{Date: "20240102", Title: "Фоновая"})
+			assert.NoError(t, err)
+		}
+	}()
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	file, err := repo.Backup(backups, "scheduler", start)
+	wg.Wait()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "scheduler-20261001-120000.db", file.Name)
+	assert.NoError(t, repository.CheckBackup(file.Path))
+	snapshotTasks := countBackupTasks(t, file.Path)
+	assert.GreaterOrEqual(t, snapshotTasks, 3)
+
+	// Старые снимки удаляются сверх заданного числа, последние первыми
+	for i := 1; i <= 2; i++ {
+		_, err = repo.Backup(backups, "scheduler", start.Add(time.Duration(i)*time.Hour))
+		assert.NoError(t, err)
+	}
+	removed, err := repository.PruneBackups(backups, "scheduler", 2)
+	assert.NoError(t, err)
+	if assert.Len(t, removed, 1) {
+		assert.Equal(t, file.Name, removed[0].Name)
+	}
+	files, err := repository.ListBackups(backups, "scheduler")
+	assert.NoError(t, err)
+	if assert.Len(t, files, 2) {
+		assert.Equal(t, "scheduler-20261001-140000.db", files[0].Name)
+		assert.Equal(t, "2026-10-01T13:00:00Z", files[1].CreatedAt)
+	}
+
+	// Восстановление подменяет файл БД и сохраняет прежний
+	_, err = repo.CreateTask(admin.Id, &tasks.Task{Date: "20240103", Title: "После снимка"})
+	assert.NoError(t, err)
+	current := countBackupTasks(t, dbFile)
+	previous, err := repository.RestoreBackup(files[1].Path, dbFile, start.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, dbFile+".before-restore-20261002-120000", previous)
+	assert.Equal(t, current, countBackupTasks(t, previous))
+	assert.Equal(t, countBackupTasks(t, files[1].Path), countBackupTasks(t, dbFile))
+	assert.Less(t, countBackupTasks(t, dbFile), current)
+}
+
+func TestCheckBackup(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	repo, ok := openStore(t, config.Config{DBFile: filepath.Join(dir, "scheduler.db")})
+	if !ok {
+		return
+	}
+	file, err := repo.Backup(dir, "scheduler", time.Now())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Копия снимка, испорченная запросом query
+	broken := func(name, query string) string {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(file.Path)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(path, data, 0o644))
+		db, err := sqlx.Open(repository.DriverSQLite, path)
+		assert.NoError(t, err)
+		defer db.Close()
+		_, err = db.Exec(query)
+		assert.NoError(t, err)
+		return path
+	}
+
+	notDB := filepath.Join(dir, "junk.db")
+	assert.NoError(t, os.WriteFile(notDB, []byte("это не база данных"), 0o644))
+
+	for _, path := range []string{
+		notDB,
+		filepath.Join(dir, "missing.db"),
+		broken("newer.db", `INSERT INTO schema_migrations (version, name, applied_at) VALUES (999, 'future', '')`),
+		broken("gap.db", `DELETE FROM schema_migrations WHERE version = 2`),
+		broken("renamed.db", `UPDATE schema_migrations SET name = 'other' WHERE version = 1`),
+		broken("tables.db", `DROP TABLE scheduler`),
+	} {
+		assert.Error(t, repository.CheckBackup(path), path)
+	}
+
+	// Проверка не даёт восстановить испорченный снимок, файл БД не меняется
+	dbFile := filepath.Join(dir, "target.db")
+	assert.NoError(t, os.WriteFile(dbFile, []byte("прежняя БД"), 0o644))
+	_, err = repository.RestoreBackup(notDB, dbFile, time.Now())
+	assert.Error(t, err)
+	data, err := os.ReadFile(dbFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "прежняя БД", string(data))
+}
+
+func TestBackupAPI(t *testing.T) {
+	t.Parallel()
+	backups := t.TempDir()
+	ts := newTestServerWith(t, func(cfg *config.Config) {
+		cfg.BackupDir = backups
+		cfg.BackupKeep = 1
+	})
+	ts.addTask(t, task{title: "Задача в снимке"})
+
+	status, ret := ts.statusOf(t, "api/admin/backup", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	name, _ := ret["name"].(string)
+	assert.Regexp(t, `^scheduler-\d{8}-\d{6}\.db$`, name)
+	assert.NoError(t, repository.CheckBackup(filepath.Join(backups, name)))
+	assert.Equal(t, 1, countBackupTasks(t, filepath.Join(backups, name)))
+
+	ret, err := ts.postJSON("api/admin/backups", nil, http.MethodGet)
+	assert.NoError(t, err)
+	list, _ := ret["backups"].([]any)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, name, list[0].(map[string]any)["name"])
+	}
+
+	// Обычному пользователю обслуживание недоступно
+	bob := ts.registerUser(t, "bob", "bobpassword")
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		path := map[string]string{http.MethodPost: "api/admin/backup", http.MethodGet: "api/admin/backups"}[method]
+		ret, err = ts.postJSONAs(bob, path, nil, method)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"])
+	}
+	entries, err := os.ReadDir(backups)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
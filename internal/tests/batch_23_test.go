@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// batchResults результаты операций из ответа POST /api/tasks/batch.
+func batchResults(ret map[string]any) []map[string]any {
+	var list []map[string]any
+	items, _ := ret["results"].([]any)
+	for _, v := range items {
+		item, _ := v.(map[string]any)
+		list = append(list, item)
+	}
+	return list
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	once := ts.addTask(t, task{date: today, title: "Купить молоко"})
+	repeating := ts.addTask(t, task{date: today, title: "Зарядка", repeat: "d 2"})
+	shifted := ts.addTask(t, task{date: today, title: "Позвонить маме"})
+	removed := ts.addTask(t, task{date: today, title: "Черновик"})
+
+	status, ret := ts.statusOf(t, "api/tasks/batch", map[string]any{"ops": []map[string]any{
+		{"op": "create", "date": "20200101", "title": "Новая задача"},
+		{"op": "update", "id": once, "date": today, "title": "Купить кефир", "version": "1"},
+		{"op": "done", "id": repeating, "note": " утром "},
+		{"op": "shift", "id": shifted, "days": 3},
+		{"op": "delete", "id": removed},
+	}}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	results := batchResults(ret)
+	if assert.Len(t, results, 5) {
+		assert.Equal(t, "create", results[0]["op"])
+		assert.NotEmpty(t, results[0]["id"])
+		assert.Equal(t, today, results[0]["date"], "Прошедшая дата разовой задачи заменяется сегодняшней")
+		assert.Equal(t, "2", results[1]["version"])
+		assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), results[2]["date"])
+		assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), results[3]["date"])
+		assert.Equal(t, "2", results[4]["version"])
+		assert.Nil(t, results[4]["date"])
+	}
+
+	assert.Equal(t, "Купить кефир", ts.getTask(t, once)["title"])
+	assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), ts.getTask(t, shifted)["date"])
+	ts.notFoundTask(t, removed)
+	history := ts.getHistory(t, url.Values{"id": {repeating}})
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, "утром", history[0]["note"])
+	}
+
+	// Ошибка в одной операции отменяет весь пакет
+	status, ret = ts.statusOf(t, "api/tasks/batch", map[string]any{"ops": []map[string]any{
+		{"op": "delete", "id": once},
+		{"op": "create", "title": "Не будет создана"},
+		{"op": "done", "id": removed},
+	}}, http.MethodPost)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.NotEmpty(t, ret["error"])
+	results = batchResults(ret)
+	if assert.Len(t, results, 3) {
+		assert.Empty(t, results[0]["error"])
+		assert.Nil(t, results[1]["id"])
+		assert.NotEmpty(t, results[2]["error"])
+	}
+	assert.Equal(t, "Купить кефир", ts.getTask(t, once)["title"])
+	assert.Len(t, ts.getTasks(t, ""), 4)
+
+	// Устаревшая версия возвращает 409 и текущую задачу
+	status, ret = ts.statusOf(t, "api/tasks/batch", map[string]any{"ops": []map[string]any{
+		{"op": "shift", "id": shifted, "days": -1},
+		{"op": "done", "id": once, "version": "1"},
+	}}, http.MethodPost)
+	assert.Equal(t, http.StatusConflict, status)
+	results = batchResults(ret)
+	if assert.Len(t, results, 2) {
+		if current, ok := results[1]["task"].(map[string]any); assert.True(t, ok, "Нет текущей задачи: %v", ret) {
+			assert.Equal(t, "2", current["version"])
+		}
+	}
+	assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), ts.getTask(t, shifted)["date"])
+	assert.Len(t, ts.getHistory(t, url.Values{"id": {once}}), 0)
+
+	// Неверные операции отклоняются до выполнения, причина у каждой
+	status, ret = ts.statusOf(t, "api/tasks/batch", map[string]any{"ops": []map[string]any{
+		{"op": "create", "title": "Без правила", "repeat": "x 1"},
+		{"op": "delete", "id": once},
+		{"op": "update", "id": once, "date": "01.01.2024", "title": "Дата"},
+		{"op": "shift", "id": shifted},
+		{"op": "done"},
+		{"op": "archive", "id": once},
+	}}, http.MethodPost)
+	assert.Equal(t, http.StatusBadRequest, status)
+	results = batchResults(ret)
+	if assert.Len(t, results, 6) {
+		assert.Empty(t, results[1]["error"])
+		for _, i := range []int{0, 2, 3, 4, 5} {
+			assert.NotEmpty(t, results[i]["error"], "Ожидается ошибка операции %d", i)
+		}
+	}
+	assert.NotEmpty(t, ts.getTask(t, once)["id"])
+
+	for _, body := range []map[string]any{{}, {"ops": []map[string]any{}}} {
+		status, ret = ts.statusOf(t, "api/tasks/batch", body, http.MethodPost)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.NotEmpty(t, ret["error"])
+	}
+
+	// Чужие задачи в пакете не найдены
+	bob := ts.registerUser(t, "bob", "bobpassword")
+	ret, err := ts.postJSONAs(bob, "api/tasks/batch", map[string]any{"ops": []map[string]any{
+		{"op": "delete", "id": once},
+	}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	assert.NotEmpty(t, ts.getTask(t, once)["id"])
+}
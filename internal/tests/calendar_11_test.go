@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendar(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	id := ts.addTask(t, task{
+		date:    date,
+		title:   "Сдать отчёт; квартальный",
+		comment: "Приложить акты",
+		repeat:  "w 1,5",
+	})
+
+	ret, err := ts.postJSON("api/calendar/feeds", map[string]any{"name": "Команда"}, http.MethodPost)
+	assert.NoError(t, err)
+	token, ok := ret["token"].(string)
+	if !assert.True(t, ok && token != "", "Не возвращён токен подписки: %v", ret) {
+		return
+	}
+	feedID := fmt.Sprint(ret["id"])
+
+	body, err := ts.getBody("api/calendar.ics?token=" + url.QueryEscape(token))
+	assert.NoError(t, err)
+	ics := string(body)
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "UID:task-"+id+"@go_final_project_avp\r\n")
+	assert.Contains(t, ics, "DTSTART;VALUE=DATE:"+date+"\r\n")
+	assert.Contains(t, ics, `SUMMARY:Сдать отчёт\; квартальный`+"\r\n")
+	assert.Contains(t, ics, "DESCRIPTION:Приложить акты\r\n")
+	assert.Contains(t, ics, "RRULE:FREQ=WEEKLY;BYDAY=MO,FR\r\n")
+
+	body, err = ts.getBody("api/calendar.ics?component=vtodo&search=" + url.QueryEscape("акты") +
+		"&token=" + url.QueryEscape(token))
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "BEGIN:VTODO\r\n")
+	assert.Contains(t, string(body), "UID:task-"+id+"@go_final_project_avp\r\n")
+
+	resp, err := http.Get(ts.getURL("api/calendar.ics?token=wrong"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	ret, err = ts.postJSON("api/calendar/feeds?id="+feedID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	resp, err = http.Get(ts.getURL("api/calendar.ics?token=" + url.QueryEscape(token)))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -1,21 +1,23 @@
 package tests
 
 import (
-	"os"
+	"database/sql"
 	"testing"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID        int64          `db:"id"`
+	Date      string         `db:"date"`
+	Title     string         `db:"title"`
+	Comment   string         `db:"comment"`
+	Repeat    string         `db:"repeat"`
+	UserID    sql.NullInt64  `db:"user_id"`
+	DeletedAt sql.NullString `db:"deleted_at"`
+	Version   int64          `db:"version"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -23,20 +25,10 @@ func count(db *sqlx.DB) (int, error) {
 	return count, db.Get(&count, `SELECT count(id) FROM scheduler`)
 }
 
-func openDB(t *testing.T) *sqlx.DB {
-	dbfile := DBFile
-	envFile := os.Getenv("TODO_DBFILE")
-	if len(envFile) > 0 {
-		dbfile = envFile
-	}
-	db, err := sqlx.Connect("sqlite3", dbfile)
-	assert.NoError(t, err)
-	return db
-}
-
 func TestDB(t *testing.T) {
-	db := openDB(t)
-	defer db.Close()
+	t.Parallel()
+	ts := newTestServer(t)
+	db := ts.db
 
 	before, err := count(db)
 	assert.NoError(t, err)
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getCounts счётчики задач из ответа GET /api/tasks.
+func (ts *testServer) getCounts(t *testing.T, query url.Values) map[string]any {
+	ret, err := ts.postJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	counts, _ := ret["counts"].(map[string]any)
+	return counts
+}
+
+func TestListFilter(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	now := time.Now()
+	day := func(days int) string { return now.AddDate(0, 0, days).Format(`20060102`) }
+
+	// Просроченные задачи API не создаёт, добавляем их напрямую в БД
+	for _, v := range []task{
+		{date: day(-10), title: "Просроченный отчёт"},
+		{date: day(-1), title: "Полить цветы", repeat: "d 2"},
+	} {
+		_, err := ts.db.Exec(`INSERT INTO scheduler (date, title, comment, repeat, user_id)
+	VALUES (?, ?, '', ?, (SELECT id FROM users WHERE login = 'admin'))`, v.date, v.title, v.repeat)
+		assert.NoError(t, err)
+	}
+	ts.addTask(t, task{date: day(0), title: "Сегодня"})
+	ts.addTask(t, task{date: day(3), title: "Планёрка", repeat: "w 1,2,3,4,5,6,7"})
+	ts.addTask(t, task{date: day(6), title: "Через неделю"})
+	ts.addTask(t, task{date: day(30), title: "Через месяц"})
+
+	titles := func(query url.Values) []string {
+		ret, err := ts.postJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Nil(t, ret["error"], "Неожиданная ошибка для %v", query)
+		var list []string
+		items, _ := ret["tasks"].([]any)
+		for _, v := range items {
+			item, _ := v.(map[string]any)
+			list = append(list, item["title"].(string))
+		}
+		return list
+	}
+
+	tbl := []struct {
+		query url.Values
+		want  []string
+	}{
+		{url.Values{"from": {day(0)}, "to": {day(7)}}, []string{"Сегодня", "Планёрка", "Через неделю"}},
+		{url.Values{"from": {day(7)}}, []string{"Через месяц"}},
+		{url.Values{"to": {day(-1)}}, []string{"Просроченный отчёт", "Полить цветы"}},
+		{url.Values{"overdue": {"true"}}, []string{"Просроченный отчёт", "Полить цветы"}},
+		{url.Values{"overdue": {"false"}, "to": {day(6)}}, []string{"Сегодня", "Планёрка", "Через неделю"}},
+		{url.Values{"repeating": {"true"}}, []string{"Полить цветы", "Планёрка"}},
+		{url.Values{"repeating": {"false"}, "overdue": {"true"}}, []string{"Просроченный отчёт"}},
+		{url.Values{"repeating": {"false"}, "search": {"Через"}, "from": {day(7)}}, []string{"Через месяц"}},
+	}
+	for _, v := range tbl {
+		// Фильтры не меняют порядок по дате
+		assert.Equal(t, v.want, titles(v.query), v.query.Encode())
+	}
+
+	// Фильтры работают вместе с постраничным выводом
+	ids, next := ts.getPage(t, url.Values{"from": {day(0)}, "limit": {"2"}})
+	assert.Len(t, ids, 2)
+	ids, next = ts.getPage(t, url.Values{"from": {day(0)}, "limit": {"2"}, "cursor": {next}})
+	assert.Len(t, ids, 2)
+	assert.Empty(t, next)
+
+	// Счётчики считаются по всем задачам, интервал — по from и to
+	counts := ts.getCounts(t, url.Values{"from": {day(0)}, "to": {day(7)}, "repeating": {"true"}})
+	assert.Equal(t, map[string]any{
+		"total":     float64(6),
+		"range":     float64(3),
+		"overdue":   float64(2),
+		"repeating": float64(2),
+		"once":      float64(4),
+	}, counts)
+	assert.Equal(t, float64(6), ts.getCounts(t, nil)["range"])
+
+	for _, query := range []url.Values{
+		{"from": {"01.01.2024"}},
+		{"to": {"2024"}},
+		{"from": {day(7)}, "to": {day(0)}},
+		{"overdue": {"yes"}},
+		{"repeating": {"1x"}},
+	} {
+		ret, err := ts.postJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для %v", query.Encode())
+	}
+}
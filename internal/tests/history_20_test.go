@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getHistory записи истории выполнения из GET /api/task/history.
+func (ts *testServer) getHistory(t *testing.T, query url.Values) []map[string]any {
+	ret, err := ts.postJSON("api/task/history?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["error"], "Неожиданная ошибка для %v", query.Encode())
+
+	var list []map[string]any
+	items, _ := ret["completions"].([]any)
+	for _, v := range items {
+		item, _ := v.(map[string]any)
+		list = append(list, item)
+	}
+	return list
+}
+
+func TestTaskHistory(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	once := ts.addTask(t, task{date: today, title: "Сдать отчёт"})
+	ret, err := ts.postJSON("api/task/done?id="+once, map[string]any{"note": " отправлен руководителю "}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ts.notFoundTask(t, once)
+
+	repeating := ts.addTask(t, task{date: today, title: "Зарядка", repeat: "d 1"})
+	for i := 0; i < 2; i++ {
+		ret, err = ts.postJSON("api/task/done?id="+repeating, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	// Разовая задача удалена, но её выполнение осталось в истории
+	history := ts.getHistory(t, url.Values{"id": {once}})
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, "Сдать отчёт", history[0]["title"])
+		assert.Equal(t, today, history[0]["scheduled_date"])
+		assert.Equal(t, "отправлен руководителю", history[0]["note"])
+		completedAt, err := time.Parse(time.RFC3339, history[0]["completed_at"].(string))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, now, completedAt, time.Minute)
+	}
+
+	// У повторяющейся задачи в истории даты, на которые она была назначена
+	history = ts.getHistory(t, url.Values{"id": {repeating}})
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, today, history[0]["scheduled_date"])
+		assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), history[1]["scheduled_date"])
+		assert.Nil(t, history[1]["note"])
+	}
+
+	// Интервал — по дате выполнения
+	assert.Len(t, ts.getHistory(t, nil), 3)
+	assert.Len(t, ts.getHistory(t, url.Values{"from": {today}, "to": {today}}), 3)
+	assert.Empty(t, ts.getHistory(t, url.Values{"from": {now.AddDate(0, 0, 1).Format(`20060102`)}}))
+	assert.Empty(t, ts.getHistory(t, url.Values{"to": {now.AddDate(0, 0, -1).Format(`20060102`)}}))
+
+	// Чужая история не видна
+	bob := ts.registerUser(t, "bob", "bobpassword")
+	ret, err = ts.postJSONAs(bob, "api/task/history", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{}, ret["completions"])
+
+	for _, query := range []url.Values{
+		{"id": {"abc"}},
+		{"from": {"01.01.2024"}},
+		{"from": {today}, "to": {now.AddDate(0, 0, -1).Format(`20060102`)}},
+	} {
+		ret, err = ts.postJSON("api/task/history?"+query.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для %v", query.Encode())
+	}
+
+	// Неверное тело запроса не отмечает задачу выполненной
+	ret, err = ts.postJSON("api/task/done?id="+repeating, map[string]any{"note": 42}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	assert.Len(t, ts.getHistory(t, url.Values{"id": {repeating}}), 2)
+}
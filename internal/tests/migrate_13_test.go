@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/repository"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	slogavp.SetLogConsole(false)
+	app := slogavp.SetupApplication()
+
+	cfg := config.Config{DBFile: filepath.Join(t.TempDir(), "scheduler.db")}
+	db, err := repository.NewOpenDB(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	repo := repository.NewRepository(db, app)
+
+	states, err := repo.MigrationStatus()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, states)
+	for _, s := range states {
+		assert.False(t, s.Applied, "Миграция %04d_%s не должна быть применена", s.Version, s.Name)
+	}
+
+	assert.NoError(t, repo.RunMigrations(cfg))
+	states, err = repo.MigrationStatus()
+	assert.NoError(t, err)
+	for i, s := range states {
+		assert.True(t, s.Applied, "Миграция %04d_%s должна быть применена", s.Version, s.Name)
+		if i > 0 {
+			assert.Greater(t, s.Version, states[i-1].Version)
+		}
+	}
+
+	// Повторный запуск ничего не меняет
+	assert.NoError(t, repo.RunMigrations(cfg))
+
+	_, err = db.Exec(`INSERT INTO scheduler (date, title, comment, repeat, user_id) VALUES ('20240101', 'Todo', '', '', 1)`)
+	assert.NoError(t, err)
+
+	// Откат всех миграций и повторное применение
+	assert.NoError(t, repo.MigrateDown(len(states)))
+	states, err = repo.MigrationStatus()
+	assert.NoError(t, err)
+	for _, s := range states {
+		assert.False(t, s.Applied, "Миграция %04d_%s должна быть откачена", s.Version, s.Name)
+	}
+	var tables int
+	assert.NoError(t, db.Get(&tables, `SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'scheduler'`))
+	assert.Equal(t, 0, tables)
+
+	assert.NoError(t, repo.MigrateUp(1))
+	states, err = repo.MigrationStatus()
+	assert.NoError(t, err)
+	assert.True(t, states[0].Applied)
+	assert.False(t, states[1].Applied)
+
+	assert.NoError(t, repo.MigrateUp(0))
+	var admins int
+	assert.NoError(t, db.Get(&admins, `SELECT count(*) FROM users WHERE login = 'admin'`))
+	assert.Equal(t, 1, admins)
+}
@@ -17,6 +17,9 @@ type nextDate struct {
 }
 
 func TestNextDate(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
 	tbl := []nextDate{
 		{"20240126", "", ""},
 		{"20240126", "k 34", ""},
@@ -41,7 +44,7 @@ func TestNextDate(t *testing.T) {
 		for _, v := range tbl {
 			urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
 				url.QueryEscape(v.date), url.QueryEscape(v.repeat))
-			get, err := getBody(urlPath)
+			get, err := ts.getBody(urlPath)
 			assert.NoError(t, err)
 			next := strings.TrimSpace(string(get))
 			_, err = time.Parse("20060102", next)
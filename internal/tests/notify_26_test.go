@@ -0,0 +1,411 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/notify"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/tasks"
+	"go_final_project_avp/internal/users"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpStandIn минимальный SMTP-сервер на localhost, запоминающий полученные письма и логины.
+type smtpStandIn struct {
+	addr string
+
+	mu       sync.Mutex
+	messages []string
+	auth     []string
+}
+
+// newSMTPStandIn запускает SMTP-сервер до конца теста.
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось запустить SMTP-сервер: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	s := &smtpStandIn{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// serve диалог SMTP с одним клиентом.
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			creds, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line)[len("AUTH PLAIN "):])
+			s.mu.Lock()
+			s.auth = append(s.auth, strings.ReplaceAll(string(creds), "\x00", ":"))
+			s.mu.Unlock()
+			reply("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// received полученные письма.
+func (s *smtpStandIn) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// jsonStandIn HTTP-сервер, запоминающий принятые запросы; первые fail запросов получают 500.
+type jsonStandIn struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	paths   []string
+	headers []http.Header
+	raw     [][]byte
+	bodies  []map[string]any
+	fail    int
+}
+
+func newJSONStandIn(t *testing.T, fail int, reply string) *jsonStandIn {
+	s := &jsonStandIn{fail: fail}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		_ = json.Unmarshal(raw, &body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.fail > 0 {
+			s.fail--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.paths = append(s.paths, r.URL.Path)
+		s.headers = append(s.headers, r.Header.Clone())
+		s.raw = append(s.raw, raw)
+		s.bodies = append(s.bodies, body)
+		_, _ = w.Write([]byte(reply))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// received тела принятых запросов.
+func (s *jsonStandIn) received() []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]any(nil), s.bodies...)
+}
+
+// requests заголовки и исходные тела принятых запросов.
+func (s *jsonStandIn) requests() ([]http.Header, [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]http.Header(nil), s.headers...), append([][]byte(nil), s.raw...)
+}
+
+func TestReminders(t *testing.T) {
+	t.Parallel()
+	repo, ok := openStore(t, config.Config{DBFile: repository.MemoryDB})
+	if !ok {
+		return
+	}
+	admin, err := repo.GetUserByLogin("admin")
+	assert.NoError(t, err)
+	bobID, err := repo.CreateUser(&users.User{Login: "bob", PasswordHash: "hash"})
+	assert.NoError(t, err)
+	carolID, err := repo.CreateUser(&users.User{Login: "carol", PasswordHash: "hash"})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.SetUserRole(carolID, users.RoleViewer, time.Now()))
+	daveID, err := repo.CreateUser(&users.User{Login: "dave", PasswordHash: "hash"})
+	assert.NoError(t, err)
+
+	app := slogavp.SetupApplication()
+	mail := newSMTPStandIn(t)
+	hook := newJSONStandIn(t, 1, `{}`)
+	bot := newJSONStandIn(t, 0, `{"ok": true}`)
+
+	// Каждый получает напоминания на свои адреса, у Дэйва адресов нет
+	now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local)
+	assert.NoError(t, repo.SetNotifyTargets(admin.Id, &notify.Targets{
+		Email: "admin@example.com", TelegramChatID: "-100500", WebhookURL: hook.URL + "/admin",
+	}, now))
+	assert.NoError(t, repo.SetNotifyTargets(bobID, &notify.Targets{Email: "bob@example.com", TelegramChatID: "42"}, now))
+	assert.NoError(t, repo.SetNotifyTargets(carolID, &notify.Targets{WebhookURL: hook.URL + "/carol"}, now))
+
+	add := func(userID int64, date, title, comment string) string {
+		id, err := repo.CreateTask(userID, &tasks.Task{Date: date, Title: title, Comment: comment})
+		assert.NoError(t, err)
+		return strconv.FormatInt(id, 10)
+	}
+	report := add(admin.Id, "20261017", "Сдать отчёт", "До обеда")
+	call := add(admin.Id, "20261018", "Позвонить в банк", "")
+	add(admin.Id, "20261020", "Через три дня", "")
+	removed := add(admin.Id, "20261017", "Удалённая", "")
+	assert.NoError(t, repo.DeleteTask(admin.Id, removed))
+	add(bobID, "20261017", "Задача Боба", "")
+	carolTask := add(carolID, "20261017", "Задача Кэрол", "")
+	add(daveID, "20261017", "Задача Дэйва", "")
+
+	newWorker := func() *notify.Worker {
+		return &notify.Worker{
+			Store: repo,
+			Notifiers: notify.NewNotifiers(config.Config{
+				SMTPAddr: mail.addr, SMTPUser: "scheduler", SMTPPassword: "secret", SMTPFrom: "scheduler@example.com",
+				TelegramToken: "123:ABC", TelegramAPI: bot.URL,
+				HooksAllowPrivate: true,
+			}),
+			LeadDays: []int{0, 1},
+			At:       9 * time.Hour,
+			App:      app,
+		}
+	}
+	worker := newWorker()
+	assert.Len(t, worker.Notifiers, 3)
+	ctx := context.Background()
+
+	// До времени рассылки ничего не отправляется
+	sent, err := worker.RunOnce(ctx, time.Date(2026, 10, 17, 8, 59, 0, 0, time.Local))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// Почта: отчёт и звонок администратора, задача Боба; веб-хук: задачи администратора и Кэрол,
+	// первый запрос получает ошибку; Telegram: задачи администратора и Боба
+	sent, err = worker.RunOnce(ctx, now)
+	assert.Error(t, err)
+	assert.Equal(t, 8, sent)
+
+	sent, err = worker.RunOnce(ctx, now.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent, "Неотправленное напоминание уходит при следующей проверке")
+
+	// После перезапуска отправленные напоминания не повторяются
+	sent, err = newWorker().RunOnce(ctx, now.Add(10*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// Письмо уходит только владельцу задачи
+	messages := mail.received()
+	if assert.Len(t, messages, 3) {
+		to := map[string][]string{}
+		for _, msg := range messages {
+			for _, rcpt := range []string{"admin@example.com", "bob@example.com"} {
+				if strings.Contains(msg, "To: "+rcpt+"\r\n") {
+					to[rcpt] = append(to[rcpt], msg)
+				}
+			}
+			assert.Contains(t, msg, "Subject: =?utf-8?q?")
+		}
+		admins := strings.Join(to["admin@example.com"], "\n")
+		assert.Len(t, to["admin@example.com"], 2)
+		assert.Contains(t, admins, "Сегодня, 17.10.2026: Сдать отчёт\r\nДо обеда")
+		assert.Contains(t, admins, "Завтра, 18.10.2026: Позвонить в банк")
+		assert.NotContains(t, admins, "Задача Боба")
+		if assert.Len(t, to["bob@example.com"], 1) {
+			assert.Contains(t, to["bob@example.com"][0], "Задача Боба")
+		}
+	}
+	assert.Equal(t, []string{":scheduler:secret", ":scheduler:secret", ":scheduler:secret"}, mail.auth)
+	assert.NotContains(t, strings.Join(messages, "\n"), "Задача Дэйва")
+
+	hooks := hook.received()
+	if assert.Len(t, hooks, 3) {
+		var got []string
+		for i, h := range hooks {
+			got = append(got, hook.paths[i]+" "+h["user"].(string)+" "+h["task_id"].(string)+" "+h["date"].(string))
+		}
+		assert.ElementsMatch(t, []string{
+			"/admin admin " + report + " 20261017",
+			"/admin admin " + call + " 20261018",
+			"/carol carol " + carolTask + " 20261017",
+		}, got)
+	}
+
+	messagesBot := bot.received()
+	if assert.Len(t, messagesBot, 3) {
+		var chats []string
+		for _, msg := range messagesBot {
+			chats = append(chats, msg["chat_id"].(string)+" "+msg["text"].(string))
+		}
+		assert.ElementsMatch(t, []string{
+			"-100500 Сегодня, 17.10.2026: Сдать отчёт\nДо обеда",
+			"-100500 Завтра, 18.10.2026: Позвонить в банк",
+			"42 Сегодня, 17.10.2026: Задача Боба",
+		}, chats)
+		assert.Equal(t, "/bot123:ABC/sendMessage", bot.paths[0])
+	}
+
+	// Задача на новую дату снова получает напоминание
+	task, err := repo.GetTasksId(admin.Id, call)
+	assert.NoError(t, err)
+	task.Date = "20261017"
+	assert.NoError(t, repo.UpdateTask(admin.Id, &task))
+	sent, err = worker.RunOnce(ctx, now.Add(15*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sent)
+
+	// Без адреса канал для пользователя выключен
+	assert.NoError(t, repo.SetNotifyTargets(bobID, &notify.Targets{TelegramChatID: "42"}, now))
+	add(bobID, "20261017", "Вторая задача Боба", "")
+	sent, err = worker.RunOnce(ctx, now.Add(20*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Len(t, mail.received(), 4)
+	assert.Len(t, bot.received(), 5)
+}
+
+func TestNotifyTargetsAPI(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	code, ret := ts.statusOf(t, "api/notify", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code, ret)
+	assert.Equal(t, "", ret["email"])
+	assert.Equal(t, "", ret["webhook_url"])
+
+	for _, body := range []map[string]any{
+		{"email": "не почта"},
+		{"email": "Admin <admin@example.com>"},
+		{"telegram_chat_id": "чат"},
+		{"webhook_url": "ftp://example.com/hook"},
+		{"webhook_url": "http://127.0.0.1/hook"},
+	} {
+		code, ret = ts.statusOf(t, "api/notify", body, http.MethodPut)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.NotEmpty(t, ret["error"], body)
+	}
+
+	targets := map[string]any{"email": "admin@example.com", "telegram_chat_id": "-100500", "webhook_url": "https://93.184.216.34/remind"}
+	code, ret = ts.statusOf(t, "api/notify", targets, http.MethodPut)
+	assert.Equal(t, http.StatusOK, code, ret)
+	assert.NotEmpty(t, ret["updated_at"])
+	code, ret = ts.statusOf(t, "api/notify", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code, ret)
+	for key, value := range targets {
+		assert.Equal(t, value, ret[key], key)
+	}
+
+	// Читатель тоже получает напоминания о своих задачах, адреса у каждого свои
+	ts.registerUser(t, "carol", "carol-password")
+	carol := ts.promote(t, "carol", "carol-password", "viewer")
+	ret, err := ts.postJSONAs(carol, "api/notify", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "", ret["email"])
+	ret, err = ts.postJSONAs(carol, "api/notify", map[string]any{"telegram_chat_id": "@carol_channel"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, "@carol_channel", ret["telegram_chat_id"])
+	code, ret = ts.statusOf(t, "api/notify", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code, ret)
+	assert.Equal(t, "-100500", ret["telegram_chat_id"])
+}
+
+func TestTelegramError(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"ok": false, "description": "Bad Request: chat not found"}`))
+	}))
+	defer srv.Close()
+
+	bot := &notify.Telegram{Token: "123:SECRET", APIURL: srv.URL}
+	reminder := notify.Reminder{Task: tasks.Task{Date: "20261017", Title: "Задача"}, Target: "1"}
+	err := bot.Send(context.Background(), reminder)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "chat not found")
+		assert.NotContains(t, err.Error(), "SECRET")
+	}
+
+	srv.Close()
+	err = bot.Send(context.Background(), reminder)
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "SECRET")
+	}
+}
+
+func TestReminderSettings(t *testing.T) {
+	t.Parallel()
+
+	days, err := notify.ParseLeadDays(" 1, 0,7,1 ")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 7}, days)
+	for _, s := range []string{"", "завтра", "-1", "400"} {
+		_, err = notify.ParseLeadDays(s)
+		assert.Error(t, err, s)
+	}
+
+	at, err := notify.ParseTimeOfDay("09:30")
+	assert.NoError(t, err)
+	assert.Equal(t, 9*time.Hour+30*time.Minute, at)
+	_, err = notify.ParseTimeOfDay("9 утра")
+	assert.Error(t, err)
+
+	reminder := notify.Reminder{Task: tasks.Task{Date: "20261020", Title: "Отпуск"}, LeadDays: 3}
+	assert.Equal(t, "Через 3 дн., 20.10.2026: Отпуск", reminder.Text())
+	assert.Equal(t, "Напоминание: Отпуск", reminder.Subject())
+
+	var names []string
+	for _, n := range notify.NewNotifiers(config.Config{}) {
+		names = append(names, n.Name())
+	}
+	assert.Equal(t, []string{notify.ChannelWebhook}, names, "Почта и Telegram без сервера и бота не включаются")
+
+	assert.NoError(t, notify.Targets{Email: "bob@example.com", TelegramChatID: "-100500"}.Validate())
+	assert.NoError(t, notify.Targets{}.Validate())
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/tasks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type occurrences struct {
+	start  string
+	repeat string
+	from   string
+	to     string
+	want   string
+}
+
+func TestOccurrences(t *testing.T) {
+	tbl := []occurrences{
+		{"20240126", "", "20240101", "20240131", "20240126"},
+		{"20240126", "", "20240201", "20240229", ""},
+		{"20240110", "d 7", "20240115", "20240207", "20240117,20240124,20240131,20240207"},
+		{"20200101", "d 30", "20240101", "20240201", "20240110"},
+		{"20240229", "y", "20240101", "20281231", "20240229,20250301,20260301,20270301,20280301"},
+		{"20240126", "w 1,5", "20240126", "20240209", "20240126,20240129,20240202,20240205,20240209"},
+		{"20240101", "w 7", "20240110", "20240121", "20240114,20240121"},
+		{"20240115", "m -1", "20240101", "20240430", "20240115,20240131,20240229,20240331,20240430"},
+		{"20240130", "m 31", "20240201", "20240831", "20240331,20240531,20240731,20240831"},
+		{"20240101", "m -2,15 2,3", "20240101", "20250301", "20240101,20240215,20240228,20240315,20240330,20250215,20250227"},
+	}
+	for _, v := range tbl {
+		start, _ := time.Parse(tasks.TimeFormat, v.start)
+		from, _ := time.Parse(tasks.TimeFormat, v.from)
+		to, _ := time.Parse(tasks.TimeFormat, v.to)
+
+		rule, err := tasks.Parse(v.repeat)
+		assert.NoError(t, err)
+
+		dates, err := tasks.Occurrences(start, rule, from, to)
+		assert.NoError(t, err)
+
+		got := make([]string, 0, len(dates))
+		for _, d := range dates {
+			got = append(got, d.Format(tasks.TimeFormat))
+		}
+		assert.Equal(t, v.want, strings.Join(got, ","), `%v`, v)
+	}
+
+	_, err := tasks.Occurrences(time.Now(), tasks.YearRule{}, time.Now(), time.Now().AddDate(0, 0, -1))
+	assert.Error(t, err, "Ожидается ошибка для интервала с концом раньше начала")
+}
+
+func TestOccurrencesAPI(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	now := time.Now()
+	id := ts.addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Планёрка",
+		repeat: "d 2",
+	})
+
+	body, err := ts.requestJSON("api/occurrences?id="+id+"&from="+now.Format(`20060102`)+
+		"&to="+now.AddDate(0, 0, 5).Format(`20060102`), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Dates     []string `json:"dates"`
+		Truncated bool     `json:"truncated"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, []string{
+		now.Format(`20060102`),
+		now.AddDate(0, 0, 2).Format(`20060102`),
+		now.AddDate(0, 0, 4).Format(`20060102`),
+	}, m.Dates)
+
+	assert.False(t, m.Truncated)
+
+	// Ежедневная задача на длинном интервале: MaxOccurrences дат и признак обрезанного списка
+	daily := ts.addTask(t, task{date: now.Format(`20060102`), title: "Зарядка", repeat: "d 1"})
+	for _, c := range []struct {
+		days      int
+		truncated bool
+	}{
+		{tasks.MaxOccurrences - 1, false},
+		{tasks.MaxOccurrences, true},
+		{10 * tasks.MaxOccurrences, true},
+	} {
+		m.Dates, m.Truncated = nil, false
+		body, err = ts.requestJSON("api/occurrences?id="+daily+"&from="+now.Format(`20060102`)+
+			"&to="+now.AddDate(0, 0, c.days).Format(`20060102`), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Len(t, m.Dates, min(c.days+1, tasks.MaxOccurrences), c.days)
+		assert.Equal(t, c.truncated, m.Truncated, c.days)
+	}
+
+	ret, err := ts.postJSON("api/occurrences?id="+id+"&from=20240201&to=20240101", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
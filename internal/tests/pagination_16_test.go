@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getPage страница /api/tasks и курсор следующей страницы.
+func (ts *testServer) getPage(t *testing.T, query url.Values) ([]string, string) {
+	ret, err := ts.postJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"], "Неожиданная ошибка для %v", query)
+
+	var ids []string
+	list, _ := ret["tasks"].([]any)
+	for _, v := range list {
+		task, _ := v.(map[string]any)
+		ids = append(ids, fmt.Sprint(task["id"]))
+	}
+	next, _ := ret["next_cursor"].(string)
+	return ids, next
+}
+
+func TestCursor(t *testing.T) {
+	c := repository.Cursor{Date: "20240131", Id: 42}
+	parsed, err := repository.ParseCursor(c.String())
+	assert.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	parsed, err = repository.ParseCursor("")
+	assert.NoError(t, err)
+	assert.True(t, parsed.IsZero())
+
+	for _, bad := range []string{"!!!", "MjAyNDAxMzE", "eHl6OjE", "MjAyNDAxMzE6eA", "MjAyNDAxMzE6MA"} {
+		_, err = repository.ParseCursor(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	// Больше DefaultLimit задач, многие с одинаковой датой
+	now := time.Now()
+	total := repository.DefaultLimit + 23
+	want := make(map[string]bool)
+	for i := 0; i < total; i++ {
+		id := ts.addTask(t, task{
+			date:  now.AddDate(0, 0, i/10).Format(`20060102`),
+			title: fmt.Sprintf("Задача %d", i),
+		})
+		want[id] = true
+	}
+
+	ids, next := ts.getPage(t, url.Values{})
+	assert.Len(t, ids, repository.DefaultLimit)
+	assert.NotEmpty(t, next)
+
+	// Обход страницами по 7 задач возвращает каждую задачу ровно один раз
+	seen := make(map[string]bool)
+	query := url.Values{"limit": {"7"}}
+	for pages := 0; pages < total; pages++ {
+		ids, next = ts.getPage(t, query)
+		assert.LessOrEqual(t, len(ids), 7)
+		for _, id := range ids {
+			assert.False(t, seen[id], "Задача %s на двух страницах", id)
+			seen[id] = true
+		}
+		if next == "" {
+			break
+		}
+		query.Set("cursor", next)
+	}
+	assert.Equal(t, want, seen)
+
+	// Поиск тоже листается курсором
+	ids, next = ts.getPage(t, url.Values{"search": {"Задача 1"}, "limit": {"5"}})
+	assert.Len(t, ids, 5)
+	rest, last := ts.getPage(t, url.Values{"search": {"Задача 1"}, "limit": {"5"}, "cursor": {next}})
+	assert.Len(t, rest, 5)
+	assert.NotEmpty(t, last)
+	assert.NotContains(t, rest, ids[0])
+
+	for _, bad := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"abc"}},
+		{"limit": {fmt.Sprint(repository.MaxLimit + 1)}},
+		{"cursor": {"bad-cursor"}},
+	} {
+		ret, err := ts.postJSON("api/tasks?"+bad.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для %v", bad)
+	}
+}
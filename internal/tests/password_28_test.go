@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/users"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenClaims незашифрованная часть JWT-токена.
+func tokenClaims(t *testing.T, token string) string {
+	parts := strings.Split(token, ".")
+	if !assert.Len(t, parts, 3) {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+	return string(payload)
+}
+
+// signIn вход с паролем, возвращает токен или пустую строку.
+func (ts *testServer) signIn(t *testing.T, login, password string) string {
+	ret, err := ts.postJSON("api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	token, _ := ret["token"].(string)
+	return token
+}
+
+// authStatus код ответа защищённого маршрута с токеном token.
+func (ts *testServer) authStatus(t *testing.T, token string) (int, string) {
+	saved := ts.token
+	ts.token = token
+	defer func() { ts.token = saved }()
+
+	code, ret := ts.statusOf(t, "api/tasks", nil, http.MethodGet)
+	msg, _ := ret["error"].(string)
+	return code, msg
+}
+
+func TestPasswordHasher(t *testing.T) {
+	t.Parallel()
+
+	for _, hasher := range []users.Hasher{{}, {Algorithm: users.AlgoBcrypt, BcryptCost: 4}, {Algorithm: users.AlgoArgon2id}} {
+		hash, err := hasher.Hash("пароль-123")
+		if !assert.NoError(t, err, hasher) {
+			continue
+		}
+		assert.True(t, users.IsHash(hash), hash)
+		assert.True(t, users.CheckPassword(hash, "пароль-123"), hash)
+		assert.False(t, users.CheckPassword(hash, "пароль-124"), hash)
+
+		again, err := hasher.Hash("пароль-123")
+		assert.NoError(t, err)
+		assert.NotEqual(t, hash, again, "У каждого хэша своя соль")
+	}
+
+	argon, err := users.Hasher{Algorithm: users.AlgoArgon2id}.Hash("secret")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(argon, "$argon2id$v=19$m=19456,t=2,p=1$"), argon)
+	for _, broken := range []string{
+		argon[:len(argon)-4],
+		strings.Replace(argon, "v=19", "v=16", 1),
+		strings.Replace(argon, "m=19456", "m=99999999", 1),
+		"$argon2id$v=19$m=19456,t=2,p=1$",
+		"secret",
+	} {
+		assert.False(t, users.CheckPassword(broken, "secret"), broken)
+	}
+
+	for _, hasher := range []users.Hasher{{Algorithm: "md5"}, {Algorithm: users.AlgoBcrypt, BcryptCost: 99}} {
+		assert.Error(t, hasher.Validate(), hasher)
+		_, err = hasher.Hash("secret")
+		assert.Error(t, err, hasher)
+	}
+
+	// TODO_PASSWORD: хэш или пароль открытым текстом
+	assert.True(t, users.CheckConfigPassword("12345", "12345"))
+	assert.False(t, users.CheckConfigPassword("12345", "123456"))
+	assert.False(t, users.CheckConfigPassword("", ""))
+	assert.True(t, users.CheckConfigPassword(argon, "secret"))
+	assert.False(t, users.CheckConfigPassword(argon, argon), "Хэш не подходит вместо пароля")
+}
+
+func TestAdminPasswordHash(t *testing.T) {
+	t.Parallel()
+	hash, err := users.Hasher{Algorithm: users.AlgoArgon2id}.Hash("admin-secret")
+	assert.NoError(t, err)
+	ts := newTestServer(t)
+
+	// Токен не раскрывает пароль: в нём только id и необратимая метка
+	claims := tokenClaims(t, ts.token)
+	assert.Contains(t, claims, `"cred":"`)
+	assert.NotContains(t, claims, "password_hash")
+	// Числа iat и exp могут случайно содержать цифры пароля, проверяем только строковые значения
+	var values map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(claims), &values))
+	for key, v := range values {
+		if s, ok := v.(string); ok {
+			assert.NotContains(t, s, testPassword, key)
+		}
+	}
+
+	hashed := newTestServerWith(t, func(cfg *config.Config) {
+		cfg.Password = hash
+	})
+	assert.Empty(t, hashed.signIn(t, "", testPassword))
+	assert.Empty(t, hashed.signIn(t, "", hash), "Хэш из настроек не подходит вместо пароля")
+	token := hashed.signIn(t, "", "admin-secret")
+	assert.NotEmpty(t, token)
+	code, _ := hashed.authStatus(t, token)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestCredentialVersion(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	// Тот же секрет подписи и пользователь, но другой TODO_PASSWORD: старый токен не подходит
+	same := newTestServer(t)
+	code, _ := same.authStatus(t, ts.token)
+	assert.Equal(t, http.StatusOK, code, "Токен действует, пока не сменился пароль")
+	changed := newTestServerWith(t, func(cfg *config.Config) {
+		cfg.Password = "новый пароль"
+	})
+	code, msg := changed.authStatus(t, ts.token)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Пароль изменён, войдите заново", msg)
+
+	// Смена пароля пользователя из БД тоже отзывает его токены
+	bob := ts.registerUser(t, "bob", "bob-password")
+	code, _ = ts.authStatus(t, bob)
+	assert.Equal(t, http.StatusOK, code)
+	hash, err := users.Hasher{BcryptCost: 4}.Hash("bob-new-password")
+	assert.NoError(t, err)
+	_, err = ts.db.Exec(`UPDATE users SET password_hash = ? WHERE login = 'bob'`, hash)
+	assert.NoError(t, err)
+	code, _ = ts.authStatus(t, bob)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Empty(t, ts.signIn(t, "bob", "bob-password"))
+	bob = ts.signIn(t, "bob", "bob-new-password")
+	code, _ = ts.authStatus(t, bob)
+	assert.Equal(t, http.StatusOK, code)
+
+	// Токены прежнего формата с паролем в hex не принимаются
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":       1,
+		"password_hash": "3132333435",
+		"exp":           time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("test_secret_key"))
+	assert.NoError(t, err)
+	code, _ = ts.authStatus(t, legacy)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestRegisterArgon2id(t *testing.T) {
+	t.Parallel()
+	ts := newTestServerWith(t, func(cfg *config.Config) {
+		cfg.PasswordAlgo = users.AlgoArgon2id
+	})
+
+	token := ts.registerUser(t, "alice", "alice-password")
+	var hash string
+	assert.NoError(t, ts.db.Get(&hash, `SELECT password_hash FROM users WHERE login = 'alice'`))
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"), hash)
+	code, _ := ts.authStatus(t, token)
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/search"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parseQuery разбирает строку поиска, ошибка разбора проваливает тест.
+func parseQuery(t *testing.T, s string) search.Query {
+	t.Helper()
+	q, err := search.Parse(s)
+	if err != nil {
+		t.Fatalf("Ошибка разбора поиска %q: %v", s, err)
+	}
+	return q
+}
+
+func TestParseQuery(t *testing.T) {
+	t.Parallel()
+	tbl := []struct {
+		in   string
+		want []search.Term
+	}{
+		{"", nil},
+		{"  -  \"\" ", nil},
+		{"молоко", []search.Term{{Op: search.OpEq, Value: "молоко"}}},
+		{`"Позвонить маме" -хлеб`, []search.Term{
+			{Op: search.OpEq, Value: "Позвонить маме", Phrase: true},
+			{Op: search.OpEq, Value: "хлеб", Negate: true},
+		}},
+		{`date:>=20261001 date:<01.11.2026 DATE:20261015`, []search.Term{
+			{Field: search.FieldDate, Op: search.OpGe, Value: "20261001"},
+			{Field: search.FieldDate, Op: search.OpLt, Value: "20261101"},
+			{Field: search.FieldDate, Op: search.OpEq, Value: "20261015"},
+		}},
+		{`repeat:W repeat:"d  7" -repeat:"m 1,-1"`, []search.Term{
+			{Field: search.FieldRepeat, Op: search.OpEq, Value: "w"},
+			{Field: search.FieldRepeat, Op: search.OpEq, Value: "d 7", Phrase: true},
+			{Field: search.FieldRepeat, Op: search.OpEq, Value: "m 1,-1", Phrase: true, Negate: true},
+		}},
+		{`title:"отчёт" -comment:draft has:repeat -has:Comment`, []search.Term{
+			{Field: search.FieldTitle, Op: search.OpEq, Value: "отчёт", Phrase: true},
+			{Field: search.FieldComment, Op: search.OpEq, Value: "draft", Negate: true},
+			{Field: search.FieldHas, Op: search.OpEq, Value: "repeat"},
+			{Field: search.FieldHas, Op: search.OpEq, Value: "comment", Negate: true},
+		}},
+		// Отдельная дата — условие на дату, неизвестные поля остаются словами
+		{`15.10.2026 встреча:18:00`, []search.Term{
+			{Field: search.FieldDate, Op: search.OpEq, Value: "20261015"},
+			{Op: search.OpEq, Value: "встреча:18:00"},
+		}},
+	}
+	for _, v := range tbl {
+		q, err := search.Parse(v.in)
+		assert.NoError(t, err, v.in)
+		assert.Equal(t, v.want, q.Terms, v.in)
+	}
+
+	tblErr := []struct {
+		in    string
+		pos   int
+		token string
+	}{
+		{"date:2026", 1, "date:2026"},
+		{"отчёт date:>>20261001", 7, "date:>>20261001"},
+		{"repeat:x", 1, "repeat:x"},
+		{`-repeat:"d 500"`, 1, `-repeat:"d 500"`},
+		{"has:title", 1, "has:title"},
+		{"молоко title:", 8, "title:"},
+	}
+	for _, v := range tblErr {
+		_, err := search.Parse(v.in)
+		var perr *search.Error
+		if assert.True(t, errors.As(err, &perr), "Ожидается ошибка для %q", v.in) {
+			assert.Equal(t, v.pos, perr.Pos, v.in)
+			assert.Equal(t, v.token, perr.Token, v.in)
+			assert.Equal(t, v.in, perr.Query)
+		}
+	}
+}
+
+func TestQueryFilter(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	base := time.Now().AddDate(0, 1, 0)
+	day := func(days int) string { return base.AddDate(0, 0, days).Format(`20060102`) }
+
+	ts.addTask(t, task{date: day(0), title: "Отчёт за месяц", comment: "draft"})
+	ts.addTask(t, task{date: day(5), title: "Отчёт по проекту", comment: "Отправить клиенту"})
+	ts.addTask(t, task{date: day(10), title: "Планёрка", repeat: "w 1"})
+	ts.addTask(t, task{date: day(20), title: "Полив цветов", repeat: "d 3"})
+	ts.addTask(t, task{date: day(40), title: "Квартальный отчёт", comment: "draft"})
+
+	tbl := []struct {
+		query string
+		want  []string
+	}{
+		{"date:>=" + day(5) + " date:<" + day(20), []string{"Отчёт по проекту", "Планёрка"}},
+		{"date:" + day(0), []string{"Отчёт за месяц"}},
+		{base.Format(`02.01.2006`), []string{"Отчёт за месяц"}},
+		{"repeat:w", []string{"Планёрка"}},
+		{`repeat:"d 3"`, []string{"Полив цветов"}},
+		{"has:repeat", []string{"Планёрка", "Полив цветов"}},
+		{"-has:repeat -has:comment", nil},
+		{"has:comment -comment:draft", []string{"Отчёт по проекту"}},
+		{`title:"Отчёт" -comment:draft`, []string{"Отчёт по проекту"}},
+		{"date:<" + day(30) + " -comment:draft -repeat:d", []string{"Отчёт по проекту", "Планёрка"}},
+		{"Отчёт date:>" + day(0) + " date:<" + day(30), []string{"Отчёт по проекту"}},
+	}
+	for _, v := range tbl {
+		titles := ts.searchTitles(t, v.query)
+		assert.ElementsMatch(t, v.want, titles, v.query)
+	}
+
+	// Ошибка разбора возвращается клиенту с позицией условия
+	_, err := search.Parse("отчёт repeat:x")
+	ret, errPost := ts.postJSON("api/tasks?search=отчёт+repeat:x", nil, http.MethodGet)
+	assert.NoError(t, errPost)
+	if assert.Error(t, err) {
+		assert.Equal(t, err.Error(), ret["error"])
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// userID id пользователя login из списка администратора.
+func (ts *testServer) userID(t *testing.T, login string) string {
+	code, ret := ts.statusOf(t, "api/admin/users", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code, ret)
+	list, _ := ret["users"].([]any)
+	for _, v := range list {
+		if u := v.(map[string]any); u["login"] == login {
+			return u["id"].(string)
+		}
+	}
+	t.Fatalf("Пользователь %s не найден: %v", login, ret)
+	return ""
+}
+
+// promote администратор меняет роль пользователя, тот входит заново, возвращает его новый токен.
+func (ts *testServer) promote(t *testing.T, login, password, role string) string {
+	code, ret := ts.statusOf(t, "api/admin/users", map[string]any{"id": ts.userID(t, login), "role": role}, http.MethodPut)
+	assert.Equal(t, http.StatusOK, code, ret)
+	assert.Equal(t, role, ret["role"])
+	return ts.signIn(t, login, password)
+}
+
+func TestRoles(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+	today := time.Now().Format(`20060102`)
+
+	assert.Contains(t, tokenClaims(t, ts.token), `"role":"admin"`)
+	ret, err := ts.postJSON("api/signin", map[string]any{"password": testPassword}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", ret["role"])
+
+	// Новый пользователь — editor: меняет свои задачи, но не управляет сервером
+	bob := ts.registerUser(t, "bob", "bob-password")
+	assert.Contains(t, tokenClaims(t, bob), `"role":"editor"`)
+	ret, err = ts.postJSONAs(bob, "api/task", map[string]any{"date": today, "title": "Задача Боба"}, http.MethodPost)
+	assert.NoError(t, err)
+	id, _ := ret["id"].(string)
+	assert.NotEmpty(t, id, ret)
+	for _, path := range []string{"api/admin/users", "api/admin/backups", "api/webhooks"} {
+		ret, err = ts.postJSONAs(bob, path, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, "Доступно только администратору", ret["error"], path)
+	}
+
+	code, ret := ts.statusOf(t, "api/admin/users", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	if list, _ := ret["users"].([]any); assert.Len(t, list, 2) {
+		assert.Equal(t, "admin", list[0].(map[string]any)["role"])
+		assert.Equal(t, "editor", list[1].(map[string]any)["role"])
+		assert.NotContains(t, list[1], "password_hash")
+	}
+
+	for _, body := range []map[string]any{
+		{"id": ts.userID(t, "bob"), "role": "owner"},
+		{"id": "abc", "role": "viewer"},
+		{"id": ts.userID(t, "admin"), "role": "viewer"},
+	} {
+		code, ret = ts.statusOf(t, "api/admin/users", body, http.MethodPut)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.NotEmpty(t, ret["error"])
+	}
+	code, _ = ts.statusOf(t, "api/admin/users", map[string]any{"id": "999", "role": "viewer"}, http.MethodPut)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// Смена роли завершает сессии: старый токен с прежней ролью не подходит
+	viewer := ts.promote(t, "bob", "bob-password", "viewer")
+	code, msg := ts.authStatus(t, bob)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Роль изменена, войдите заново", msg)
+
+	// viewer только читает задачи
+	ret, err = ts.postJSONAs(viewer, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Len(t, ret["tasks"], 1)
+	ret, err = ts.postJSONAs(viewer, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "Задача Боба", ret["title"])
+	for _, req := range []struct {
+		method, path string
+		body         map[string]any
+	}{
+		{http.MethodPost, "api/task", map[string]any{"date": today, "title": "Ещё задача"}},
+		{http.MethodPut, "api/task", map[string]any{"id": id, "date": today, "title": "Другое название"}},
+		{http.MethodPost, "api/task/done?id=" + id, nil},
+		{http.MethodDelete, "api/task?id=" + id, nil},
+		{http.MethodPost, "api/task/restore?id=" + id, nil},
+		{http.MethodPost, "api/tasks/batch", map[string]any{"ops": []map[string]any{{"op": "delete", "id": id}}}},
+		{http.MethodGet, "api/task/history?id=" + id, nil},
+		{http.MethodGet, "api/trash", nil},
+		{http.MethodGet, "api/occurrences?from=" + today + "&to=" + today, nil},
+		{http.MethodGet, "api/export", nil},
+	} {
+		code, ret = ts.withAuthorization(t, req.method, req.path, req.body, "Bearer "+viewer)
+		assert.Equal(t, http.StatusForbidden, code, req.path)
+		assert.Equal(t, "Недостаточно прав, нужна роль editor", ret["error"], req.path)
+	}
+
+	// API-ключ не даёт больше прав, чем роль его владельца
+	ret, err = ts.postJSONAs(viewer, "api/keys", map[string]any{"name": "cron", "scopes": []string{"tasks:read", "tasks:write"}}, http.MethodPost)
+	assert.NoError(t, err)
+	key, _ := ret["key"].(string)
+	code, _ = ts.withAuthorization(t, http.MethodGet, "api/tasks", nil, "Bearer "+key)
+	assert.Equal(t, http.StatusOK, code)
+	code, ret = ts.withAuthorization(t, http.MethodPost, "api/task", map[string]any{"date": today, "title": "Из скрипта"}, "Bearer "+key)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, "Недостаточно прав, нужна роль editor", ret["error"])
+
+	// После повышения до editor тот же ключ может менять задачи
+	ts.promote(t, "bob", "bob-password", "editor")
+	code, ret = ts.withAuthorization(t, http.MethodPost, "api/task", map[string]any{"date": today, "title": "Из скрипта"}, "Bearer "+key)
+	assert.Equal(t, http.StatusOK, code, ret)
+	assert.Empty(t, ts.getTasks(t, ""), "Задачи Боба не видны администратору")
+}
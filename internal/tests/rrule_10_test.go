@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/tasks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRRule(t *testing.T) {
+	start := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+	tbl := []struct {
+		repeat string
+		rrule  string
+	}{
+		{"d 1", "RRULE:FREQ=DAILY"},
+		{"d 7", "RRULE:FREQ=DAILY;INTERVAL=7"},
+		{"y", "RRULE:FREQ=YEARLY"},
+		{"w 1,3,5", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{"m -1", "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{"m 10,17 12,8,1", "RRULE:FREQ=MONTHLY;BYMONTH=12,8,1;BYMONTHDAY=10,17"},
+	}
+	for _, v := range tbl {
+		rule, err := tasks.Parse(v.repeat)
+		assert.NoError(t, err)
+
+		rrule, err := tasks.FormatRRule(rule, start)
+		assert.NoError(t, err)
+		assert.Equal(t, v.rrule, rrule, v.repeat)
+
+		back, err := tasks.ParseRRule(rrule, start)
+		assert.NoError(t, err)
+		assert.Equal(t, v.repeat, back.String(), v.rrule)
+	}
+
+	imports := []struct {
+		rrule  string
+		repeat string
+	}{
+		{"FREQ=WEEKLY", "w 5"},
+		{"rrule:freq=weekly;byday=su;wkst=mo", "w 7"},
+		{"FREQ=MONTHLY", "m 26"},
+		{"FREQ=YEARLY;BYMONTH=3", "m 26 3"},
+		{"FREQ=YEARLY;BYMONTH=5,6;BYMONTHDAY=7,19", "m 7,19 5,6"},
+	}
+	for _, v := range imports {
+		rule, err := tasks.ParseRRule(v.rrule, start)
+		assert.NoError(t, err)
+		assert.Equal(t, v.repeat, rule.String(), v.rrule)
+	}
+
+	rejects := []string{
+		"",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=401",
+		"FREQ=DAILY;COUNT=5",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYMONTHDAY=-3",
+		"FREQ=MONTHLY;BYDAY=MO;BYSETPOS=1",
+		"FREQ=MONTHLY;BYMONTH=2;BYMONTHDAY=30",
+		"FREQ",
+	}
+	for _, rrule := range rejects {
+		_, err := tasks.ParseRRule(rrule, start)
+		var ruleErr *tasks.RuleError
+		assert.True(t, errors.As(err, &ruleErr), "Ожидается RuleError для %q", rrule)
+	}
+
+	// Предпоследний день месяца не переводится ни в одну сторону
+	for _, repeat := range []string{"m -2", "m 1,-2", "m -2 3"} {
+		rule, err := tasks.Parse(repeat)
+		assert.NoError(t, err)
+		_, err = tasks.FormatRRule(rule, start)
+		var ruleErr *tasks.RuleError
+		if assert.True(t, errors.As(err, &ruleErr), repeat) {
+			assert.Equal(t, "-2", ruleErr.Token)
+			assert.Contains(t, ruleErr.Error(), "предпоследний день месяца -2 не имеет точного эквивалента")
+		}
+	}
+	for _, rrule := range []string{"FREQ=MONTHLY;BYMONTHDAY=-2", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=1,-2"} {
+		_, err := tasks.ParseRRule(rrule, start)
+		var ruleErr *tasks.RuleError
+		if assert.True(t, errors.As(err, &ruleErr), rrule) {
+			assert.Equal(t, "BYMONTHDAY=-2", ruleErr.Token)
+			assert.Contains(t, ruleErr.Error(), "предпоследний день месяца -2 не имеет точного эквивалента")
+		}
+	}
+
+	leap := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	_, err := tasks.FormatRRule(tasks.YearRule{}, leap)
+	assert.Error(t, err)
+	_, err = tasks.ParseRRule("FREQ=YEARLY", leap)
+	assert.Error(t, err)
+}
+
+func TestRRuleAPI(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+
+	ret, err := ts.postJSON("api/task?repeat_format=rrule", map[string]any{
+		"date":   date,
+		"title":  "Стендап",
+		"repeat": "RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id, ok := ret["id"].(string)
+	if !assert.True(t, ok, "Не возвращён id: %v", ret) {
+		return
+	}
+
+	body, err := ts.requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "w 1,2,3,4,5", m["repeat"])
+
+	body, err = ts.requestJSON("api/task?repeat_format=rrule&id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", m["repeat"])
+
+	ret, err = ts.postJSON("api/task?repeat_format=rrule", map[string]any{
+		"id":     id,
+		"date":   date,
+		"title":  "Стендап",
+		"repeat": "RRULE:FREQ=DAILY;COUNT=3",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = ts.postJSON("api/task?repeat_format="+url.QueryEscape("ical"), map[string]any{
+		"date":   date,
+		"title":  "Стендап",
+		"repeat": "d 1",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
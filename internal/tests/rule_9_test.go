@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"go_final_project_avp/internal/tasks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRule(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		want   tasks.Rule
+		str    string
+	}{
+		{"d 7", tasks.DayRule{Days: 7}, "d 7"},
+		{"y", tasks.YearRule{}, "y"},
+		{"w 1,3,5", tasks.WeekRule{Weekdays: []int{1, 3, 5}}, "w 1,3,5"},
+		{"m -1", tasks.MonthRule{Days: []int{-1}}, "m -1"},
+		{"m 07,19 05,6", tasks.MonthRule{Days: []int{7, 19}, Months: []int{5, 6}}, "m 7,19 5,6"},
+	}
+	for _, v := range tbl {
+		rule, err := tasks.Parse(v.repeat)
+		assert.NoError(t, err)
+		assert.Equal(t, v.want, rule, v.repeat)
+		assert.Equal(t, v.str, rule.String(), v.repeat)
+
+		again, err := tasks.Parse(rule.String())
+		assert.NoError(t, err)
+		assert.Equal(t, rule, again, v.repeat)
+	}
+
+	rule, err := tasks.Parse("")
+	assert.NoError(t, err)
+	assert.Nil(t, rule)
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		token  string
+	}{
+		{"k 34", "k"},
+		{"d", ""},
+		{"d x", "x"},
+		{"d 401", "401"},
+		{"d 7 8", "8"},
+		{"y 1", "1"},
+		{"w", ""},
+		{"w 1,8", "8"},
+		{"m 0", "0"},
+		{"m -2,-3", "-3"},
+		{"m 1 13", "13"},
+		{"m 31 2", ""},
+		{"m 30,31 2", ""},
+	}
+	for _, v := range tbl {
+		_, err := tasks.Parse(v.repeat)
+		var ruleErr *tasks.RuleError
+		if !assert.True(t, errors.As(err, &ruleErr), "Ожидается RuleError для правила %q", v.repeat) {
+			continue
+		}
+		assert.Equal(t, v.repeat, ruleErr.Rule)
+		assert.Equal(t, v.token, ruleErr.Token, v.repeat)
+		assert.NotEmpty(t, ruleErr.Reason, v.repeat)
+	}
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/tasks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// searchTitles заголовки задач, найденных строкой search, в порядке выдачи.
+func (ts *testServer) searchTitles(t *testing.T, search string) []string {
+	var titles []string
+	for _, v := range ts.getTasks(t, url.QueryEscape(search)) {
+		titles = append(titles, v["title"])
+	}
+	return titles
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	ts.addTask(t, task{title: "Купить молоко", comment: "Два литра"})
+	ts.addTask(t, task{title: "Молочный коктейль"})
+	id := ts.addTask(t, task{title: "Позвонить маме", comment: "Спросить, что купить к ужину"})
+	ts.addTask(t, task{title: "Отчёт по продажам", comment: "Отправить до пятницы"})
+
+	// Слова ищутся по началу, фраза — целиком, минус исключает
+	assert.ElementsMatch(t, []string{"Купить молоко"}, ts.searchTitles(t, "молок"))
+	assert.ElementsMatch(t, []string{"Позвонить маме"}, ts.searchTitles(t, `"Позвонить маме"`))
+	assert.Empty(t, ts.searchTitles(t, `"маме Позвонить"`))
+	assert.ElementsMatch(t, []string{"Позвонить маме"}, ts.searchTitles(t, "купить -молоко"))
+	assert.ElementsMatch(t, []string{"Отчёт по продажам"}, ts.searchTitles(t, `продажам -"Купить молоко"`))
+
+	// Только минус исключает задачи из полного списка
+	assert.ElementsMatch(t, []string{"Молочный коктейль", "Позвонить маме", "Отчёт по продажам"}, ts.searchTitles(t, "-молоко"))
+
+	// Символы синтаксиса FTS5 в строке поиска не ломают запрос
+	for _, q := range []string{`молоко*`, `NEAR(молоко)`, `молоко "`, `title:молоко`, `(молоко`, `tag:молоко`} {
+		ret, err := ts.postJSON("api/tasks?search="+url.QueryEscape(q), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Nil(t, ret["error"], "Неожиданная ошибка для %s", q)
+	}
+
+	// Индекс следует за изменением и удалением задачи
+	ret, err := ts.postJSON("api/task", map[string]any{
+		"id":    id,
+		"date":  time.Now().Format(`20060102`),
+		"title": "Написать бабушке",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, ts.searchTitles(t, "позвонить"))
+	assert.ElementsMatch(t, []string{"Написать бабушке"}, ts.searchTitles(t, "бабушк"))
+
+	ret, err = ts.postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, ts.searchTitles(t, "бабушк"))
+
+	// Регистр кириллицы не важен, границы слов учитываются
+	assert.ElementsMatch(t, []string{"Купить молоко"}, ts.searchTitles(t, "купить"))
+	assert.ElementsMatch(t, []string{"Купить молоко"}, ts.searchTitles(t, "МОЛОКО"))
+	assert.ElementsMatch(t, []string{"Молочный коктейль"}, ts.searchTitles(t, "ко"))
+
+	// Совпадение в заголовке выше совпадения в комментарии
+	ts.addTask(t, task{title: "Список дел", comment: "Купить хлеб"})
+	titles := ts.searchTitles(t, "купить")
+	if assert.Len(t, titles, 2) {
+		assert.Equal(t, "Купить молоко", titles[0])
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	want := make(map[string]bool)
+	for _, title := range []string{"Отчёт", "Отчёт за январь", "Годовой отчёт", "Отчёт отчёт", "Сдать отчёт", "Отчёт по налогам", "Квартальный отчёт"} {
+		want[ts.addTask(t, task{title: title, comment: "отчётность"})] = true
+	}
+	ts.addTask(t, task{title: "Другая задача"})
+
+	seen := make(map[string]bool)
+	query := url.Values{"search": {"отчёт"}, "limit": {"2"}}
+	for pages := 0; pages < len(want)+1; pages++ {
+		ids, next := ts.getPage(t, query)
+		for _, id := range ids {
+			assert.False(t, seen[id], "Задача %s на двух страницах", id)
+			seen[id] = true
+		}
+		if next == "" {
+			break
+		}
+		query.Set("cursor", next)
+	}
+	assert.Equal(t, want, seen)
+
+	// Курсор поиска не подходит к обычному списку и наоборот
+	ids, next := ts.getPage(t, url.Values{"search": {"отчёт"}, "limit": {"1"}})
+	assert.Len(t, ids, 1)
+	ret, err := ts.postJSON("api/tasks?"+url.Values{"cursor": {next}}.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, repository.ErrCursor.Error(), ret["error"])
+}
+
+func TestSearchIndexMigrations(t *testing.T) {
+	t.Parallel()
+	cfg := config.Config{DBFile: filepath.Join(t.TempDir(), "scheduler.db")}
+	repo, ok := openStore(t, cfg)
+	if !ok {
+		return
+	}
+	admin, err := repo.GetUserByLogin("admin")
+	assert.NoError(t, err)
+	_, err = repo.CreateTask(admin.Id, &tasks.Task{Date: "20240101", Title: "Продлить домен"})
+	assert.NoError(t, err)
+
+	// Индекс — миграция 0014_search_index: откат удаляет его, повторное применение собирает заново
+	states, err := repo.MigrationStatus()
+	assert.NoError(t, err)
+	if assert.GreaterOrEqual(t, len(states), 14) {
+		assert.Equal(t, "search_index", states[13].Name)
+	}
+	assert.NoError(t, repo.MigrateDown(len(states)-13))
+	_, err = repo.GetSearch(admin.Id, parseQuery(t, "домен"), repository.Cursor{}, repository.NoLimit)
+	assert.ErrorContains(t, err, "no such table: scheduler_fts")
+
+	// Откат дальше пересоздаёт таблицы задач, индекс по ним собирается заново
+	assert.NoError(t, repo.MigrateDown(1))
+	assert.NoError(t, repo.MigrateUp(0))
+
+	list, err := repo.GetSearch(admin.Id, parseQuery(t, "домен"), repository.Cursor{}, repository.NoLimit)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	_, err = repo.CreateTask(admin.Id, &tasks.Task{Date: "20240102", Title: "Оплатить домен"})
+	assert.NoError(t, err)
+	list, err = repo.GetSearch(admin.Id, parseQuery(t, "домен"), repository.Cursor{}, repository.NoLimit)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+}
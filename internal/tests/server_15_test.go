@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/handler"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/server"
+	"go_final_project_avp/internal/users"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPassword пароль администратора тестового сервера.
+const testPassword = "12345"
+
+var (
+	setupOnce sync.Once
+	testApp   *slogavp.Application
+)
+
+// testServer приложение с БД в памяти, поднятое через httptest для одного теста.
+type testServer struct {
+	*httptest.Server
+	db      *sqlx.DB
+	handler *handler.Handler
+	token   string
+}
+
+// newTestServer запускает отдельный сервер со своей пустой БД и входит администратором.
+// Сервер и БД закрываются по завершении теста, поэтому тесты можно запускать параллельно.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWith(t, nil)
+}
+
+// newTestServerWith как newTestServer, configure меняет настройки сервера перед запуском.
+// Администратор входит с паролем из настроек.
+func newTestServerWith(t *testing.T, configure func(cfg *config.Config)) *testServer {
+	t.Helper()
+
+	// Настройки логгера и gin глобальные, меняем их один раз
+	setupOnce.Do(func() {
+		slogavp.SetLogConsole(false)
+		testApp = slogavp.SetupApplication()
+		gin.SetMode(gin.TestMode)
+		gin.DefaultWriter = io.Discard
+	})
+
+	cfg := config.Config{
+		DBFile:    repository.MemoryDB,
+		Password:  testPassword,
+		JwtSecret: "test_secret_key",
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	db, err := repository.NewOpenDB(cfg)
+	if err != nil {
+		t.Fatalf("Не удалось создать БД: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := repository.NewRepository(db, testApp)
+	if err = repo.RunMigrations(cfg); err != nil {
+		t.Fatalf("Не удалось выполнить миграции: %v", err)
+	}
+
+	h := handler.NewHandler(cfg, repo, testApp)
+	srv := httptest.NewServer(server.NewRouter(h, "../web"))
+	t.Cleanup(srv.Close)
+
+	ts := &testServer{Server: srv, db: db, handler: h}
+	// С хэшем в TODO_PASSWORD пароль неизвестен, тест входит сам
+	if users.IsHash(cfg.Password) {
+		return ts
+	}
+	ret, err := ts.postJSON("api/signin", map[string]any{"password": cfg.Password}, http.MethodPost)
+	if err != nil {
+		t.Fatalf("Не удалось войти: %v", err)
+	}
+	token, _ := ret["token"].(string)
+	if token == "" {
+		t.Fatalf("Не возвращён токен: %v", ret)
+	}
+	ts.token = token
+
+	return ts
+}
+
+func TestServerIsolation(t *testing.T) {
+	t.Parallel()
+	first := newTestServer(t)
+	second := newTestServer(t)
+
+	first.addTask(t, task{title: "Задача первого сервера"})
+	assert.Len(t, first.getTasks(t, ""), 1)
+	assert.Empty(t, second.getTasks(t, ""), "У каждого сервера должна быть своя БД")
+}
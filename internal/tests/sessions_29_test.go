@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/config"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// session токены одной сессии входа.
+type session struct {
+	token   string
+	refresh string
+}
+
+// signInSession вход с паролем, возвращает токен доступа и токен обновления.
+func (ts *testServer) signInSession(t *testing.T, login, password string) session {
+	ret, err := ts.postJSON("api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	s := session{}
+	s.token, _ = ret["token"].(string)
+	s.refresh, _ = ret["refresh_token"].(string)
+	assert.NotEmpty(t, s.token, ret)
+	assert.NotEmpty(t, s.refresh, ret)
+	return s
+}
+
+// withCookies запрос к API с заданными куками, возвращает код, тело и установленные сервером куки.
+func (ts *testServer) withCookies(t *testing.T, method, apipath string, values map[string]any, cookies map[string]string) (int, map[string]any, map[string]string) {
+	var body []byte
+	if len(values) > 0 {
+		var err error
+		body, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, ts.getURL(apipath), bytes.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, nil, nil
+	}
+	defer resp.Body.Close()
+
+	set := map[string]string{}
+	for _, cookie := range resp.Cookies() {
+		set[cookie.Name] = cookie.Value
+	}
+	var ret map[string]any
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&ret))
+	return resp.StatusCode, ret, set
+}
+
+// refresh обмен токена обновления, возвращает код ответа, новую сессию и текст ошибки.
+func (ts *testServer) refresh(t *testing.T, refresh string) (int, session, string) {
+	code, ret, _ := ts.withCookies(t, http.MethodPost, "api/refresh", map[string]any{"refresh_token": refresh}, nil)
+	s := session{}
+	s.token, _ = ret["token"].(string)
+	s.refresh, _ = ret["refresh_token"].(string)
+	msg, _ := ret["error"].(string)
+	return code, s, msg
+}
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	first := ts.signInSession(t, "", testPassword)
+	var claims struct {
+		Sid float64 `json:"sid"`
+		Iat int64   `json:"iat"`
+		Exp int64   `json:"exp"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(tokenClaims(t, first.token)), &claims))
+	assert.NotZero(t, claims.Sid)
+	assert.Equal(t, int64(15*60), claims.Exp-claims.Iat, "Токен доступа живёт 15 минут от момента выдачи")
+
+	code, ret := ts.statusOf(t, "api/sessions", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, ret["sessions"], 2, "Вход при запуске сервера и вход теста")
+
+	// Обмен токена обновления выдаёт новую пару, старый токен обновления больше не подходит
+	code, second, _ := ts.refresh(t, first.refresh)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, first.refresh, second.refresh)
+	code, _ = ts.authStatus(t, second.token)
+	assert.Equal(t, http.StatusOK, code)
+	code, _, msg := ts.refresh(t, "неизвестный токен")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Неверный токен обновления", msg)
+
+	// Повторное использование заменённого токена отзывает всю сессию
+	code, _, msg = ts.refresh(t, first.refresh)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Сессия завершена, войдите заново", msg)
+	for _, token := range []string{first.token, second.token} {
+		code, msg = ts.authStatus(t, token)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "Сессия завершена, войдите заново", msg)
+	}
+	code, _, _ = ts.refresh(t, second.refresh)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Выход завершает только свою сессию
+	other := ts.signInSession(t, "", testPassword)
+	code, _, cookies := ts.withCookies(t, http.MethodPost, "api/logout", nil, map[string]string{"token": other.token})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, cookies, "token")
+	assert.Empty(t, cookies["token"], "Кука с токеном удаляется")
+	code, _ = ts.authStatus(t, other.token)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _, _ = ts.refresh(t, other.refresh)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = ts.statusOf(t, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code, "Сессия при запуске сервера не затронута")
+
+	// Выход на всех устройствах завершает все сессии пользователя, но не чужие
+	ts.registerUser(t, "bob", "bob-password")
+	phone := ts.signInSession(t, "bob", "bob-password")
+	laptop := ts.signInSession(t, "bob", "bob-password")
+	code, ret, _ = ts.withCookies(t, http.MethodPost, "api/logout/all", nil, map[string]string{"token": laptop.token})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(3), ret["revoked"])
+	for _, s := range []session{phone, laptop} {
+		code, _ = ts.authStatus(t, s.token)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		code, _, _ = ts.refresh(t, s.refresh)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	}
+	code, _ = ts.statusOf(t, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestSessionRenewal(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+	s := ts.signInSession(t, "", testPassword)
+
+	// Без куки с токеном доступа новый токен выдаётся по куке с токеном обновления, сам он не меняется
+	code, _, cookies := ts.withCookies(t, http.MethodGet, "api/tasks", nil, map[string]string{"refresh_token": s.refresh})
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, cookies["token"])
+	assert.NotContains(t, cookies, "refresh_token")
+	code, _ = ts.authStatus(t, cookies["token"])
+	assert.Equal(t, http.StatusOK, code)
+
+	// Так же с истёкшим токеном доступа, а с поддельным — отказ
+	var claims jwt.MapClaims
+	assert.NoError(t, json.Unmarshal([]byte(tokenClaims(t, s.token)), &claims))
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test_secret_key"))
+	assert.NoError(t, err)
+	code, _ = ts.authStatus(t, expired)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _, cookies = ts.withCookies(t, http.MethodGet, "api/tasks", nil, map[string]string{"token": expired, "refresh_token": s.refresh})
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, cookies["token"])
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("other_secret_key"))
+	assert.NoError(t, err)
+	code, _, _ = ts.withCookies(t, http.MethodGet, "api/tasks", nil, map[string]string{"token": forged, "refresh_token": s.refresh})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Истёкшая сессия не обновляется
+	_, err = ts.db.Exec(`UPDATE sessions SET expires_at = ? WHERE token_hash != ''`, time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+	assert.NoError(t, err)
+	code, ret, _ := ts.withCookies(t, http.MethodGet, "api/tasks", nil, map[string]string{"refresh_token": s.refresh})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Сессия завершена, войдите заново", ret["error"])
+	code, _, _ = ts.refresh(t, s.refresh)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestSessionSettings(t *testing.T) {
+	t.Parallel()
+	ts := newTestServerWith(t, func(cfg *config.Config) {
+		cfg.AccessTTL = time.Hour
+		cfg.RefreshTTL = 48 * time.Hour
+	})
+
+	ret, err := ts.postJSON("api/signin", map[string]any{"password": testPassword}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3600), ret["expires_in"])
+
+	var expires string
+	assert.NoError(t, ts.db.Get(&expires, `SELECT expires_at FROM sessions ORDER BY id DESC LIMIT 1`))
+	at, err := time.Parse(time.RFC3339, expires)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), at, time.Minute)
+
+	// После смены пароля токен обновления не подходит
+	ts.registerUser(t, "bob", "bob-password")
+	bob := ts.signInSession(t, "bob", "bob-password")
+	_, err = ts.db.Exec(`UPDATE users SET password_hash = 'другой хэш' WHERE login = 'bob'`)
+	assert.NoError(t, err)
+	code, _, msg := ts.refresh(t, bob.refresh)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Пароль изменён, войдите заново", msg)
+}
@@ -0,0 +1,273 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/calendar"
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/tasks"
+	"go_final_project_avp/internal/users"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// openStore создаёт хранилище с применёнными миграциями.
+func openStore(t *testing.T, cfg config.Config) (*repository.Repository, bool) {
+	slogavp.SetLogConsole(false)
+	app := slogavp.SetupApplication()
+
+	db, err := repository.NewOpenDB(cfg)
+	if !assert.NoError(t, err) {
+		return nil, false
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := repository.NewRepository(db, app)
+	if !assert.NoError(t, repo.RunMigrations(cfg)) {
+		return nil, false
+	}
+	return repo, true
+}
+
+// withSearchPath добавляет в строку подключения PostgreSQL схему по умолчанию.
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return strings.TrimSpace(dsn) + " search_path=" + schema
+}
+
+func TestStoreSQLite(t *testing.T) {
+	repo, ok := openStore(t, config.Config{
+		DBDriver: repository.DriverSQLite,
+		DBFile:   filepath.Join(t.TempDir(), "scheduler.db"),
+	})
+	if ok {
+		testStore(t, repo)
+	}
+}
+
+// postgresDSN строка подключения к PostgreSQL для теста: TODO_TEST_PG_DSN или встроенный сервер,
+// который запускается на свободном порту и останавливается после теста. Бинарные файлы PostgreSQL
+// скачиваются при первом запуске из TODO_TEST_PG_REPO (по умолчанию Maven Central) и кэшируются.
+// Если встроенный сервер не запустился, тест пропускается, с TODO_TEST_PG_REQUIRED — падает.
+func postgresDSN(t *testing.T) string {
+	if dsn := os.Getenv("TODO_TEST_PG_DSN"); dsn != "" {
+		return dsn
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось выбрать порт для PostgreSQL: %v", err)
+	}
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+	_ = listener.Close()
+
+	var log bytes.Buffer
+	pgConfig := embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(t.TempDir()).
+		StartTimeout(time.Minute).
+		Logger(&log)
+	if repo := os.Getenv("TODO_TEST_PG_REPO"); repo != "" {
+		pgConfig = pgConfig.BinaryRepositoryURL(repo)
+	}
+	pg := embeddedpostgres.NewDatabase(pgConfig)
+	if err = pg.Start(); err != nil {
+		// Без сети бинарные файлы не скачать: обычный go test ./... пропускает тест,
+		// а TODO_TEST_PG_REQUIRED=1 в CI делает это ошибкой
+		msg := fmt.Sprintf("Не удалось запустить встроенный PostgreSQL: %v\n%s\n"+
+			"Задайте TODO_TEST_PG_DSN или TODO_TEST_PG_REPO", err, log.String())
+		if os.Getenv("TODO_TEST_PG_REQUIRED") == "" {
+			t.Skip(msg)
+		}
+		t.Fatal(msg)
+	}
+	t.Cleanup(func() {
+		if err := pg.Stop(); err != nil {
+			t.Errorf("Не удалось остановить встроенный PostgreSQL: %v", err)
+		}
+	})
+
+	return pgConfig.GetConnectionURL() + "?sslmode=disable"
+}
+
+// TestStorePostgres те же проверки на PostgreSQL. Каждый запуск работает в отдельной схеме,
+// которая удаляется после теста.
+func TestStorePostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("-short: тест PostgreSQL пропущен")
+	}
+	dsn := postgresDSN(t)
+
+	admin, err := sqlx.Connect(repository.DriverPostgres, dsn)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer admin.Close()
+
+	schema := fmt.Sprintf("todo_test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		_, _ = admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+	}()
+
+	repo, ok := openStore(t, config.Config{
+		DBDriver: repository.DriverPostgres,
+		DBDSN:    withSearchPath(dsn, schema),
+	})
+	if ok {
+		testStore(t, repo)
+	}
+}
+
+// testStore общие проверки хранилища, одинаковые для всех драйверов.
+func testStore(t *testing.T, repo *repository.Repository) {
+	var store repository.Store = repo
+
+	aliceID, err := store.CreateUser(&users.User{Login: "alice", PasswordHash: "hash"})
+	assert.NoError(t, err)
+	bobID, err := store.CreateUser(&users.User{Login: "bob", PasswordHash: "hash"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, aliceID, bobID)
+
+	_, err = store.CreateUser(&users.User{Login: "alice", PasswordHash: "hash"})
+	assert.ErrorIs(t, err, repository.ErrUserExists)
+
+	alice, err := store.GetUserByLogin("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, aliceID, alice.Id)
+	_, err = store.GetUserById(bobID)
+	assert.NoError(t, err)
+	_, err = store.GetUserByLogin("nobody")
+	assert.Error(t, err)
+
+	for i, title := range []string{"Купить молоко", "Позвонить в Bank", "Оплатить счёт в bank"} {
+		task := tasks.Task{Date: fmt.Sprintf("2024010%d", i+1), Title: title, Repeat: "d 5"}
+		id, err := store.CreateTask(aliceID, &task)
+		assert.NoError(t, err)
+		assert.NotZero(t, id)
+	}
+	bobTask := tasks.Task{Date: "20240101", Title: "Задача Боба"}
+	bobTaskID, err := store.CreateTask(bobID, &bobTask)
+	assert.NoError(t, err)
+
+	list, err := store.GetTasks(aliceID, repository.Cursor{}, repository.DefaultLimit)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 3) {
+		assert.Equal(t, "20240101", list[0].Date)
+	}
+	list, err = store.GetTasks(aliceID, repository.Cursor{}, 2)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		after, err := repository.CursorAfter(list[1])
+		assert.NoError(t, err)
+		list, err = store.GetTasks(aliceID, after, 2)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+	}
+	list, err = store.GetTasks(aliceID, repository.Cursor{}, repository.NoLimit)
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+
+	// Поиск по подстроке не зависит от регистра латиницы
+	list, err = store.GetSearch(aliceID, parseQuery(t, "BANK"), repository.Cursor{}, repository.DefaultLimit)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	list, err = store.GetSearch(aliceID, parseQuery(t, "02.01.2024"), repository.Cursor{}, repository.NoLimit)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	list, err = store.GetSearch(bobID, parseQuery(t, "bank"), repository.Cursor{}, repository.DefaultLimit)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	id := fmt.Sprint(bobTaskID)
+	_, err = store.GetTasksId(aliceID, id)
+	assert.Error(t, err, "Чужая задача не должна быть видна")
+	task, err := store.GetTasksId(bobID, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Задача Боба", task.Title)
+
+	task.Title = "Обновлённая задача"
+	task.Repeat = "d 31"
+	assert.Equal(t, int64(1), task.Version)
+	assert.NoError(t, store.UpdateTask(bobID, &task))
+	assert.Equal(t, int64(2), task.Version)
+	err = store.UpdateTask(aliceID, &task)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, repository.ErrVersionConflict)
+
+	// Устаревшая версия не перезаписывает задачу, ошибка содержит текущую
+	stale := task
+	stale.Version = 1
+	var conflict *repository.ConflictError
+	if assert.ErrorAs(t, store.UpdateTask(bobID, &stale), &conflict) {
+		assert.Equal(t, task.Version, conflict.Current.Version)
+		assert.Equal(t, "Обновлённая задача", conflict.Current.Title)
+	}
+
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	_, err = store.DoneTask(bobID, id, 1, "", now)
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+	completion, err := store.DoneTask(bobID, id, task.Version, "готово", now)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, completion.Id)
+	assert.Equal(t, "20240101", completion.ScheduledDate)
+	_, err = store.DoneTask(aliceID, id, 0, "", now)
+	assert.Error(t, err)
+	history, err := store.GetCompletions(bobID, id, time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []tasks.Completion{completion}, history)
+	history, err = store.GetCompletions(aliceID, "", time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, history, "Чужая история не должна быть видна")
+	task, err = store.GetTasksId(bobID, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Обновлённая задача", task.Title)
+	assert.Equal(t, "20240201", task.Date)
+	assert.Equal(t, int64(3), task.Version)
+
+	assert.Error(t, store.DeleteTask(aliceID, id))
+	assert.NoError(t, store.DeleteTask(bobID, id))
+	_, err = store.GetTasksId(bobID, id)
+	assert.Error(t, err)
+
+	feed := calendar.Feed{Name: "Телефон", TokenHash: "feed-hash"}
+	_, err = store.CreateFeed(aliceID, &feed)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, feed.Id)
+	found, err := store.GetFeedByToken("feed-hash")
+	assert.NoError(t, err)
+	assert.Equal(t, aliceID, found.UserId)
+	feeds, err := store.GetFeeds(bobID)
+	assert.NoError(t, err)
+	assert.Empty(t, feeds)
+	assert.Error(t, store.DeleteFeed(bobID, feed.Id))
+	assert.NoError(t, store.DeleteFeed(aliceID, feed.Id))
+
+	// Миграции драйвера полностью откатываются и применяются заново
+	states, err := repo.MigrationStatus()
+	assert.NoError(t, err)
+	assert.NoError(t, repo.MigrateDown(len(states)))
+	assert.NoError(t, repo.MigrateUp(0))
+	_, err = store.GetUserByLogin(users.AdminLogin)
+	assert.NoError(t, err)
+}
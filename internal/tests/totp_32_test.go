@@ -0,0 +1,329 @@
+package tests
+
+import (
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/users"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcKey общий ключ тестовых векторов RFC 4226 и RFC 6238 для SHA1.
+const rfcKey = "12345678901234567890"
+
+// testClock фиксированные часы проверки кодов, двигаются только вызовом add.
+type testClock struct {
+	unix atomic.Int64
+}
+
+// fixClock ставит серверу часы на момент start.
+func (ts *testServer) fixClock(start time.Time) *testClock {
+	clock := &testClock{}
+	clock.unix.Store(start.Unix())
+	ts.handler.SetClock(func() time.Time { return time.Unix(clock.unix.Load(), 0) })
+	return clock
+}
+
+func (c *testClock) now() time.Time {
+	return time.Unix(c.unix.Load(), 0)
+}
+
+func (c *testClock) add(d time.Duration) {
+	c.unix.Add(int64(d / time.Second))
+}
+
+// code код приложения-аутентификатора с секретом secret на момент часов.
+func (c *testClock) code(t *testing.T, secret string) string {
+	code, err := users.TOTPCode(secret, users.TOTPStep(c.now()))
+	assert.NoError(t, err)
+	return code
+}
+
+// enableTOTP подключает второй фактор пользователю с токеном token, возвращает секрет и коды восстановления.
+func (ts *testServer) enableTOTP(t *testing.T, clock *testClock, token string) (string, []string) {
+	ret, err := ts.postJSONAs(token, "api/2fa/setup", nil, http.MethodPost)
+	assert.NoError(t, err)
+	secret, _ := ret["secret"].(string)
+	assert.NotEmpty(t, secret, ret)
+
+	ret, err = ts.postJSONAs(token, "api/2fa/enable", map[string]any{"code": clock.code(t, secret)}, http.MethodPost)
+	assert.NoError(t, err)
+	var codes []string
+	list, _ := ret["recovery_codes"].([]any)
+	for _, v := range list {
+		codes = append(codes, v.(string))
+	}
+	assert.Len(t, codes, users.RecoveryCodeCount, ret)
+	return secret, codes
+}
+
+// signInCode второй шаг входа, возвращает код ответа, токен доступа и текст ошибки.
+func (ts *testServer) signInCode(t *testing.T, mfaToken, code string) (int, string, string) {
+	status, ret, _ := ts.withCookies(t, http.MethodPost, "api/signin/2fa", map[string]any{"mfa_token": mfaToken, "code": code}, nil)
+	token, _ := ret["token"].(string)
+	msg, _ := ret["error"].(string)
+	return status, token, msg
+}
+
+// mfaToken первый шаг входа пользователя со вторым фактором, возвращает токен второго шага.
+func (ts *testServer) mfaToken(t *testing.T, login, password string) string {
+	ret, err := ts.postJSON("api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, true, ret["mfa_required"], ret)
+	assert.NotContains(t, ret, "token", "Токен доступа выдаётся только после кода")
+	assert.NotContains(t, ret, "refresh_token")
+	token, _ := ret["mfa_token"].(string)
+	assert.NotEmpty(t, token)
+	return token
+}
+
+func TestHOTPVectors(t *testing.T) {
+	t.Parallel()
+
+	// RFC 4226, приложение D
+	for counter, want := range []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"} {
+		assert.Equal(t, want, users.HOTP([]byte(rfcKey), uint64(counter), 6), counter)
+	}
+
+	// RFC 6238, приложение B, SHA1 с кодами из 8 цифр
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(rfcKey))
+	for _, v := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		step := users.TOTPStep(time.Unix(v.unix, 0))
+		assert.Equal(t, v.want, users.HOTP([]byte(rfcKey), uint64(step), 8), v.unix)
+		// Шестизначный код приложения — последние 6 цифр того же значения
+		code, err := users.TOTPCode(strings.ToLower(secret), step)
+		assert.NoError(t, err)
+		assert.Equal(t, v.want[2:], code, v.unix)
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	t.Parallel()
+	secret, err := users.NewTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+	now := time.Date(2026, 3, 1, 12, 0, 10, 0, time.UTC)
+	step := users.TOTPStep(now)
+
+	code := func(step int64) string {
+		c, err := users.TOTPCode(secret, step)
+		assert.NoError(t, err)
+		return c
+	}
+
+	// Соседние шаги принимаются из-за расхождения часов, дальние — нет
+	for _, delta := range []int64{-1, 0, 1} {
+		got, ok := users.VerifyTOTP(secret, code(step+delta), now, 0)
+		assert.True(t, ok, delta)
+		assert.Equal(t, step+delta, got)
+	}
+	for _, delta := range []int64{-2, 2} {
+		_, ok := users.VerifyTOTP(secret, code(step+delta), now, 0)
+		assert.False(t, ok, delta)
+	}
+	// Принятый шаг и более ранние повторно не подходят
+	_, ok := users.VerifyTOTP(secret, code(step), now, step)
+	assert.False(t, ok)
+	_, ok = users.VerifyTOTP(secret, code(step-1), now, step-1)
+	assert.False(t, ok)
+	_, ok = users.VerifyTOTP(secret, code(step+1), now, step)
+	assert.True(t, ok)
+	_, ok = users.VerifyTOTP(secret, "12345", now, 0)
+	assert.False(t, ok)
+
+	assert.True(t, users.IsTOTPCode("012345"))
+	assert.False(t, users.IsTOTPCode("abcde-fghij"))
+	assert.False(t, users.IsTOTPCode("1234567"))
+
+	uri := users.TOTPURI("Планировщик задач", "bob", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/"), uri)
+	assert.Contains(t, uri, ":bob?")
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "period=30")
+
+	codes, err := users.NewRecoveryCodes()
+	assert.NoError(t, err)
+	assert.Len(t, codes, users.RecoveryCodeCount)
+	assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, codes[0])
+	assert.NotEqual(t, codes[0], codes[1])
+	assert.Equal(t, users.NormalizeRecoveryCode(codes[0]), users.NormalizeRecoveryCode(" "+strings.ToUpper(codes[0])))
+}
+
+func TestTOTPSignIn(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+	clock := ts.fixClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	bob := ts.registerUser(t, "bob", "bob-password")
+
+	ret, err := ts.postJSONAs(bob, "api/2fa", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, false, ret["enabled"])
+	ret, err = ts.postJSONAs(bob, "api/2fa/enable", map[string]any{"code": "123456"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Подключение начинается с секрета")
+
+	// Секрет и URI для QR-кода, без подтверждения кодом вход не меняется
+	ret, err = ts.postJSONAs(bob, "api/2fa/setup", nil, http.MethodPost)
+	assert.NoError(t, err)
+	secret, _ := ret["secret"].(string)
+	uri, _ := ret["uri"].(string)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/"), uri)
+	assert.Contains(t, uri, "secret="+secret)
+	assert.NotEmpty(t, ts.signIn(t, "bob", "bob-password"))
+
+	step := users.TOTPStep(clock.now())
+	wrong, err := users.TOTPCode(secret, step+5)
+	assert.NoError(t, err)
+	ret, err = ts.postJSONAs(bob, "api/2fa/enable", map[string]any{"code": wrong}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "Неверный код", ret["error"])
+	ret, err = ts.postJSONAs(bob, "api/2fa/enable", map[string]any{"code": clock.code(t, secret)}, http.MethodPost)
+	assert.NoError(t, err)
+	list, _ := ret["recovery_codes"].([]any)
+	assert.Len(t, list, users.RecoveryCodeCount, ret)
+	recovery := list[0].(string)
+	code, _ := ts.statusOf(t, "api/2fa/setup", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, "У администратора второй фактор не подключён")
+	ret, err = ts.postJSONAs(bob, "api/2fa/setup", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Подключённый второй фактор не сбросить новым секретом")
+
+	// Пароль проверяется как раньше, но токены выдаются только после кода
+	assert.Empty(t, ts.signIn(t, "bob", "wrong-password"))
+	mfa := ts.mfaToken(t, "bob", "bob-password")
+	status, msg := ts.authStatus(t, mfa)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Неверный токен", msg)
+	status, _, msg = ts.signInCode(t, bob, clock.code(t, secret))
+	assert.Equal(t, http.StatusUnauthorized, status, "Токен доступа не заменяет токен второго шага")
+	assert.NotEqual(t, "Неверный код", msg)
+
+	// Код, которым подтверждено подключение, повторно не подходит
+	status, _, msg = ts.signInCode(t, mfa, clock.code(t, secret))
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Неверный код", msg)
+
+	clock.add(30 * time.Second)
+	current := clock.code(t, secret)
+	status, token, msg := ts.signInCode(t, mfa, current)
+	assert.Equal(t, http.StatusOK, status, msg)
+	status, _ = ts.authStatus(t, token)
+	assert.Equal(t, http.StatusOK, status)
+	status, _, msg = ts.signInCode(t, ts.mfaToken(t, "bob", "bob-password"), current)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Неверный код", msg)
+
+	// Код со следующего шага принимается из-за расхождения часов
+	next, err := users.TOTPCode(secret, users.TOTPStep(clock.now())+1)
+	assert.NoError(t, err)
+	status, _, msg = ts.signInCode(t, mfa, next)
+	assert.Equal(t, http.StatusOK, status, msg)
+
+	// Код восстановления подходит один раз, регистр и пробелы не важны
+	status, token, msg = ts.signInCode(t, mfa, " "+strings.ToUpper(recovery)+" ")
+	assert.Equal(t, http.StatusOK, status, msg)
+	assert.NotEmpty(t, token)
+	status, _, msg = ts.signInCode(t, mfa, recovery)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Неверный код", msg)
+
+	ret, err = ts.postJSONAs(token, "api/2fa", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, true, ret["enabled"])
+	assert.Equal(t, float64(users.RecoveryCodeCount-1), ret["recovery_codes_left"])
+
+	// Токен второго шага не подходит после смены пароля и после истечения
+	status, _, _ = ts.signInCode(t, "не.jwt.токен", "123456")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	var hash string
+	assert.NoError(t, ts.db.Get(&hash, `SELECT password_hash FROM users WHERE login = 'bob'`))
+	_, err = ts.db.Exec(`UPDATE users SET password_hash = ? WHERE login = 'bob'`, hash+"x")
+	assert.NoError(t, err)
+	clock.add(30 * time.Second)
+	status, _, msg = ts.signInCode(t, mfa, clock.code(t, secret))
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Вход не начат или устарел, введите пароль заново", msg)
+}
+
+func TestTOTPLockout(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+	clock := ts.fixClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	bob := ts.registerUser(t, "bob", "bob-password")
+	secret, codes := ts.enableTOTP(t, clock, bob)
+	clock.add(time.Minute)
+
+	mfa := ts.mfaToken(t, "bob", "bob-password")
+	wrong, err := users.TOTPCode(secret, users.TOTPStep(clock.now())+10)
+	assert.NoError(t, err)
+	for i := 1; i < 5; i++ {
+		status, _, msg := ts.signInCode(t, mfa, wrong)
+		assert.Equal(t, http.StatusUnauthorized, status, i)
+		assert.Equal(t, "Неверный код", msg)
+	}
+	status, _, msg := ts.signInCode(t, mfa, "zzzzz-zzzzz")
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "Слишком много неверных кодов, попробуйте через несколько минут", msg)
+
+	// Во время блокировки не подходят и верные коды
+	status, _, _ = ts.signInCode(t, mfa, clock.code(t, secret))
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	status, _, _ = ts.signInCode(t, mfa, codes[0])
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	clock.add(5 * time.Minute)
+	status, token, msg := ts.signInCode(t, mfa, clock.code(t, secret))
+	assert.Equal(t, http.StatusOK, status, msg)
+	// Код восстановления, введённый во время блокировки, не погашен
+	ret, err := ts.postJSONAs(token, "api/2fa", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(users.RecoveryCodeCount), ret["recovery_codes_left"])
+
+	// Новые коды восстановления заменяют прежние
+	ret, err = ts.postJSONAs(token, "api/2fa/recovery", map[string]any{"code": wrong}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "Неверный код", ret["error"])
+	ret, err = ts.postJSONAs(token, "api/2fa/recovery", map[string]any{"code": codes[1]}, http.MethodPost)
+	assert.NoError(t, err)
+	fresh, _ := ret["recovery_codes"].([]any)
+	if assert.Len(t, fresh, users.RecoveryCodeCount, ret) {
+		status, _, _ = ts.signInCode(t, ts.mfaToken(t, "bob", "bob-password"), codes[2])
+		assert.Equal(t, http.StatusUnauthorized, status)
+		status, _, msg = ts.signInCode(t, ts.mfaToken(t, "bob", "bob-password"), fresh[0].(string))
+		assert.Equal(t, http.StatusOK, status, msg)
+	}
+
+	// Отключение требует кода, после него вход снова только по паролю
+	ret, err = ts.postJSONAs(token, "api/2fa/disable", map[string]any{"code": ""}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "Неверный код", ret["error"])
+	clock.add(30 * time.Second)
+	ret, err = ts.postJSONAs(token, "api/2fa/disable", map[string]any{"code": clock.code(t, secret)}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	assert.NotEmpty(t, ts.signIn(t, "bob", "bob-password"))
+	var count int
+	assert.NoError(t, ts.db.Get(&count, `SELECT count(*) FROM recovery_codes`))
+	assert.Zero(t, count)
+
+	// По API-ключу второй фактор не настроить
+	_, key := ts.createAPIKey(t, "cron", "tasks:read")
+	status, _ = ts.withAuthorization(t, http.MethodPost, "api/2fa/setup", nil, "Bearer "+key)
+	assert.Equal(t, http.StatusForbidden, status)
+}
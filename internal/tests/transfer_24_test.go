@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// transfer запрос к API выгрузки и загрузки с произвольным телом, возвращает код, заголовки и тело ответа.
+func (ts *testServer) transfer(t *testing.T, token, method, apipath string, body []byte) (int, http.Header, []byte) {
+	req, err := http.NewRequest(method, ts.getURL(apipath), bytes.NewReader(body))
+	assert.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, nil, nil
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, resp.Header, data
+}
+
+// importTasks POST /api/import, возвращает код и разобранный ответ.
+func (ts *testServer) importTasks(t *testing.T, token, query string, body []byte) (int, map[string]any) {
+	status, _, data := ts.transfer(t, token, http.MethodPost, "api/import?"+query, body)
+	var ret map[string]any
+	assert.NoError(t, json.Unmarshal(data, &ret), string(data))
+	return status, ret
+}
+
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	today := time.Now().Format(`20060102`)
+	repeating := ts.addTask(t, task{date: today, title: "Оплатить счета", comment: `Свет, газ и "интернет"`, repeat: "m 10"})
+	once := ts.addTask(t, task{date: today, title: "Купить подарок"})
+	removed := ts.addTask(t, task{date: today, title: "В корзине"})
+	ret, err := ts.postJSON("api/task?id="+removed, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	// JSON: массив задач без корзины
+	status, header, body := ts.transfer(t, ts.token, http.MethodGet, "api/export", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, header.Get("Content-Disposition"), "attachment")
+	var exported []map[string]any
+	assert.NoError(t, json.Unmarshal(body, &exported), string(body))
+	if assert.Len(t, exported, 2) {
+		titles := []any{exported[0]["title"], exported[1]["title"]}
+		assert.ElementsMatch(t, []any{"Оплатить счета", "Купить подарок"}, titles)
+	}
+	jsonExport := body
+
+	// CSV с заголовком, кавычки и запятые экранируются
+	status, header, body = ts.transfer(t, ts.token, http.MethodGet, "api/export?format=csv", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, header.Get("Content-Type"), "text/csv")
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, []string{"id", "date", "title", "comment", "repeat"}, records[0])
+		assert.Contains(t, records[1:], []string{repeating, ts.getTask(t, repeating)["date"].(string),
+			"Оплатить счета", `Свет, газ и "интернет"`, "m 10"})
+	}
+	csvExport := body
+
+	status, _, body = ts.transfer(t, ts.token, http.MethodGet, "api/export?repeat_format=rrule", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "FREQ=MONTHLY;BYMONTHDAY=10")
+
+	// Файл переносится другому пользователю, его задачи не меняются
+	bob := ts.registerUser(t, "bob", "bobpassword")
+	status, ret = ts.importTasks(t, bob, "format=csv", csvExport)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, float64(2), ret["created"])
+	assert.Equal(t, false, ret["dry_run"])
+	ret, err = ts.postJSONAs(bob, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Len(t, ret["tasks"], 2)
+	assert.Len(t, ts.getTasks(t, ""), 2)
+
+	// upsert изменяет задачи с id пользователя, чужие и новые добавляет
+	status, ret = ts.importTasks(t, bob, "mode=upsert", []byte(`[{"id": "`+once+`", "title": "Чужая"}]`))
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, float64(1), ret["created"])
+	assert.Equal(t, "Купить подарок", ts.getTask(t, once)["title"])
+
+	var upsert []map[string]any
+	assert.NoError(t, json.Unmarshal(jsonExport, &upsert))
+	for _, item := range upsert {
+		if item["id"] == once {
+			item["title"] = "Купить цветы"
+		}
+	}
+	upsert = append(upsert, map[string]any{"title": "Новая задача", "repeat": "d 1"})
+	data, err := json.Marshal(upsert)
+	assert.NoError(t, err)
+	status, ret = ts.importTasks(t, ts.token, "mode=upsert", data)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, float64(2), ret["updated"])
+	assert.Equal(t, float64(1), ret["created"])
+	assert.Equal(t, "Купить цветы", ts.getTask(t, once)["title"])
+	assert.Len(t, ts.getTasks(t, ""), 3)
+
+	// Пробный импорт показывает ошибки по строкам и ничего не меняет
+	file := "title,date,repeat\nПолить цветы,20200101,d 3\n,20240101,\nОтпуск,01.08.2026,\nЗарядка,,x 1\n"
+	status, ret = ts.importTasks(t, ts.token, "format=csv&dry_run=true", []byte(file))
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, true, ret["dry_run"])
+	assert.Equal(t, float64(1), ret["created"])
+	assert.Equal(t, float64(3), ret["invalid"])
+	results, _ := ret["results"].([]any)
+	if assert.Len(t, results, 4) {
+		first := results[0].(map[string]any)
+		assert.Equal(t, "created", first["action"])
+		assert.Nil(t, first["error"])
+		for i, row := range results[1:] {
+			assert.NotEmpty(t, row.(map[string]any)["error"], "Ожидается ошибка в задаче %d", i+2)
+		}
+	}
+	assert.Len(t, ts.getTasks(t, ""), 3)
+
+	status, ret = ts.importTasks(t, ts.token, "format=csv", []byte(file))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, float64(3), ret["invalid"])
+	assert.Len(t, ts.getTasks(t, ""), 3)
+
+	for _, query := range []struct {
+		query string
+		body  string
+	}{
+		{"format=xml", "[]"},
+		{"mode=replace", `[{"title": "Задача"}]`},
+		{"dry_run=maybe", `[{"title": "Задача"}]`},
+		{"", "[]"},
+		{"", `{"title": "Не массив"}`},
+		{"format=csv", "title,priority\nЗадача,1\n"},
+		{"format=csv", "date\n20240101\n"},
+		{"format=csv", "title\n"},
+		{"format=csv", ""},
+	} {
+		status, ret = ts.importTasks(t, ts.token, query.query, []byte(query.body))
+		assert.Equal(t, http.StatusBadRequest, status, query)
+		assert.NotEmpty(t, ret["error"], query)
+	}
+	assert.Len(t, ts.getTasks(t, ""), 3)
+
+	status, _, body = ts.transfer(t, ts.token, http.MethodGet, "api/export?format=xml", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.True(t, strings.Contains(string(body), "format"))
+}
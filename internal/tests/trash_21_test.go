@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getTrash задачи из корзины в порядке выдачи.
+func (ts *testServer) getTrash(t *testing.T) []map[string]any {
+	ret, err := ts.postJSON("api/trash", nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var list []map[string]any
+	items, _ := ret["tasks"].([]any)
+	for _, v := range items {
+		item, _ := v.(map[string]any)
+		list = append(list, item)
+	}
+	return list
+}
+
+// restoreTask POST /api/task/restore, возвращает текст ошибки.
+func (ts *testServer) restoreTask(t *testing.T, id string) string {
+	ret, err := ts.postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	msg, _ := ret["error"].(string)
+	return msg
+}
+
+func TestTrash(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	// Удалённая задача попадает в корзину и не видна в списке и поиске
+	id := ts.addTask(t, task{date: today, title: "Купить билеты", comment: "На концерт"})
+	ret, err := ts.postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ts.notFoundTask(t, id)
+	assert.Empty(t, ts.getTasks(t, ""))
+	assert.Empty(t, ts.searchTitles(t, "билеты"))
+	assert.Equal(t, float64(0), ts.getCounts(t, nil)["total"])
+
+	trash := ts.getTrash(t)
+	if assert.Len(t, trash, 1) {
+		assert.Equal(t, id, trash[0]["id"])
+		assert.Equal(t, "Купить билеты", trash[0]["title"])
+		deletedAt, err := time.Parse(time.RFC3339, trash[0]["deleted_at"].(string))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, now, deletedAt, time.Minute)
+	}
+
+	// Повторное удаление и изменение задачи из корзины не работают
+	ret, err = ts.postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	ret, err = ts.postJSON("api/task", map[string]any{"id": id, "date": today, "title": "Изменить"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	assert.Empty(t, ts.restoreTask(t, id))
+	assert.Empty(t, ts.getTrash(t))
+	assert.ElementsMatch(t, []string{"Купить билеты"}, ts.searchTitles(t, "билеты"))
+
+	// Выполненная разовая задача восстанавливается вместе с отменой выполнения
+	ret, err = ts.postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Len(t, ts.getTrash(t), 1)
+	assert.Len(t, ts.getHistory(t, url.Values{"id": {id}}), 1)
+	assert.Empty(t, ts.restoreTask(t, id))
+	assert.Len(t, ts.getTasks(t, ""), 1)
+	assert.Empty(t, ts.getHistory(t, url.Values{"id": {id}}))
+	assert.NotEmpty(t, ts.restoreTask(t, id), "Восстанавливать больше нечего")
+
+	// Отменяется только последнее выполнение повторяющейся задачи и только один раз
+	repeating := ts.addTask(t, task{date: today, title: "Зарядка", repeat: "d 2"})
+	for i := 0; i < 2; i++ {
+		ret, err = ts.postJSON("api/task/done?id="+repeating, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	taskDate := func() string {
+		ret, err := ts.postJSON("api/task?id="+repeating, nil, http.MethodGet)
+		assert.NoError(t, err)
+		date, _ := ret["date"].(string)
+		return date
+	}
+	assert.Equal(t, now.AddDate(0, 0, 4).Format(`20060102`), taskDate())
+	assert.Empty(t, ts.restoreTask(t, repeating))
+	assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), taskDate())
+	assert.Equal(t, repository.ErrNotInTrash.Error(), ts.restoreTask(t, repeating), "Более раннее выполнение не отменяется")
+	assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), taskDate())
+	assert.Len(t, ts.getHistory(t, url.Values{"id": {repeating}}), 1)
+
+	// Чужую задачу восстановить нельзя
+	ret, err = ts.postJSON("api/task?id="+repeating, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	bob := ts.registerUser(t, "bob", "bobpassword")
+	ret, err = ts.postJSONAs(bob, "api/task/restore?id="+repeating, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	ret, err = ts.postJSONAs(bob, "api/trash", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{}, ret["tasks"])
+
+	assert.NotEmpty(t, ts.restoreTask(t, "abc"))
+	assert.NotEmpty(t, ts.restoreTask(t, "999999"))
+}
+
+func TestRestoreAfterEdit(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+	today := time.Now().Format(`20060102`)
+	later := time.Now().AddDate(0, 0, 10).Format(`20060102`)
+
+	// Изменённую после выполнения задачу отмена не трогает
+	id := ts.addTask(t, task{date: today, title: "Полить цветы", repeat: "d 3"})
+	ret, err := ts.postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = ts.postJSON("api/task", map[string]any{"id": id, "date": later, "title": "Полить цветы", "repeat": "d 3"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	assert.Equal(t, repository.ErrNotInTrash.Error(), ts.restoreTask(t, id))
+	assert.Equal(t, later, ts.getTask(t, id)["date"])
+	assert.Len(t, ts.getHistory(t, url.Values{"id": {id}}), 1)
+
+	// Выполнение разовой задачи отменяется вместе с возвратом из корзины, второй раз отменять нечего
+	once := ts.addTask(t, task{date: today, title: "Отправить письмо"})
+	ret, err = ts.postJSON("api/task/done?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, ts.restoreTask(t, once))
+	assert.Empty(t, ts.getHistory(t, url.Values{"id": {once}}))
+	assert.Equal(t, repository.ErrNotInTrash.Error(), ts.restoreTask(t, once))
+}
+
+func TestPurgeTrash(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	old := ts.addTask(t, task{title: "Давно удалённая"})
+	recent := ts.addTask(t, task{title: "Недавно удалённая"})
+	for _, id := range []string{old, recent} {
+		ret, err := ts.postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	_, err := ts.db.Exec(`UPDATE scheduler SET deleted_at = ? WHERE id = ?`,
+		time.Now().AddDate(0, 0, -40).UTC().Format(time.RFC3339), old)
+	assert.NoError(t, err)
+
+	repo := repository.NewRepository(ts.db, testApp)
+	count, err := repo.PurgeTrash(time.Now().AddDate(0, 0, -30))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	trash := ts.getTrash(t)
+	if assert.Len(t, trash, 1) {
+		assert.Equal(t, recent, trash[0]["id"])
+	}
+	assert.NotEmpty(t, ts.restoreTask(t, old), "Очищенная задача удалена окончательно")
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// postJSONAs запрос к API с токеном указанного пользователя.
+func (ts *testServer) postJSONAs(token, apipath string, values map[string]any, method string) (map[string]any, error) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		if data, err = json.Marshal(values); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, ts.getURL(apipath), bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	err = json.Unmarshal(body, &m)
+	return m, err
+}
+
+// registerUser регистрирует пользователя и возвращает его токен.
+func (ts *testServer) registerUser(t *testing.T, login, password string) string {
+	ret, err := ts.postJSON("api/register", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["id"], "Не возвращён id пользователя: %v", ret)
+
+	ret, err = ts.postJSON("api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	token, _ := ret["token"].(string)
+	assert.NotEmpty(t, token, "Не возвращён токен: %v", ret)
+	return token
+}
+
+func TestUsers(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	alice := ts.registerUser(t, "alice"+suffix, "alice-password")
+	bob := ts.registerUser(t, "bob"+suffix, "bob-password")
+
+	ret, err := ts.postJSON("api/register", map[string]any{"login": "alice" + suffix, "password": "other-password"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Ожидается ошибка для занятого логина")
+
+	ret, err = ts.postJSON("api/register", map[string]any{"login": "carol" + suffix, "password": "short"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Ожидается ошибка для короткого пароля")
+
+	ret, err = ts.postJSON("api/signin", map[string]any{"login": "alice" + suffix, "password": "bob-password"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Ожидается ошибка для чужого пароля")
+
+	date := time.Now().Format(`20060102`)
+	ret, err = ts.postJSONAs(alice, "api/task", map[string]any{
+		"date":  date,
+		"title": "Задача Алисы " + suffix,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	ret, err = ts.postJSONAs(alice, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "Задача Алисы "+suffix, ret["title"])
+
+	// Чужая задача не видна, не изменяется и не удаляется
+	ret, err = ts.postJSONAs(bob, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = ts.postJSONAs(bob, "api/tasks?search="+suffix, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["tasks"])
+
+	ret, err = ts.postJSONAs(bob, "api/task", map[string]any{
+		"id":    id,
+		"date":  date,
+		"title": "Захват",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = ts.postJSONAs(bob, "api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = ts.postJSONAs(bob, "api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = ts.postJSONAs(alice, "api/tasks?search="+suffix, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Len(t, ret["tasks"], 1)
+
+	ret, err = ts.postJSON("api/tasks?search="+suffix, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["tasks"], "Администратор не должен видеть задачи других пользователей")
+}
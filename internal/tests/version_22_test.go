@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/tasks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getTask задача из GET /api/task.
+func (ts *testServer) getTask(t *testing.T, id string) map[string]any {
+	ret, err := ts.postJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	return ret
+}
+
+// statusOf код ответа и тело запроса к API.
+func (ts *testServer) statusOf(t *testing.T, apipath string, values map[string]any, method string) (int, map[string]any) {
+	var body []byte
+	if len(values) > 0 {
+		var err error
+		body, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, ts.getURL(apipath), bytes.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "token", Value: ts.token})
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+
+	var ret map[string]any
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&ret))
+	return resp.StatusCode, ret
+}
+
+func TestTaskVersion(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	today := time.Now().Format(`20060102`)
+	id := ts.addTask(t, task{date: today, title: "Полить цветы", repeat: "d 3"})
+	assert.Equal(t, "1", ts.getTask(t, id)["version"])
+
+	update := map[string]any{"id": id, "date": today, "title": "Полить кактус", "repeat": "d 3", "version": "1"}
+	status, ret := ts.statusOf(t, "api/task", update, http.MethodPut)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, ret)
+	assert.Equal(t, "2", ts.getTask(t, id)["version"])
+
+	// Повторное изменение по устаревшей версии возвращает 409 и текущую задачу
+	update["title"] = "Полить фикус"
+	status, ret = ts.statusOf(t, "api/task", update, http.MethodPut)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, repository.ErrVersionConflict.Error(), ret["error"])
+	if current, ok := ret["task"].(map[string]any); assert.True(t, ok, "Нет текущей задачи: %v", ret) {
+		assert.Equal(t, "Полить кактус", current["title"])
+		assert.Equal(t, "2", current["version"])
+	}
+	assert.Equal(t, "Полить кактус", ts.getTask(t, id)["title"])
+
+	// Без версии изменение не проверяется
+	delete(update, "version")
+	status, _ = ts.statusOf(t, "api/task", update, http.MethodPut)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "3", ts.getTask(t, id)["version"])
+
+	// Выполнение по устаревшей версии не переносит задачу и не пишет историю
+	status, ret = ts.statusOf(t, "api/task/done?id="+id+"&version=2", nil, http.MethodPost)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.NotNil(t, ret["task"])
+	assert.Equal(t, today, ts.getTask(t, id)["date"])
+	assert.Empty(t, ts.getHistory(t, url.Values{"id": {id}}))
+
+	status, ret = ts.statusOf(t, "api/task/done?id="+id+"&version=3", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, ret)
+	current := ts.getTask(t, id)
+	assert.Equal(t, time.Now().AddDate(0, 0, 3).Format(`20060102`), current["date"])
+	assert.Equal(t, "4", current["version"])
+
+	for _, version := range []string{"abc", "0", "-1"} {
+		status, _ = ts.statusOf(t, "api/task/done?id="+id+"&version="+version, nil, http.MethodPost)
+		assert.Equal(t, http.StatusBadRequest, status, version)
+		status, _ = ts.statusOf(t, "api/task/restore?id="+id+"&version="+version, nil, http.MethodPost)
+		assert.Equal(t, http.StatusBadRequest, status, version)
+	}
+
+	// Отмена выполнения по устаревшей версии не возвращает дату и не трогает историю
+	status, ret = ts.statusOf(t, "api/task/restore?id="+id+"&version=3", nil, http.MethodPost)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, repository.ErrVersionConflict.Error(), ret["error"])
+	if current, ok := ret["task"].(map[string]any); assert.True(t, ok, "Нет текущей задачи: %v", ret) {
+		assert.Equal(t, "4", current["version"])
+	}
+	assert.Equal(t, time.Now().AddDate(0, 0, 3).Format(`20060102`), ts.getTask(t, id)["date"])
+	assert.Len(t, ts.getHistory(t, url.Values{"id": {id}}), 1)
+	status, _ = ts.statusOf(t, "api/task/restore?id="+id+"&version=4", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, today, ts.getTask(t, id)["date"])
+	assert.Equal(t, "5", ts.getTask(t, id)["version"])
+
+	// Задача из корзины тоже возвращается только по текущей версии
+	status, _ = ts.statusOf(t, "api/task?id="+id, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, status)
+	status, ret = ts.statusOf(t, "api/task/restore?id="+id+"&version=5", nil, http.MethodPost)
+	assert.Equal(t, http.StatusConflict, status)
+	if current, ok := ret["task"].(map[string]any); assert.True(t, ok, "Нет текущей задачи: %v", ret) {
+		assert.Equal(t, "6", current["version"])
+		assert.NotEmpty(t, current["deleted_at"])
+	}
+	ts.notFoundTask(t, id)
+	status, _ = ts.statusOf(t, "api/task/restore?id="+id+"&version=6", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "7", ts.getTask(t, id)["version"])
+}
+
+func TestConcurrentDone(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	today := time.Now().Format(`20060102`)
+	id := ts.addTask(t, task{date: today, title: "Еженедельный отчёт", repeat: "d 7"})
+
+	// Одновременные отметки с одной версией переносят задачу только один раз
+	const clients = 8
+	statuses := make(chan int, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := ts.statusOf(t, "api/task/done?id="+id+"&version=1", nil, http.MethodPost)
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	count := make(map[int]int)
+	for status := range statuses {
+		count[status]++
+	}
+	assert.Equal(t, map[int]int{http.StatusOK: 1, http.StatusConflict: clients - 1}, count)
+	assert.Equal(t, time.Now().AddDate(0, 0, 7).Format(`20060102`), ts.getTask(t, id)["date"])
+	assert.Len(t, ts.getHistory(t, url.Values{"id": {id}}), 1)
+}
+
+func TestConcurrentDoneFile(t *testing.T) {
+	t.Parallel()
+	repo, ok := openStore(t, config.Config{DBFile: filepath.Join(t.TempDir(), "scheduler.db")})
+	if !ok {
+		return
+	}
+	admin, err := repo.GetUserByLogin("admin")
+	assert.NoError(t, err)
+	taskID, err := repo.CreateTask(admin.Id, &tasks.Task{Date: "20240101", Title: "Отчёт", Repeat: "d 7"})
+	assert.NoError(t, err)
+	id := fmt.Sprint(taskID)
+
+	// Отдельные соединения к файлу БД ждут блокировку, а не получают "database is locked"
+	const clients = 8
+	errs := make(chan error, clients)
+	var wg sync.WaitGroup
+	now := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.DoneTask(admin.Id, id, 1, "", now)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var done, conflicts int
+	for err := range errs {
+		switch {
+		case err == nil:
+			done++
+		case assert.ErrorIs(t, err, repository.ErrVersionConflict):
+			conflicts++
+		}
+	}
+	assert.Equal(t, 1, done)
+	assert.Equal(t, clients-1, conflicts)
+
+	task, err := repo.GetTasksId(admin.Id, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "20240108", task.Date)
+	assert.Equal(t, int64(2), task.Version)
+}
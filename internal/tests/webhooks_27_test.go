@@ -0,0 +1,403 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"go_final_project_avp/internal/config"
+	"go_final_project_avp/internal/repository"
+	"go_final_project_avp/internal/webhooks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getDeliveries журнал доставок подписки.
+func (ts *testServer) getDeliveries(t *testing.T, id string) []map[string]any {
+	code, ret := ts.statusOf(t, "api/webhooks/deliveries?id="+id, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code, ret)
+	list, _ := ret["deliveries"].([]any)
+	result := make([]map[string]any, 0, len(list))
+	for _, v := range list {
+		result = append(result, v.(map[string]any))
+	}
+	return result
+}
+
+// allowPrivateHooks разрешает подписки на localhost: получатели в тестах — httptest-серверы на 127.0.0.1.
+func allowPrivateHooks(cfg *config.Config) {
+	cfg.HooksAllowPrivate = true
+}
+
+func TestWebhooks(t *testing.T) {
+	t.Parallel()
+	ts := newTestServerWith(t, allowPrivateHooks)
+	today := time.Now().Format(`20060102`)
+
+	for _, body := range []map[string]any{
+		{"url": "ftp://example.com/hook"},
+		{"url": "/hook"},
+		{"url": "http://example.com/hook", "events": []string{"task.archived"}},
+	} {
+		code, ret := ts.statusOf(t, "api/webhooks", body, http.MethodPost)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.NotEmpty(t, ret["error"])
+	}
+
+	all := newJSONStandIn(t, 2, `{}`)
+	done := newJSONStandIn(t, 0, `{}`)
+	code, hookAll := ts.statusOf(t, "api/webhooks", map[string]any{"url": all.URL + "/all"}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, hookAll)
+	assert.Equal(t, []any{"task.created", "task.updated", "task.done", "task.deleted"}, hookAll["events"])
+	secret, _ := hookAll["secret"].(string)
+	assert.Len(t, secret, 64)
+	code, hookDone := ts.statusOf(t, "api/webhooks",
+		map[string]any{"url": done.URL, "events": []string{"task.done", "task.done"}}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, hookDone)
+	assert.Equal(t, []any{"task.done"}, hookDone["events"])
+
+	code, ret := ts.statusOf(t, "api/webhooks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	if list, _ := ret["webhooks"].([]any); assert.Len(t, list, 2) {
+		assert.NotContains(t, list[0], "secret", "Секрет выдаётся только при создании")
+	}
+
+	// Подписки доступны только администраторам
+	bob := ts.registerUser(t, "bob", "bobsecret")
+	ret, err := ts.postJSONAs(bob, "api/webhooks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "Доступно только администратору", ret["error"])
+
+	// Другой администратор не видит чужие подписки, его события им не доставляются
+	bob = ts.promote(t, "bob", "bobsecret", "admin")
+	ret, err = ts.postJSONAs(bob, "api/webhooks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["webhooks"])
+	ret, err = ts.postJSONAs(bob, "api/webhooks/deliveries?id="+hookAll["id"].(string), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "Подписка не найдена", ret["error"])
+	_, err = ts.postJSONAs(bob, "api/task", map[string]any{"date": today, "title": "Задача Боба"}, http.MethodPost)
+	assert.NoError(t, err)
+
+	// События: создание, изменение, выполнение повторяющейся задачи и удаление разовой
+	id := ts.addTask(t, task{date: today, title: "Полить цветы", repeat: "d 2"})
+	code, _ = ts.statusOf(t, "api/task", map[string]any{"id": id, "date": today, "title": "Полить кактус", "repeat": "d 2"}, http.MethodPut)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = ts.statusOf(t, "api/task/done?id="+id, map[string]any{"note": "полил"}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	once := ts.addTask(t, task{date: today, title: "Купить молоко"})
+	code, _ = ts.statusOf(t, "api/task?id="+once, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, code)
+	// Неудачные запросы событий не создают
+	code, _ = ts.statusOf(t, "api/task?id=999", nil, http.MethodDelete)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	deliveries := ts.getDeliveries(t, hookAll["id"].(string))
+	if assert.Len(t, deliveries, 5) {
+		assert.Equal(t, "task.deleted", deliveries[0]["event"], "Последние доставки первыми")
+		for _, d := range deliveries {
+			assert.Equal(t, "pending", d["status"])
+		}
+	}
+
+	// Получатель дважды отвечает ошибкой: эти доставки повторяются после задержки
+	repo := repository.NewRepository(ts.db, testApp)
+	dispatcher := &webhooks.Dispatcher{Store: repo, AllowPrivate: true, MaxAttempts: 3, Backoff: time.Minute, App: testApp}
+	ctx := context.Background()
+	now := time.Now()
+	delivered, err := dispatcher.RunOnce(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, delivered)
+
+	delivered, err = dispatcher.RunOnce(ctx, now.Add(30*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered, "Повтор только после задержки")
+
+	deliveries = ts.getDeliveries(t, hookAll["id"].(string))
+	if assert.Len(t, deliveries, 5) {
+		created := deliveries[4]
+		assert.Equal(t, "task.created", created["event"])
+		assert.Equal(t, "pending", created["status"])
+		assert.Equal(t, float64(1), created["attempts"])
+		assert.Equal(t, float64(http.StatusInternalServerError), created["response_status"])
+		assert.Contains(t, created["error"], "500")
+		assert.Equal(t, now.Add(time.Minute).UTC().Format(time.RFC3339), created["next_attempt_at"])
+	}
+
+	delivered, err = dispatcher.RunOnce(ctx, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+
+	deliveries = ts.getDeliveries(t, hookAll["id"].(string))
+	for _, d := range deliveries {
+		assert.Equal(t, "delivered", d["status"], d)
+		assert.NotEmpty(t, d["delivered_at"])
+		assert.Empty(t, d["next_attempt_at"])
+	}
+
+	// Запросы подписаны секретом подписки
+	headers, bodies := all.requests()
+	received := all.received()
+	events := map[string]map[string]any{}
+	if assert.Len(t, bodies, 5) {
+		for i, body := range bodies {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), headers[i].Get(webhooks.HeaderSignature))
+			assert.NotEmpty(t, headers[i].Get(webhooks.HeaderDelivery))
+			event := received[i]
+			assert.Equal(t, headers[i].Get(webhooks.HeaderEvent), event["event"])
+			events[event["event"].(string)+" "+event["task"].(map[string]any)["id"].(string)] = event
+		}
+	}
+
+	if event := events["task.created "+id]; assert.NotNil(t, event) {
+		assert.Equal(t, "Полить цветы", event["task"].(map[string]any)["title"])
+		assert.Equal(t, "1", event["task"].(map[string]any)["version"])
+	}
+	if event := events["task.updated "+id]; assert.NotNil(t, event) {
+		assert.Equal(t, "Полить кактус", event["task"].(map[string]any)["title"])
+		assert.Equal(t, "2", event["task"].(map[string]any)["version"])
+	}
+	if event := events["task.done "+id]; assert.NotNil(t, event) {
+		next := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+		assert.Equal(t, next, event["task"].(map[string]any)["date"])
+		completion := event["completion"].(map[string]any)
+		assert.Equal(t, id, completion["task_id"])
+		assert.Equal(t, today, completion["scheduled_date"])
+		assert.Equal(t, "полил", completion["note"])
+	}
+	if event := events["task.deleted "+once]; assert.NotNil(t, event) {
+		assert.Equal(t, "Купить молоко", event["task"].(map[string]any)["title"])
+	}
+
+	// Подписка только на выполнение получила одно событие
+	if doneReceived := done.received(); assert.Len(t, doneReceived, 1) {
+		assert.Equal(t, "task.done", doneReceived[0]["event"])
+	}
+
+	// Удаление подписки удаляет и её журнал
+	code, _ = ts.statusOf(t, "api/webhooks?id="+hookAll["id"].(string), nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = ts.statusOf(t, "api/webhooks?id="+hookAll["id"].(string), nil, http.MethodDelete)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = ts.statusOf(t, "api/webhooks/deliveries?id="+hookAll["id"].(string), nil, http.MethodGet)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestWebhookAttempts(t *testing.T) {
+	t.Parallel()
+	ts := newTestServerWith(t, allowPrivateHooks)
+
+	broken := newJSONStandIn(t, 100, `{}`)
+	code, hook := ts.statusOf(t, "api/webhooks", map[string]any{"url": broken.URL, "events": []string{"task.created"}}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, hook)
+	ts.addTask(t, task{date: time.Now().Format(`20060102`), title: "Задача"})
+
+	repo := repository.NewRepository(ts.db, testApp)
+	dispatcher := &webhooks.Dispatcher{Store: repo, AllowPrivate: true, MaxAttempts: 3, Backoff: time.Minute, App: testApp}
+	now := time.Now()
+	for _, at := range []time.Duration{0, time.Minute, 3 * time.Minute, time.Hour} {
+		delivered, err := dispatcher.RunOnce(context.Background(), now.Add(at))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+	}
+
+	deliveries := ts.getDeliveries(t, hook["id"].(string))
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "failed", deliveries[0]["status"])
+		assert.Equal(t, float64(3), deliveries[0]["attempts"], "После последней попытки доставка не повторяется")
+		assert.Empty(t, deliveries[0]["next_attempt_at"])
+	}
+
+	// Задержка удваивается после каждой неудачи и не превышает MaxBackoff
+	assert.Equal(t, time.Minute, dispatcher.Delay(1))
+	assert.Equal(t, 2*time.Minute, dispatcher.Delay(2))
+	assert.Equal(t, 8*time.Minute, dispatcher.Delay(4))
+	assert.Equal(t, webhooks.MaxBackoff, dispatcher.Delay(20))
+}
+
+// deliveredEvents события из журнала доставок подписки в порядке их появления: "событие id-задачи".
+func (ts *testServer) deliveredEvents(t *testing.T, id string) []string {
+	deliveries := ts.getDeliveries(t, id)
+	events := make([]string, 0, len(deliveries))
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		payload, _ := deliveries[i]["payload"].(map[string]any)
+		taskID := ""
+		if task, ok := payload["task"].(map[string]any); ok {
+			taskID, _ = task["id"].(string)
+		}
+		events = append(events, deliveries[i]["event"].(string)+" "+taskID)
+	}
+	return events
+}
+
+func TestWebhookBulkEvents(t *testing.T) {
+	t.Parallel()
+	ts := newTestServerWith(t, allowPrivateHooks)
+	today := time.Now().Format(`20060102`)
+
+	receiver := newJSONStandIn(t, 0, `{}`)
+	code, hook := ts.statusOf(t, "api/webhooks", map[string]any{"url": receiver.URL}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, hook)
+	hookID := hook["id"].(string)
+
+	once := ts.addTask(t, task{date: today, title: "Купить молоко"})
+	repeating := ts.addTask(t, task{date: today, title: "Зарядка", repeat: "d 2"})
+	shifted := ts.addTask(t, task{date: today, title: "Позвонить маме"})
+	removed := ts.addTask(t, task{date: today, title: "Черновик"})
+	start := len(ts.deliveredEvents(t, hookID))
+	assert.Equal(t, 4, start)
+
+	// Пакет: событие на каждую операцию после фиксации транзакции
+	code, ret := ts.statusOf(t, "api/tasks/batch", map[string]any{"ops": []map[string]any{
+		{"op": "create", "date": today, "title": "Новая задача"},
+		{"op": "update", "id": once, "date": today, "title": "Купить кефир"},
+		{"op": "done", "id": repeating, "note": "утром"},
+		{"op": "shift", "id": shifted, "days": 3},
+		{"op": "delete", "id": removed},
+	}}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, ret)
+	created := batchResults(ret)[0]["id"].(string)
+
+	// Отменённый пакет событий не создаёт
+	code, ret = ts.statusOf(t, "api/tasks/batch", map[string]any{"ops": []map[string]any{
+		{"op": "create", "date": today, "title": "Не будет создана"},
+		{"op": "delete", "id": "999"},
+	}}, http.MethodPost)
+	assert.Equal(t, http.StatusNotFound, code, ret)
+
+	// Импорт: создание новых задач и изменение существующей; пробный импорт событий не создаёт
+	code, ret = ts.importTasks(t, ts.token, "format=json&mode=upsert", []byte(`[
+		{"id": "`+once+`", "date": "`+today+`", "title": "Купить сметану"},
+		{"date": "`+today+`", "title": "Из файла"}
+	]`))
+	assert.Equal(t, http.StatusOK, code, ret)
+	imported := ret["results"].([]any)[1].(map[string]any)["id"].(string)
+	code, ret = ts.importTasks(t, ts.token, "format=json&dry_run=true", []byte(`[{"date": "`+today+`", "title": "Проба"}]`))
+	assert.Equal(t, http.StatusOK, code, ret)
+
+	// Восстановление из корзины — изменение задачи
+	assert.Empty(t, ts.restoreTask(t, removed))
+
+	events := ts.deliveredEvents(t, hookID)
+	assert.Equal(t, []string{
+		"task.created " + created,
+		"task.updated " + once,
+		"task.done " + repeating,
+		"task.updated " + shifted,
+		"task.deleted " + removed,
+		"task.updated " + once,
+		"task.created " + imported,
+		"task.updated " + removed,
+	}, events[start:])
+
+	deliveries := ts.getDeliveries(t, hookID)
+	for _, d := range deliveries {
+		payload := d["payload"].(map[string]any)
+		task, _ := payload["task"].(map[string]any)
+		switch d["event"].(string) + " " + task["id"].(string) {
+		case "task.done " + repeating:
+			assert.Equal(t, "утром", payload["completion"].(map[string]any)["note"])
+			assert.Equal(t, time.Now().AddDate(0, 0, 2).Format(`20060102`), task["date"])
+		case "task.updated " + shifted:
+			assert.Equal(t, time.Now().AddDate(0, 0, 3).Format(`20060102`), task["date"])
+			assert.Equal(t, "2", task["version"])
+		case "task.deleted " + removed:
+			assert.Equal(t, "Черновик", task["title"])
+		case "task.created " + imported:
+			assert.Equal(t, "Из файла", task["title"])
+			assert.Equal(t, "1", task["version"])
+		}
+	}
+	if assert.NotEmpty(t, deliveries) {
+		restored := deliveries[0]["payload"].(map[string]any)["task"].(map[string]any)
+		assert.Equal(t, "Черновик", restored["title"])
+		assert.Equal(t, today, restored["date"])
+	}
+}
+
+func TestWebhookPrivateAddress(t *testing.T) {
+	t.Parallel()
+	ts := newTestServer(t)
+
+	// Адреса внутренней сети отклоняются при создании подписки, имя хоста проверяется по его адресам
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.0.0.5/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0/hook",
+		"http://[::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://[fe80::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	} {
+		code, ret := ts.statusOf(t, "api/webhooks", map[string]any{"url": target}, http.MethodPost)
+		assert.Equal(t, http.StatusBadRequest, code, target)
+		assert.Equal(t, webhooks.ErrPrivateAddress.Error(), ret["error"], target)
+	}
+	code, ret := ts.statusOf(t, "api/webhooks", map[string]any{"url": "https://93.184.216.34/hook"}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, ret)
+
+	// Адрес проверяется и при соединении: подписка, созданная на внутренний адрес, например после
+	// смены DNS-записи, не получает событий
+	internal := newTestServerWith(t, allowPrivateHooks)
+	receiver := newJSONStandIn(t, 0, `{}`)
+	code, hook := internal.statusOf(t, "api/webhooks", map[string]any{"url": receiver.URL}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code, hook)
+	internal.addTask(t, task{date: time.Now().Format(`20060102`), title: "Задача"})
+
+	repo := repository.NewRepository(internal.db, testApp)
+	dispatcher := &webhooks.Dispatcher{Store: repo, MaxAttempts: 3, Backoff: time.Minute, App: testApp}
+	delivered, err := dispatcher.RunOnce(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Empty(t, receiver.received())
+	if deliveries := internal.getDeliveries(t, hook["id"].(string)); assert.Len(t, deliveries, 1) {
+		assert.Contains(t, deliveries[0]["error"], webhooks.ErrPrivateAddress.Error())
+		assert.Equal(t, "pending", deliveries[0]["status"])
+	}
+}
+
+func TestWebhookDemotion(t *testing.T) {
+	t.Parallel()
+	ts := newTestServerWith(t, allowPrivateHooks)
+	today := time.Now().Format(`20060102`)
+
+	ts.registerUser(t, "carol", "carol-password")
+	carol := ts.promote(t, "carol", "carol-password", "admin")
+	receiver := newJSONStandIn(t, 0, `{}`)
+	ret, err := ts.postJSONAs(carol, "api/webhooks", map[string]any{"url": receiver.URL}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["id"], ret)
+	_, err = ts.postJSONAs(carol, "api/task", map[string]any{"date": today, "title": "Задача Кэрол"}, http.MethodPost)
+	assert.NoError(t, err)
+
+	repo := repository.NewRepository(ts.db, testApp)
+	dispatcher := &webhooks.Dispatcher{Store: repo, AllowPrivate: true, App: testApp}
+	delivered, err := dispatcher.RunOnce(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	// Без роли admin подписки удаляются: управлять ими уже нельзя, и события в них не приходят
+	carol = ts.promote(t, "carol", "carol-password", "editor")
+	_, err = ts.postJSONAs(carol, "api/task", map[string]any{"date": today, "title": "Вторая задача Кэрол"}, http.MethodPost)
+	assert.NoError(t, err)
+	delivered, err = dispatcher.RunOnce(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Len(t, receiver.received(), 1)
+
+	carol = ts.promote(t, "carol", "carol-password", "admin")
+	ret, err = ts.postJSONAs(carol, "api/webhooks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["webhooks"])
+}
@@ -0,0 +1,74 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Области доступа API-ключей.
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+)
+
+// Scopes все области доступа в порядке вывода.
+var Scopes = []string{ScopeTasksRead, ScopeTasksWrite}
+
+// APIKeyPrefix начало каждого API-ключа, по нему ключ отличается от JWT-токена в заголовке Authorization.
+const APIKeyPrefix = "todo_"
+
+// APIKey именованный ключ пользователя для скриптов и интеграций. Сам ключ не хранится, только его хэш.
+type APIKey struct {
+	Id         string   `json:"id"`
+	UserId     int64    `json:"-"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"` // начало ключа, чтобы узнать его в списке
+	KeyHash    string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+}
+
+// Allows есть ли у ключа область доступа scope.
+func (k APIKey) Allows(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScopes проверяет области доступа ключа и возвращает их без повторов в порядке Scopes.
+// Ключ без областей ничего не может, поэтому пустой список — ошибка.
+func ParseScopes(list []string) ([]string, error) {
+	want := make(map[string]bool, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !isScope(s) {
+			return nil, fmt.Errorf("неизвестная область доступа '%s', допустимы: %s", s, strings.Join(Scopes, ", "))
+		}
+		want[s] = true
+	}
+	if len(want) == 0 {
+		return nil, fmt.Errorf("укажите области доступа ключа: %s", strings.Join(Scopes, ", "))
+	}
+
+	scopes := make([]string, 0, len(want))
+	for _, s := range Scopes {
+		if want[s] {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+// isScope известна ли область доступа.
+func isScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
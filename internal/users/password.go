@@ -0,0 +1,170 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Алгоритмы хэширования паролей.
+const (
+	AlgoBcrypt   = "bcrypt"
+	AlgoArgon2id = "argon2id"
+)
+
+// Параметры argon2id для новых хэшей по рекомендации OWASP.
+// Параметры существующего хэша записаны в нём самом, поэтому их изменение не ломает проверку.
+const (
+	argon2Memory  = 19 * 1024 // КиБ
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+	// argon2MaxMemory предел памяти при проверке хэша, защищает от хэша с завышенными параметрами
+	argon2MaxMemory = 1024 * 1024
+)
+
+// argon2Prefix начало хэша argon2id в формате PHC.
+const argon2Prefix = "$argon2id$"
+
+// Hasher хэширование новых паролей выбранным алгоритмом.
+type Hasher struct {
+	Algorithm  string // AlgoBcrypt или AlgoArgon2id, пустая строка — AlgoBcrypt
+	BcryptCost int    // 0 — bcrypt.DefaultCost
+}
+
+// Validate проверка настроек хэширования.
+func (h Hasher) Validate() error {
+	switch h.Algorithm {
+	case "", AlgoBcrypt:
+		if h.BcryptCost != 0 && (h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost) {
+			return fmt.Errorf("стоимость bcrypt должна быть от %d до %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return nil
+	case AlgoArgon2id:
+		return nil
+	default:
+		return fmt.Errorf("неизвестный алгоритм хэширования паролей '%s', допустимы %s и %s", h.Algorithm, AlgoBcrypt, AlgoArgon2id)
+	}
+}
+
+// Hash хэширование пароля для хранения в БД или в TODO_PASSWORD.
+func (h Hasher) Hash(password string) (string, error) {
+	if err := h.Validate(); err != nil {
+		return "", err
+	}
+	if h.Algorithm == AlgoArgon2id {
+		return hashArgon2id(password)
+	}
+
+	cost := h.BcryptCost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", fmt.Errorf("ошибка хэширования пароля: %w", err)
+	}
+	return string(hash), nil
+}
+
+// hashArgon2id хэш argon2id в формате PHC: $argon2id$v=19$m=...,t=...,p=...$соль$ключ.
+func hashArgon2id(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("ошибка создания соли: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	b64 := base64.RawStdEncoding
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// IsHash строка — хэш bcrypt или argon2id, а не сам пароль.
+func IsHash(s string) bool {
+	if strings.HasPrefix(s, argon2Prefix) {
+		return true
+	}
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPassword сравнение пароля с хэшем bcrypt или argon2id, время сравнения не зависит от совпадения.
+func CheckPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, argon2Prefix) {
+		return checkArgon2id(hash, password)
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// checkArgon2id сравнение пароля с хэшем argon2id с параметрами из хэша.
+func checkArgon2id(hash, password string) bool {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", соль, ключ
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var (
+		memory, time uint32
+		threads      uint8
+	)
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	if memory == 0 || memory > argon2MaxMemory || time == 0 || threads == 0 {
+		return false
+	}
+
+	b64 := base64.RawStdEncoding
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// CheckConfigPassword сравнение пароля с TODO_PASSWORD: в нём хэш или, для совместимости, сам пароль.
+// Пустой TODO_PASSWORD не подходит ни к какому паролю.
+func CheckConfigPassword(stored, password string) bool {
+	if stored == "" {
+		return false
+	}
+	if IsHash(stored) {
+		return CheckPassword(stored, password)
+	}
+	// Сравниваем хэши одной длины, чтобы время не зависело ни от совпадения, ни от длины пароля
+	a, b := sha256.Sum256([]byte(stored)), sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
+// CredentialVersion необратимая метка учётных данных для токена: HMAC секретом key от хэша пароля или TODO_PASSWORD.
+// Меняется вместе с паролем, поэтому старые токены перестают подходить, но по ней не восстановить ни пароль, ни хэш.
+func CredentialVersion(key, credential string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte("credential:" + credential))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,25 @@
+package users
+
+import "time"
+
+// Session сессия входа пользователя. Сам токен обновления не хранится, только его хэш.
+type Session struct {
+	Id           int64  `json:"id,string"`
+	UserId       int64  `json:"-"`
+	TokenHash    string `json:"-"` // sha256 текущего токена обновления
+	PreviousHash string `json:"-"` // sha256 токена до последнего обновления
+	Credential   string `json:"-"` // метка учётных данных на момент входа, см. CredentialVersion
+	CreatedAt    string `json:"created_at"`
+	RefreshedAt  string `json:"refreshed_at"`
+	ExpiresAt    string `json:"expires_at"`
+	RevokedAt    string `json:"revoked_at,omitempty"`
+}
+
+// Active сессия не отозвана и не истекла к моменту now.
+func (s Session) Active(now time.Time) bool {
+	if s.RevokedAt != "" {
+		return false
+	}
+	expires, err := time.Parse(time.RFC3339, s.ExpiresAt)
+	return err == nil && now.Before(expires)
+}
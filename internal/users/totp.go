@@ -0,0 +1,173 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Параметры TOTP по RFC 6238, их понимают все приложения-аутентификаторы.
+const (
+	TOTPPeriod = 30 * time.Second
+	TOTPDigits = 6
+	// TOTPSkew сколько соседних шагов принимать из-за расхождения часов
+	TOTPSkew = 1
+	// totpSecretBytes длина секрета, 160 бит по рекомендации RFC 4226
+	totpSecretBytes = 20
+)
+
+// Коды восстановления на случай потери телефона.
+const (
+	RecoveryCodeCount = 10
+	recoveryCodeLen   = 10
+)
+
+// base32NoPad кодировка секрета в URI и в ответе API.
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP настройки второго фактора пользователя.
+type TOTP struct {
+	UserId         int64
+	Secret         string // base32 без выравнивания
+	EnabledAt      string // пустая строка — подключение не завершено
+	LastStep       int64  // последний принятый шаг, код не принимается повторно
+	FailedAttempts int
+	LockedUntil    string // до этого времени коды не проверяются, пустая строка — без блокировки
+	CreatedAt      string
+}
+
+// Enabled второй фактор подключён и требуется при входе.
+func (t TOTP) Enabled() bool {
+	return t.EnabledAt != ""
+}
+
+// Locked проверка кодов заблокирована после серии ошибок.
+func (t TOTP) Locked(now time.Time) bool {
+	if t.LockedUntil == "" {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339, t.LockedUntil)
+	return err == nil && now.Before(until)
+}
+
+// NewTOTPSecret случайный секрет TOTP в base32.
+func NewTOTPSecret() (string, error) {
+	raw := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("ошибка создания секрета: %w", err)
+	}
+	return base32NoPad.EncodeToString(raw), nil
+}
+
+// HOTP одноразовый код по RFC 4226 из ключа key и счётчика counter длиной digits цифр.
+func HOTP(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Динамическое усечение: 31 бит с места, заданного последним полубайтом
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// TOTPStep номер шага TOTP для момента t.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// decodeTOTPSecret ключ из секрета base32, регистр и пробелы не важны.
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32NoPad.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("неверный секрет TOTP")
+	}
+	return key, nil
+}
+
+// TOTPCode код TOTP для шага step.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return HOTP(key, uint64(step), TOTPDigits), nil
+}
+
+// VerifyTOTP проверка кода на момент now с допуском TOTPSkew шагов. Шаги не позже lastStep не принимаются,
+// чтобы подсмотренный код нельзя было ввести второй раз. Возвращает шаг принятого кода.
+func VerifyTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(now)
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		if step <= lastStep || step < 0 {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(HOTP(key, uint64(step), TOTPDigits)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// IsTOTPCode строка похожа на код из приложения, а не на код восстановления.
+func IsTOTPCode(code string) bool {
+	if len(code) != TOTPDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// TOTPURI URI otpauth:// для QR-кода в приложении-аутентификаторе.
+func TOTPURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	return "otpauth://totp/" + url.PathEscape(issuer) + ":" + url.PathEscape(account) + "?" + query.Encode()
+}
+
+// NewRecoveryCodes RecoveryCodeCount случайных кодов восстановления вида abcde-fghij.
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeLen)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("ошибка создания кода восстановления: %w", err)
+		}
+		code := strings.ToLower(base32NoPad.EncodeToString(raw))[:recoveryCodeLen]
+		codes = append(codes, code[:recoveryCodeLen/2]+"-"+code[recoveryCodeLen/2:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode код восстановления без дефисов, пробелов и различия регистра.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,67 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// AdminLogin логин пользователя по умолчанию, которому принадлежат задачи,
+// созданные до появления учётных записей. Пароль администратора задаёт TODO_PASSWORD.
+const AdminLogin = "admin"
+
+// MinPasswordLength минимальная длина пароля при регистрации.
+const MinPasswordLength = 8
+
+// Роли пользователей, каждая следующая может всё, что предыдущая.
+const (
+	RoleViewer = "viewer" // читает задачи
+	RoleEditor = "editor" // создаёт, меняет, выполняет и удаляет задачи
+	RoleAdmin  = "admin"  // управляет пользователями, веб-хуками и снимками БД
+)
+
+// Roles все роли по возрастанию прав.
+var Roles = []string{RoleViewer, RoleEditor, RoleAdmin}
+
+// DefaultRole роль нового пользователя после регистрации.
+const DefaultRole = RoleEditor
+
+type User struct {
+	Id           int64  `json:"id,string"`
+	Login        string `json:"login"`
+	PasswordHash string `json:"-"` // пустой хэш — пароль берётся из TODO_PASSWORD
+	Role         string `json:"role"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// roleRank место роли в Roles, -1 — неизвестная роль.
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidRole известна ли роль.
+func ValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+// RoleAllows роли role доступно то, что требует need. Неизвестной роли не доступно ничего.
+func RoleAllows(role, need string) bool {
+	rank := roleRank(role)
+	return rank >= 0 && rank >= roleRank(need)
+}
+
+// Validate проверка логина и пароля при регистрации.
+func Validate(login, password string) error {
+	if n := utf8.RuneCountInString(login); n < 3 || n > 64 {
+		return errors.New("логин должен быть от 3 до 64 символов")
+	}
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return fmt.Errorf("пароль должен быть не короче %d символов", MinPasswordLength)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+// Второй шаг входа: после пароля сервер просит код из приложения-аутентификатора или код восстановления
+axios.interceptors.response.use(function (resp) {
+    if (!resp.data || !resp.data.mfa_required) {
+        return resp;
+    }
+    let code = window.prompt("Код из приложения-аутентификатора или код восстановления");
+    if (!code) {
+        return {data: {error: "Вход отменён"}};
+    }
+    return axios.post("/api/signin/2fa", {mfa_token: resp.data.mfa_token, code: code.trim()})
+        .catch(function (err) {
+            if (err.response && err.response.data) {
+                return {data: err.response.data};
+            }
+            throw err;
+        });
+});
@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	slogavp "github.com/Anatoly8853/slog-avp/v2"
+)
+
+// Значения Dispatcher по умолчанию.
+const (
+	DefaultAttempts = 8
+	DefaultBackoff  = 30 * time.Second
+	// MaxBackoff предел задержки между попытками
+	MaxBackoff = time.Hour
+	// deliveryTimeout время ожидания ответа получателя, если клиент не задан
+	deliveryTimeout = 10 * time.Second
+	// dispatchBatch сколько доставок берётся из очереди за один вызов RunOnce
+	dispatchBatch = 100
+)
+
+// Store очередь доставок.
+type Store interface {
+	// GetDueDeliveries доставки в состоянии pending, время попытки которых наступило к now, старые первыми.
+	GetDueDeliveries(now time.Time, limit int) ([]Delivery, error)
+	// UpdateDelivery записывает результат попытки: состояние, число попыток, время следующей и ответ.
+	UpdateDelivery(d Delivery) error
+}
+
+// Dispatcher отправляет события из очереди и повторяет неудачные попытки с экспоненциальной задержкой.
+type Dispatcher struct {
+	Store        Store
+	Client       *http.Client  // nil — клиент с таймаутом deliveryTimeout, соединяется только с публичными адресами
+	AllowPrivate bool          // клиенту по умолчанию можно соединяться с localhost и внутренней сетью
+	MaxAttempts  int           // после стольких неудач доставка помечается failed, 0 — DefaultAttempts
+	Backoff      time.Duration // задержка после первой неудачи, дальше удваивается до MaxBackoff; 0 — DefaultBackoff
+	App          *slogavp.Application
+}
+
+// Delay задержка перед следующей попыткой после attempts неудачных.
+func (d *Dispatcher) Delay(attempts int) time.Duration {
+	delay := d.Backoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	for i := 1; i < attempts && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxBackoff {
+		delay = MaxBackoff
+	}
+	return delay
+}
+
+// RunOnce отправляет доставки, время которых наступило к now, и возвращает число успешных.
+// Ошибки получателей записываются в доставку и не прерывают отправку остальных.
+func (d *Dispatcher) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	maxAttempts := d.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultAttempts
+	}
+
+	due, err := d.Store.GetDueDeliveries(now, dispatchBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, del := range due {
+		del.Attempts++
+		del.ResponseStatus, err = d.send(ctx, del)
+		switch {
+		case err == nil:
+			del.Status, del.Error, del.NextAttemptAt = StatusDelivered, "", ""
+			del.DeliveredAt = now.UTC().Format(time.RFC3339)
+			delivered++
+		case del.Attempts >= maxAttempts:
+			del.Status, del.Error, del.NextAttemptAt = StatusFailed, err.Error(), ""
+			d.App.Log.Errorf("Событие %s не доставлено на %s после %d попыток: %v", del.Id, del.URL, del.Attempts, err)
+		default:
+			del.Error = err.Error()
+			del.NextAttemptAt = now.Add(d.Delay(del.Attempts)).UTC().Format(time.RFC3339)
+		}
+
+		if err = d.Store.UpdateDelivery(del); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// send отправляет событие подписчику и возвращает код ответа, ответ не из 2xx считается ошибкой.
+func (d *Dispatcher) send(ctx context.Context, del Delivery) (int, error) {
+	client := d.Client
+	if client == nil {
+		client = NewClient(d.AllowPrivate)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, del.Event)
+	req.Header.Set(HeaderDelivery, del.Id)
+	req.Header.Set(HeaderSignature, Sign(del.Secret, del.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func(body io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, io.LimitReader(body, 1<<20))
+		_ = body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("ответ %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// NewClient клиент для отправки событий и напоминаний на адреса пользователей. Без allowPrivate адрес
+// проверяется при каждом соединении, уже после разрешения имени, поэтому ни перенаправление, ни смена
+// DNS-записи после создания подписки не приведут запрос во внутреннюю сеть. Прокси из окружения тогда не используется:
+// соединение шло бы с ним, а не с получателем.
+func NewClient(allowPrivate bool) *http.Client {
+	if allowPrivate {
+		return &http.Client{Timeout: deliveryTimeout}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: deliveryTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("некорректный адрес %s: %w", address, err)
+			}
+			if !PublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: deliveryTimeout, Transport: transport}
+}
+
+// Run вызывает RunOnce сразу и затем раз в interval, пока не отменён ctx.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if delivered, err := d.RunOnce(ctx, time.Now()); err != nil {
+			d.App.Log.Errorf("Ошибка доставки событий веб-хуков: %v", err)
+		} else if delivered > 0 {
+			d.App.Log.Infof("Доставлено событий веб-хуков: %d", delivered)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"go_final_project_avp/internal/tasks"
+)
+
+// События жизненного цикла задачи, на которые можно подписаться.
+const (
+	EventTaskCreated = "task.created"
+	EventTaskUpdated = "task.updated"
+	EventTaskDone    = "task.done"
+	EventTaskDeleted = "task.deleted"
+)
+
+// Events все события в порядке вывода.
+var Events = []string{EventTaskCreated, EventTaskUpdated, EventTaskDone, EventTaskDeleted}
+
+// Состояния доставки события.
+const (
+	StatusPending   = "pending"   // ждёт первой или повторной попытки
+	StatusDelivered = "delivered" // получатель ответил 2xx
+	StatusFailed    = "failed"    // попытки исчерпаны
+)
+
+// Заголовки запроса к получателю.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Webhook подписка пользователя на события задач.
+type Webhook struct {
+	Id        string   `json:"id"`
+	UserId    int64    `json:"-"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"-"` // выдаётся один раз при создании
+	CreatedAt string   `json:"created_at"`
+}
+
+// Delivery доставка события подписке: запись очереди и журнала доставок.
+type Delivery struct {
+	Id             string          `json:"id"`
+	WebhookId      string          `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  string          `json:"next_attempt_at,omitempty"` // время следующей попытки в UTC, RFC 3339
+	ResponseStatus int             `json:"response_status,omitempty"` // код ответа последней попытки
+	Error          string          `json:"error,omitempty"`           // ошибка последней попытки
+	CreatedAt      string          `json:"created_at"`
+	DeliveredAt    string          `json:"delivered_at,omitempty"`
+	URL            string          `json:"-"` // адрес и секрет подписки, нужны только для отправки
+	Secret         string          `json:"-"`
+}
+
+// Payload тело запроса с событием.
+type Payload struct {
+	Event      string            `json:"event"`
+	CreatedAt  string            `json:"created_at"` // время события в UTC, RFC 3339
+	Task       *tasks.Task       `json:"task,omitempty"`
+	Completion *tasks.Completion `json:"completion,omitempty"` // только у task.done
+}
+
+// NewPayload тело события event о задаче task в момент now.
+func NewPayload(event string, task *tasks.Task, completion *tasks.Completion, now time.Time) ([]byte, error) {
+	return json.Marshal(Payload{
+		Event:      event,
+		CreatedAt:  now.UTC().Format(time.RFC3339),
+		Task:       task,
+		Completion: completion,
+	})
+}
+
+// Sign подпись тела запроса секретом подписки: "sha256=" и HMAC-SHA256 в hex.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseEvents проверяет события подписки и возвращает их без повторов в порядке Events.
+// Пустой список — подписка на все события.
+func ParseEvents(list []string) ([]string, error) {
+	want := make(map[string]bool, len(list))
+	for _, e := range list {
+		e = strings.TrimSpace(e)
+		if !isEvent(e) {
+			return nil, fmt.Errorf("неизвестное событие '%s', допустимы: %s", e, strings.Join(Events, ", "))
+		}
+		want[e] = true
+	}
+
+	if len(want) == 0 {
+		return append([]string(nil), Events...), nil
+	}
+	events := make([]string, 0, len(want))
+	for _, e := range Events {
+		if want[e] {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// isEvent известно ли событие.
+func isEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrPrivateAddress адрес подписки ведёт во внутреннюю сеть сервера.
+var ErrPrivateAddress = errors.New("адрес подписки ведёт во внутреннюю сеть: localhost, частные и служебные адреса запрещены")
+
+// reservedPrefixes служебные диапазоны, которых нет среди проверок netip.Addr.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // «эта» сеть
+	netip.MustParsePrefix("100.64.0.0/10"), // общий адрес провайдера (CGNAT)
+	netip.MustParsePrefix("192.0.0.0/24"),  // служебные адреса IETF
+	netip.MustParsePrefix("198.18.0.0/15"), // сети для тестов производительности
+	netip.MustParsePrefix("240.0.0.0/4"),   // зарезервированные и широковещательный
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, ведёт на любой адрес IPv4
+}
+
+// PublicAddr можно ли отправлять события на адрес addr: не localhost, не частная сеть RFC 1918
+// и fc00::/7, не link-local (в том числе 169.254.169.254 облачных метаданных) и не служебный диапазон.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateURL проверяет адрес подписки: абсолютный http или https. Без allowPrivate имя хоста
+// разрешается, и каждый его адрес должен быть публичным по PublicAddr.
+func ValidateURL(ctx context.Context, raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("поле 'url' должно быть адресом http или https")
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !PublicAddr(addr) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("не удалось найти адрес хоста %s", host)
+	}
+	for _, addr := range addrs {
+		if !PublicAddr(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}